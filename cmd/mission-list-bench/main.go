@@ -0,0 +1,165 @@
+// Command mission-list-bench seeds missions with targets and compares the
+// per-mission target loading ListMissions used to do with the current
+// set-based loading. It needs DB_SOURCE pointing at a migrated database.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"spy_cat_agency/internal/database"
+	"spy_cat_agency/internal/models"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const seedPrefix = "benchmission"
+
+func main() {
+	missions := flag.Int("missions", 2000, "number of missions to seed")
+	targets := flag.Int("targets", 3, "targets per mission")
+	runs := flag.Int("runs", 5, "timed runs per strategy")
+	keep := flag.Bool("keep", false, "keep the seeded rows after the run")
+	flag.Parse()
+
+	db, err := sql.Open("postgres", os.Getenv("DB_SOURCE"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+
+	seeded, err := seed(ctx, db, *missions, *targets)
+	if err != nil {
+		log.Fatal("failed to seed:", err)
+	}
+	if !*keep {
+		defer cleanup(ctx, db, seeded)
+	}
+
+	repo := database.NewMissionRepository(db)
+
+	report("per-mission queries", *runs, func() (int, error) {
+		list, err := listMissionsPerMission(ctx, db)
+		return len(list), err
+	})
+	report("set-based query", *runs, func() (int, error) {
+		list, err := repo.ListMissions(ctx)
+		return len(list), err
+	})
+}
+
+func report(name string, runs int, fn func() (int, error)) {
+	var total time.Duration
+	var count int
+
+	for i := 0; i < runs; i++ {
+		start := time.Now()
+		n, err := fn()
+		if err != nil {
+			log.Fatalf("%s: %v", name, err)
+		}
+		total += time.Since(start)
+		count = n
+	}
+
+	fmt.Printf("%-22s missions=%d runs=%d avg=%s\n", name, count, runs, total/time.Duration(runs))
+}
+
+// seed inserts the missions with their targets and returns the ids of the
+// missions.
+func seed(ctx context.Context, db *sql.DB, missions, targets int) ([]int64, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	ids := make([]int64, 0, missions)
+	for i := 0; i < missions; i++ {
+		var id int64
+		query := "INSERT INTO missions (name) VALUES ($1) RETURNING id;"
+		if err := tx.QueryRowContext(ctx, query, seedPrefix).Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+
+		query = "INSERT INTO targets (name, country, notes, mission_id) SELECT 'target', 'Ukraine', '', $1 FROM generate_series(1, $2);"
+		if _, err := tx.ExecContext(ctx, query, id, targets); err != nil {
+			return nil, err
+		}
+	}
+
+	return ids, tx.Commit()
+}
+
+// cleanup deletes the seeded missions by id, so missions that merely share
+// their name are kept.
+func cleanup(ctx context.Context, db *sql.DB, ids []int64) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Println("failed to clean up seeded missions:", err)
+		return
+	}
+	defer tx.Rollback()
+
+	for _, query := range []string{
+		"DELETE FROM targets WHERE mission_id = ANY($1);",
+		"DELETE FROM missions WHERE id = ANY($1);",
+	} {
+		if _, err := tx.ExecContext(ctx, query, pq.Array(ids)); err != nil {
+			log.Println("failed to clean up seeded missions:", err)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println("failed to clean up seeded missions:", err)
+	}
+}
+
+// listMissionsPerMission is the old ListMissions: one targets query for every
+// mission.
+func listMissionsPerMission(ctx context.Context, db *sql.DB) ([]models.Mission, error) {
+	res := make([]models.Mission, 0)
+
+	rows, err := db.QueryContext(ctx, "SELECT id, name, cat_id, status, created_at FROM missions;")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var mission models.Mission
+		mission.TargetList = make([]models.Target, 0)
+		if err := rows.Scan(&mission.ID, &mission.Name, &mission.CatId, &mission.Status, &mission.CreatedAt); err != nil {
+			return nil, err
+		}
+		res = append(res, mission)
+	}
+	rows.Close()
+
+	query := "SELECT id, mission_id, name, country, notes, is_completed, created_at FROM targets WHERE mission_id = $1;"
+	for i, mission := range res {
+		rows, err := db.QueryContext(ctx, query, mission.ID)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var target models.Target
+			if err := rows.Scan(&target.ID, &target.MissionID, &target.Name, &target.Country, &target.Notes, &target.IsCompleted, &target.CreatedAt); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			res[i].TargetList = append(res[i].TargetList, target)
+		}
+		rows.Close()
+	}
+
+	return res, nil
+}
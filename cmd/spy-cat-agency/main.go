@@ -1,10 +1,46 @@
 package main
 
 import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"spy_cat_agency/internal/config"
+	"spy_cat_agency/internal/logging"
 	"spy_cat_agency/internal/server"
 )
 
 func main() {
-	server := server.NewServer()
-	server.Run()
+	cfg, err := config.Load(os.Args[0], os.Args[1:], os.Getenv, os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	if cfg.PrintConfig {
+		if err := config.Print(os.Stdout, cfg); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	logger := logging.New(os.Stdout, cfg.Log.Level)
+	// Libraries logging through the standard log package end up here too.
+	slog.SetDefault(logger)
+
+	server, err := server.NewServer(cfg, logger)
+	if err != nil {
+		logger.Error("failed to start server", logging.Err(err))
+		os.Exit(1)
+	}
+
+	if err := server.Run(); err != nil {
+		logger.Error("server failed", logging.Err(err))
+		os.Exit(1)
+	}
 }
@@ -1,33 +1,180 @@
 package appErrors
 
-import "net/http"
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Code is a stable, machine-readable identifier of an error. Clients should
+// branch on it rather than on the human-readable title or detail.
+type Code string
+
+const (
+	CodeInternal                Code = "INTERNAL_ERROR"
+	CodeDatabase                Code = "DATABASE_ERROR"
+	CodeInvalidRequest          Code = "INVALID_REQUEST"
+	CodeInvalidCursor           Code = "INVALID_CURSOR"
+	CodeCatNotFound             Code = "CAT_NOT_FOUND"
+	CodeMissionNotFound         Code = "MISSION_NOT_FOUND"
+	CodeTargetNotFound          Code = "TARGET_NOT_FOUND"
+	CodeMissionAlreadyAssigned  Code = "MISSION_ALREADY_ASSIGNED"
+	CodeCatAlreadyOnMission     Code = "CAT_ALREADY_ON_MISSION"
+	CodeCatOnMission            Code = "CAT_ON_MISSION"
+	CodeCatFired                Code = "CAT_FIRED"
+	CodeCatEmployed             Code = "CAT_EMPLOYED"
+	CodeMissionAssigned         Code = "MISSION_ASSIGNED"
+	CodeMissionNotAssigned      Code = "MISSION_NOT_ASSIGNED"
+	CodeMissionCompleted        Code = "MISSION_COMPLETED"
+	CodeTargetCompleted         Code = "TARGET_COMPLETED"
+	CodeInvalidTransition       Code = "INVALID_TRANSITION"
+	CodeMissionNotInProgress    Code = "MISSION_NOT_IN_PROGRESS"
+	CodeTargetLimitExceeded     Code = "TARGET_LIMIT_EXCEEDED"
+	CodeNotesConflict           Code = "NOTES_CONFLICT"
+	CodePreconditionRequired    Code = "PRECONDITION_REQUIRED"
+	CodeCatNotExperienced       Code = "CAT_NOT_EXPERIENCED"
+	CodeSalaryOutOfRange        Code = "SALARY_OUT_OF_RANGE"
+	CodeBreedCatalogUnavailable Code = "BREED_CATALOG_UNAVAILABLE"
+	CodeInvalidPolicy           Code = "INVALID_POLICY"
+	CodeUnauthorized            Code = "UNAUTHORIZED"
+	CodeForbidden               Code = "FORBIDDEN"
+	CodeAPIKeyNotFound          Code = "API_KEY_NOT_FOUND"
+	CodeWebhookNotFound         Code = "WEBHOOK_NOT_FOUND"
+	CodeDeliveryNotFound        Code = "DELIVERY_NOT_FOUND"
+	CodeDeliveryNotDead         Code = "DELIVERY_NOT_DEAD"
+)
 
+// HttpError is an error that knows how it is reported to a client. Two
+// HttpErrors are equal for errors.Is when they share a Code, so a sentinel
+// refined with WithDetail still matches the sentinel.
 type HttpError struct {
-	Message      string
-	StatusCode   int
-	JSONResponse map[string]interface{}
+	Code       Code
+	StatusCode int
+	Title      string
+	Detail     string
+	Extensions map[string]interface{}
 }
 
-func NewHttpError(message string, statusCode int, jsonResponse map[string]interface{}) *HttpError {
+func NewHttpError(code Code, statusCode int, title string) *HttpError {
 	return &HttpError{
-		Message:      message,
-		StatusCode:   statusCode,
-		JSONResponse: jsonResponse,
+		Code:       code,
+		StatusCode: statusCode,
+		Title:      title,
 	}
 }
 
 func (e *HttpError) Error() string {
-	return e.Message
+	if e.Detail != "" {
+		return e.Title + ": " + e.Detail
+	}
+	return e.Title
+}
+
+func (e *HttpError) Is(target error) bool {
+	t, ok := target.(*HttpError)
+	return ok && t.Code == e.Code
+}
+
+// WithDetail returns a copy of e with an occurrence-specific explanation.
+func (e *HttpError) WithDetail(detail string) *HttpError {
+	res := *e
+	res.Detail = detail
+	return &res
+}
+
+// WithExtension returns a copy of e with an additional problem member.
+func (e *HttpError) WithExtension(key string, value interface{}) *HttpError {
+	res := *e
+	res.Extensions = make(map[string]interface{}, len(e.Extensions)+1)
+	for k, v := range e.Extensions {
+		res.Extensions[k] = v
+	}
+	res.Extensions[key] = value
+	return &res
+}
+
+// Problem renders e as an RFC 7807 problem details object.
+func (e *HttpError) Problem(instance string) map[string]interface{} {
+	problem := map[string]interface{}{
+		"type":   "/problems/" + strings.ToLower(strings.ReplaceAll(string(e.Code), "_", "-")),
+		"title":  e.Title,
+		"status": e.StatusCode,
+		"code":   e.Code,
+	}
+	if e.Detail != "" {
+		problem["detail"] = e.Detail
+	}
+	if instance != "" {
+		problem["instance"] = instance
+	}
+	for k, v := range e.Extensions {
+		if reservedMembers[k] {
+			continue
+		}
+		problem[k] = v
+	}
+
+	return problem
+}
+
+// reservedMembers are the members Problem sets itself. Extensions cannot
+// replace them, so clients can always rely on their meaning.
+var reservedMembers = map[string]bool{
+	"type":     true,
+	"title":    true,
+	"status":   true,
+	"detail":   true,
+	"instance": true,
+	"code":     true,
+}
+
+// ErrNotFound is returned by the DAOs when the requested row does not exist.
+// Services translate it into the matching *NotFound HttpError.
+var ErrNotFound = errors.New("record not found")
+
+// DBError wraps a driver error with the failed operation. The result matches
+// ErrDatabase, so it is reported as a 500, and keeps err for logging.
+func DBError(op string, err error) error {
+	return fmt.Errorf("%w: %s: %w", ErrDatabase, op, err)
 }
 
 var (
-	ErrInternalServer = NewHttpError("Internal server error",
-		http.StatusInternalServerError,
-		map[string]interface{}{"error": "internal server error"})
-
-	ErrDatabase = NewHttpError("Database error",
-		http.StatusInternalServerError,
-		map[string]interface{}{
-			"error": "database raised an error",
-		})
+	ErrInternalServer = NewHttpError(CodeInternal, http.StatusInternalServerError, "Internal server error")
+	ErrDatabase       = NewHttpError(CodeDatabase, http.StatusInternalServerError, "Database error")
+	ErrInvalidRequest = NewHttpError(CodeInvalidRequest, http.StatusBadRequest, "Couldn't bind request")
+	ErrInvalidCursor  = NewHttpError(CodeInvalidCursor, http.StatusBadRequest, "Invalid pagination cursor")
+
+	ErrCatNotFound      = NewHttpError(CodeCatNotFound, http.StatusNotFound, "There is no cat with such id")
+	ErrMissionNotFound  = NewHttpError(CodeMissionNotFound, http.StatusNotFound, "There is no mission with such id")
+	ErrTargetNotFound   = NewHttpError(CodeTargetNotFound, http.StatusNotFound, "There is no target with such id")
+	ErrWebhookNotFound  = NewHttpError(CodeWebhookNotFound, http.StatusNotFound, "There is no webhook with such id")
+	ErrDeliveryNotFound = NewHttpError(CodeDeliveryNotFound, http.StatusNotFound, "There is no webhook delivery with such id")
+
+	ErrMissionAlreadyAssigned = NewHttpError(CodeMissionAlreadyAssigned, http.StatusConflict, "This mission is already assigned to a cat")
+	ErrCatAlreadyOnMission    = NewHttpError(CodeCatAlreadyOnMission, http.StatusConflict, "This cat has already been assigned a mission")
+	ErrCatOnMission           = NewHttpError(CodeCatOnMission, http.StatusConflict, "You cannot fire cat, while it is on mission")
+	ErrCatFired               = NewHttpError(CodeCatFired, http.StatusConflict, "This cat has been fired")
+	ErrCatEmployed            = NewHttpError(CodeCatEmployed, http.StatusConflict, "This cat is currently employed")
+	ErrMissionAssigned        = NewHttpError(CodeMissionAssigned, http.StatusConflict, "Assigned mission cannot be deleted")
+	ErrMissionNotAssigned     = NewHttpError(CodeMissionNotAssigned, http.StatusConflict, "This mission is not assigned to a cat")
+	ErrMissionCompleted       = NewHttpError(CodeMissionCompleted, http.StatusConflict, "Completed mission cannot be updated")
+	ErrTargetCompleted        = NewHttpError(CodeTargetCompleted, http.StatusConflict, "Completed target cannot be updated")
+	ErrInvalidTransition      = NewHttpError(CodeInvalidTransition, http.StatusConflict, "Mission cannot move to the requested status")
+	ErrMissionNotInProgress   = NewHttpError(CodeMissionNotInProgress, http.StatusConflict, "Mission is not in progress")
+	ErrDeliveryNotDead        = NewHttpError(CodeDeliveryNotDead, http.StatusConflict, "Only dead deliveries can be retried")
+
+	ErrNotesConflict        = NewHttpError(CodeNotesConflict, http.StatusPreconditionFailed, "Notes have changed since the given revision")
+	ErrPreconditionRequired = NewHttpError(CodePreconditionRequired, http.StatusPreconditionRequired, "If-Match header with the notes revision is required")
+
+	ErrTargetLimitExceeded = NewHttpError(CodeTargetLimitExceeded, http.StatusUnprocessableEntity, "Mission has too many or too few targets")
+	ErrCatNotExperienced   = NewHttpError(CodeCatNotExperienced, http.StatusUnprocessableEntity, "Cat is not experienced enough for this mission")
+	ErrSalaryOutOfRange    = NewHttpError(CodeSalaryOutOfRange, http.StatusUnprocessableEntity, "Salary is outside the range allowed for the breed")
+
+	ErrBreedCatalogUnavailable = NewHttpError(CodeBreedCatalogUnavailable, http.StatusServiceUnavailable, "Breed catalog is unavailable")
+	ErrInvalidPolicy           = NewHttpError(CodeInvalidPolicy, http.StatusUnprocessableEntity, "Policy could not be loaded")
+
+	ErrUnauthorized   = NewHttpError(CodeUnauthorized, http.StatusUnauthorized, "Missing or invalid API key")
+	ErrForbidden      = NewHttpError(CodeForbidden, http.StatusForbidden, "You are not allowed to perform this action")
+	ErrAPIKeyNotFound = NewHttpError(CodeAPIKeyNotFound, http.StatusNotFound, "There is no active API key with such id")
 )
@@ -0,0 +1,50 @@
+package appErrors
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestProblemKeepsReservedMembers(t *testing.T) {
+	err := ErrInvalidTransition.
+		WithDetail("mission is completed").
+		WithExtension("status", "completed").
+		WithExtension("code", "OTHER").
+		WithExtension("title", "Other").
+		WithExtension("type", "/problems/other").
+		WithExtension("detail", "other").
+		WithExtension("instance", "/other").
+		WithExtension("allowed", []string{})
+
+	problem := err.Problem("/missions/1")
+
+	want := map[string]interface{}{
+		"type":     "/problems/invalid-transition",
+		"title":    ErrInvalidTransition.Title,
+		"status":   http.StatusConflict,
+		"code":     CodeInvalidTransition,
+		"detail":   "mission is completed",
+		"instance": "/missions/1",
+	}
+	for k, v := range want {
+		if problem[k] != v {
+			t.Errorf("%s = %v, want %v", k, problem[k], v)
+		}
+	}
+	if _, ok := problem["allowed"]; !ok {
+		t.Error("allowed extension is missing")
+	}
+}
+
+func TestProblemOmitsEmptyReservedMembers(t *testing.T) {
+	problem := ErrCatNotFound.
+		WithExtension("detail", "other").
+		WithExtension("instance", "/other").
+		Problem("")
+
+	for _, k := range []string{"detail", "instance"} {
+		if v, ok := problem[k]; ok {
+			t.Errorf("%s = %v, want it omitted", k, v)
+		}
+	}
+}
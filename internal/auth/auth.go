@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"spy_cat_agency/internal/models"
+)
+
+const keyPrefix = "sca_"
+
+type principalKey struct{}
+
+// WithPrincipal stores the authenticated API key in ctx.
+func WithPrincipal(ctx context.Context, key *models.APIKey) context.Context {
+	return context.WithValue(ctx, principalKey{}, key)
+}
+
+// FromContext returns the API key the request was authenticated with. It
+// returns false for calls that did not come through the HTTP API.
+func FromContext(ctx context.Context) (*models.APIKey, bool) {
+	key, ok := ctx.Value(principalKey{}).(*models.APIKey)
+	return key, ok
+}
+
+// GenerateKey returns a new random API key. Only its hash is stored.
+func GenerateKey() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return keyPrefix + hex.EncodeToString(buf), nil
+}
+
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,22 @@
+package breeds
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"spy_cat_agency/internal/models"
+)
+
+//go:embed breeds.json
+var bundled []byte
+
+// Bundled returns the breeds of breeds.json, which is compiled into the
+// binary. It is the same list the Postgres migrations seed.
+func Bundled() ([]models.Breed, error) {
+	var list []models.Breed
+	if err := json.Unmarshal(bundled, &list); err != nil {
+		return nil, fmt.Errorf("decode bundled breeds: %w", err)
+	}
+
+	return list, nil
+}
@@ -0,0 +1,50 @@
+package breeds
+
+import (
+	"log/slog"
+	"spy_cat_agency/internal/logging"
+	"spy_cat_agency/internal/models"
+	"sync"
+)
+
+// Source is anything the breed list can be fetched from.
+type Source interface {
+	FetchBreeds() ([]models.Breed, error)
+}
+
+// FallbackSource serves the bundled breeds while its source has never been
+// reached, so a cold start without network access still validates hires.
+// Once the source has answered, its errors are returned and the catalog
+// keeps the last list it got.
+type FallbackSource struct {
+	source Source
+	logger *slog.Logger
+
+	mu      sync.Mutex
+	reached bool
+}
+
+func NewFallbackSource(source Source, logger *slog.Logger) *FallbackSource {
+	return &FallbackSource{
+		source: source,
+		logger: logger,
+	}
+}
+
+func (s *FallbackSource) FetchBreeds() ([]models.Breed, error) {
+	list, err := s.source.FetchBreeds()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err == nil {
+		s.reached = true
+		return list, nil
+	}
+	if s.reached {
+		return nil, err
+	}
+
+	s.logger.Warn("breed source unavailable, using bundled breeds", logging.Err(err))
+	return Bundled()
+}
@@ -0,0 +1,50 @@
+package breeds
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"spy_cat_agency/internal/models"
+	"testing"
+)
+
+type stubSource struct {
+	list []models.Breed
+	err  error
+}
+
+func (s *stubSource) FetchBreeds() ([]models.Breed, error) {
+	return s.list, s.err
+}
+
+func TestFallbackSource(t *testing.T) {
+	bundled, err := Bundled()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unreachable := errors.New("no network")
+	stub := &stubSource{err: unreachable}
+	source := NewFallbackSource(stub, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	list, err := source.FetchBreeds()
+	if err != nil {
+		t.Fatalf("cold start: %v", err)
+	}
+	if len(list) != len(bundled) {
+		t.Errorf("cold start: %d breeds, want the %d bundled", len(list), len(bundled))
+	}
+
+	stub.list, stub.err = []models.Breed{{Name: "Siamese"}}, nil
+	list, err = source.FetchBreeds()
+	if err != nil || len(list) != 1 {
+		t.Fatalf("reachable: %v, %d breeds, want 1", err, len(list))
+	}
+
+	// Once reached, failures are reported so the catalog keeps the list
+	// of the source.
+	stub.list, stub.err = nil, unreachable
+	if _, err := source.FetchBreeds(); !errors.Is(err, unreachable) {
+		t.Errorf("after reaching the source: error = %v, want %v", err, unreachable)
+	}
+}
@@ -0,0 +1,34 @@
+package breeds
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"spy_cat_agency/internal/models"
+)
+
+// FileSource reads breeds from a local JSON file in the same format as the
+// cat API response, so the service can run without network access.
+type FileSource struct {
+	path string
+}
+
+func NewFileSource(path string) *FileSource {
+	return &FileSource{
+		path: path,
+	}
+}
+
+func (s *FileSource) FetchBreeds() ([]models.Breed, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("read breeds file: %w", err)
+	}
+
+	var list []models.Breed
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("decode breeds file %s: %w", s.path, err)
+	}
+
+	return list, nil
+}
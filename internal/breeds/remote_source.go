@@ -0,0 +1,46 @@
+package breeds
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"spy_cat_agency/internal/models"
+	"time"
+)
+
+const DefaultAPIURL = "https://api.thecatapi.com/v1/breeds"
+
+type RemoteSource struct {
+	url    string
+	client *http.Client
+}
+
+func NewRemoteSource(url string, timeout time.Duration) *RemoteSource {
+	if url == "" {
+		url = DefaultAPIURL
+	}
+
+	return &RemoteSource{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (s *RemoteSource) FetchBreeds() ([]models.Breed, error) {
+	resp, err := s.client.Get(s.url)
+	if err != nil {
+		return nil, fmt.Errorf("fetch breeds from %s: %w", s.url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch breeds from %s: unexpected status %s", s.url, resp.Status)
+	}
+
+	var list []models.Breed
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("decode breeds from %s: %w", s.url, err)
+	}
+
+	return list, nil
+}
@@ -0,0 +1,263 @@
+// Package config loads the settings of the service from, by increasing
+// precedence, built-in defaults, a YAML or TOML file, environment variables
+// and command line flags. Everything is validated before the service starts,
+// so a missing or malformed setting is reported by name instead of failing
+// later at runtime.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"spy_cat_agency/internal/breeds"
+	"spy_cat_agency/internal/storage"
+	"time"
+)
+
+// Duration is a time.Duration written as "30s" or "1h" in files, variables
+// and flags.
+type Duration time.Duration
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return fmt.Errorf("invalid duration %q", text)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// Set and String make a Duration usable as a flag.Value.
+func (d *Duration) Set(raw string) error {
+	return d.UnmarshalText([]byte(raw))
+}
+
+func (d *Duration) String() string {
+	return time.Duration(*d).String()
+}
+
+type Config struct {
+	Server    ServerConfig    `yaml:"server" toml:"server"`
+	Database  DatabaseConfig  `yaml:"database" toml:"database"`
+	Breeds    BreedsConfig    `yaml:"breeds" toml:"breeds"`
+	Policy    PolicyConfig    `yaml:"policy" toml:"policy"`
+	Scheduler SchedulerConfig `yaml:"scheduler" toml:"scheduler"`
+	Webhooks  WebhooksConfig  `yaml:"webhooks" toml:"webhooks"`
+	Auth      AuthConfig      `yaml:"auth" toml:"auth"`
+	Log       LogConfig       `yaml:"log" toml:"log"`
+
+	// PrintConfig asks for the loaded configuration to be printed instead of
+	// starting the service.
+	PrintConfig bool `yaml:"-" toml:"-"`
+}
+
+type ServerConfig struct {
+	Port         int      `yaml:"port" toml:"port"`
+	ReadTimeout  Duration `yaml:"read_timeout" toml:"read_timeout"`
+	WriteTimeout Duration `yaml:"write_timeout" toml:"write_timeout"`
+	IdleTimeout  Duration `yaml:"idle_timeout" toml:"idle_timeout"`
+	// ShutdownTimeout bounds how long in-flight requests are waited for on
+	// SIGTERM.
+	ShutdownTimeout Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout"`
+}
+
+type DatabaseConfig struct {
+	// Driver is "postgres" or "embedded".
+	Driver string `yaml:"driver" toml:"driver"`
+	// Source is the Postgres connection URL, or the file of the embedded
+	// store.
+	Source        string `yaml:"source" toml:"source"`
+	MigrationPath string `yaml:"migration_path" toml:"migration_path"`
+	// Pool settings apply to Postgres. Zero max_open_conns and
+	// conn_max_lifetime mean no limit.
+	MaxOpenConns    int      `yaml:"max_open_conns" toml:"max_open_conns"`
+	MaxIdleConns    int      `yaml:"max_idle_conns" toml:"max_idle_conns"`
+	ConnMaxLifetime Duration `yaml:"conn_max_lifetime" toml:"conn_max_lifetime"`
+}
+
+type BreedsConfig struct {
+	// Source is "remote", "file" or "db".
+	Source       string   `yaml:"source" toml:"source"`
+	File         string   `yaml:"file" toml:"file"`
+	APIURL       string   `yaml:"api_url" toml:"api_url"`
+	CacheTTL     Duration `yaml:"cache_ttl" toml:"cache_ttl"`
+	FetchTimeout Duration `yaml:"fetch_timeout" toml:"fetch_timeout"`
+}
+
+type PolicyConfig struct {
+	// Source is "default", "file" or "db".
+	Source string `yaml:"source" toml:"source"`
+	File   string `yaml:"file" toml:"file"`
+}
+
+type SchedulerConfig struct {
+	OverdueCheckInterval Duration `yaml:"overdue_check_interval" toml:"overdue_check_interval"`
+}
+
+type WebhooksConfig struct {
+	PollInterval Duration `yaml:"poll_interval" toml:"poll_interval"`
+	// Timeout bounds one delivery attempt.
+	Timeout Duration `yaml:"timeout" toml:"timeout"`
+}
+
+type AuthConfig struct {
+	BootstrapAPIKey string `yaml:"bootstrap_api_key" toml:"bootstrap_api_key"`
+}
+
+type LogConfig struct {
+	// Level is "debug", "info", "warn" or "error".
+	Level string `yaml:"level" toml:"level"`
+}
+
+// Default returns the settings used when nothing else is configured.
+func Default() Config {
+	return Config{
+		Server: ServerConfig{
+			Port:            8080,
+			ReadTimeout:     Duration(15 * time.Second),
+			WriteTimeout:    Duration(30 * time.Second),
+			IdleTimeout:     Duration(time.Minute),
+			ShutdownTimeout: Duration(30 * time.Second),
+		},
+		Database: DatabaseConfig{
+			Driver:        storage.DriverPostgres,
+			MigrationPath: "file://internal/database/migrations",
+			MaxIdleConns:  2,
+		},
+		Breeds: BreedsConfig{
+			Source:       "remote",
+			APIURL:       breeds.DefaultAPIURL,
+			CacheTTL:     Duration(time.Hour),
+			FetchTimeout: Duration(5 * time.Second),
+		},
+		Policy: PolicyConfig{
+			Source: "default",
+		},
+		Scheduler: SchedulerConfig{
+			OverdueCheckInterval: Duration(time.Minute),
+		},
+		Webhooks: WebhooksConfig{
+			PollInterval: Duration(5 * time.Second),
+			Timeout:      Duration(10 * time.Second),
+		},
+		Log: LogConfig{
+			Level: "info",
+		},
+	}
+}
+
+// Validate reports every invalid setting at once, each under its name in
+// the configuration file.
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, name, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: %s", name, fmt.Sprintf(format, args...)))
+		}
+	}
+	oneOf := func(name, value string, allowed ...string) {
+		check(slices.Contains(allowed, value), name, "%q is not one of %q", value, allowed)
+	}
+	positive := func(name string, d Duration) {
+		check(d > 0, name, "must be positive, got %s", time.Duration(d))
+	}
+
+	check(c.Server.Port > 0 && c.Server.Port <= 65535, "server.port", "%d is not a valid port", c.Server.Port)
+	positive("server.read_timeout", c.Server.ReadTimeout)
+	positive("server.write_timeout", c.Server.WriteTimeout)
+	positive("server.idle_timeout", c.Server.IdleTimeout)
+	positive("server.shutdown_timeout", c.Server.ShutdownTimeout)
+
+	oneOf("database.driver", c.Database.Driver, storage.DriverPostgres, storage.DriverEmbedded)
+	if c.Database.Driver == storage.DriverPostgres {
+		check(c.Database.Source != "", "database.source", "is required for the postgres driver")
+		check(c.Database.MigrationPath != "", "database.migration_path", "is required for the postgres driver")
+	}
+	check(c.Database.MaxOpenConns >= 0, "database.max_open_conns", "must not be negative")
+	check(c.Database.MaxIdleConns >= 0, "database.max_idle_conns", "must not be negative")
+	check(c.Database.MaxOpenConns == 0 || c.Database.MaxIdleConns <= c.Database.MaxOpenConns,
+		"database.max_idle_conns", "must not be more than max_open_conns (%d)", c.Database.MaxOpenConns)
+	check(c.Database.ConnMaxLifetime >= 0, "database.conn_max_lifetime", "must not be negative")
+
+	oneOf("breeds.source", c.Breeds.Source, "remote", "file", "db")
+	switch c.Breeds.Source {
+	case "file":
+		check(c.Breeds.File != "", "breeds.file", "is required for the file source")
+	case "remote":
+		u, err := url.Parse(c.Breeds.APIURL)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", "breeds.api_url", "%q is not an http(s) URL", c.Breeds.APIURL)
+	}
+	positive("breeds.cache_ttl", c.Breeds.CacheTTL)
+	positive("breeds.fetch_timeout", c.Breeds.FetchTimeout)
+
+	oneOf("policy.source", c.Policy.Source, "default", "file", "db")
+	if c.Policy.Source == "file" {
+		check(c.Policy.File != "", "policy.file", "is required for the file source")
+	}
+
+	positive("scheduler.overdue_check_interval", c.Scheduler.OverdueCheckInterval)
+	positive("webhooks.poll_interval", c.Webhooks.PollInterval)
+	positive("webhooks.timeout", c.Webhooks.Timeout)
+
+	oneOf("log.level", c.Log.Level, "debug", "info", "warn", "error")
+
+	return errors.Join(errs...)
+}
+
+const redacted = "REDACTED"
+
+// dsnPassword matches the password of a key/value connection string such as
+// "host=db password='s3 cret'", quoted or not.
+var dsnPassword = regexp.MustCompile(`(password\s*=\s*)('(?:[^'\\]|\\.)*'|\S*)`)
+
+// Redacted returns a copy of the configuration that is safe to print: the
+// database password and the bootstrap key are masked.
+func (c Config) Redacted() Config {
+	c.Database.Source = redactSource(c.Database.Source)
+
+	if c.Auth.BootstrapAPIKey != "" {
+		c.Auth.BootstrapAPIKey = redacted
+	}
+
+	return c
+}
+
+// redactSource masks the password of a Postgres connection URL, in its user
+// info or its password parameter, or of a key/value connection string. A file
+// path of the embedded store is left as is.
+func redactSource(source string) string {
+	u, err := url.Parse(source)
+	if err != nil || u.Scheme == "" {
+		return dsnPassword.ReplaceAllString(source, "${1}"+redacted)
+	}
+
+	if u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), redacted)
+		}
+	}
+	if query := u.Query(); query.Has("password") {
+		query.Set("password", redacted)
+		u.RawQuery = query.Encode()
+	}
+
+	return u.String()
+}
+
+// Storage returns the settings of the storage driver.
+func (c Config) Storage() storage.Config {
+	return storage.Config{
+		Driver:          c.Database.Driver,
+		Source:          c.Database.Source,
+		MigrationPath:   c.Database.MigrationPath,
+		MaxOpenConns:    c.Database.MaxOpenConns,
+		MaxIdleConns:    c.Database.MaxIdleConns,
+		ConnMaxLifetime: time.Duration(c.Database.ConnMaxLifetime),
+	}
+}
@@ -0,0 +1,194 @@
+package config
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// setting is one configurable value. Its flag is the key with dots and
+// underscores turned into dashes, so database.max_open_conns is set with
+// --database-max-open-conns.
+type setting struct {
+	key   string
+	env   string
+	usage string
+	value func(c *Config) flag.Value
+}
+
+func (s setting) flag() string {
+	return strings.NewReplacer(".", "-", "_", "-").Replace(s.key)
+}
+
+var settings = []setting{
+	{"server.port", "SERVER_PORT", "port to listen on", func(c *Config) flag.Value { return (*intValue)(&c.Server.Port) }},
+	{"server.read_timeout", "SERVER_READ_TIMEOUT", "timeout for reading a request", func(c *Config) flag.Value { return &c.Server.ReadTimeout }},
+	{"server.write_timeout", "SERVER_WRITE_TIMEOUT", "timeout for writing a response, except event streams", func(c *Config) flag.Value { return &c.Server.WriteTimeout }},
+	{"server.idle_timeout", "SERVER_IDLE_TIMEOUT", "how long an idle keep-alive connection is kept open", func(c *Config) flag.Value { return &c.Server.IdleTimeout }},
+	{"server.shutdown_timeout", "SERVER_SHUTDOWN_TIMEOUT", "how long in-flight requests are waited for on shutdown", func(c *Config) flag.Value { return &c.Server.ShutdownTimeout }},
+
+	{"database.driver", "DB_DRIVER", `storage driver, "postgres" or "embedded"`, func(c *Config) flag.Value { return (*stringValue)(&c.Database.Driver) }},
+	{"database.source", "DB_SOURCE", "Postgres connection URL, or the file of the embedded store", func(c *Config) flag.Value { return (*stringValue)(&c.Database.Source) }},
+	{"database.migration_path", "MIGRATION_PATH", "location of the Postgres migrations", func(c *Config) flag.Value { return (*stringValue)(&c.Database.MigrationPath) }},
+	{"database.max_open_conns", "DB_MAX_OPEN_CONNS", "maximum open Postgres connections, 0 for no limit", func(c *Config) flag.Value { return (*intValue)(&c.Database.MaxOpenConns) }},
+	{"database.max_idle_conns", "DB_MAX_IDLE_CONNS", "maximum idle Postgres connections", func(c *Config) flag.Value { return (*intValue)(&c.Database.MaxIdleConns) }},
+	{"database.conn_max_lifetime", "DB_CONN_MAX_LIFETIME", "how long a Postgres connection is reused, 0 for ever", func(c *Config) flag.Value { return &c.Database.ConnMaxLifetime }},
+
+	{"breeds.source", "BREED_SOURCE", `breed source, "remote", "file" or "db"`, func(c *Config) flag.Value { return (*stringValue)(&c.Breeds.Source) }},
+	{"breeds.file", "BREED_FILE", "JSON file of the file breed source", func(c *Config) flag.Value { return (*stringValue)(&c.Breeds.File) }},
+	{"breeds.api_url", "BREED_API_URL", "URL of the remote breed source", func(c *Config) flag.Value { return (*stringValue)(&c.Breeds.APIURL) }},
+	{"breeds.cache_ttl", "BREED_CACHE_TTL", "how long the breed list is cached", func(c *Config) flag.Value { return &c.Breeds.CacheTTL }},
+	{"breeds.fetch_timeout", "BREED_FETCH_TIMEOUT", "timeout of a request to the remote breed source", func(c *Config) flag.Value { return &c.Breeds.FetchTimeout }},
+
+	{"policy.source", "POLICY_SOURCE", `policy source, "default", "file" or "db"`, func(c *Config) flag.Value { return (*stringValue)(&c.Policy.Source) }},
+	{"policy.file", "POLICY_FILE", "JSON file of the file policy source", func(c *Config) flag.Value { return (*stringValue)(&c.Policy.File) }},
+
+	{"scheduler.overdue_check_interval", "OVERDUE_CHECK_INTERVAL", "how often overdue missions are looked for", func(c *Config) flag.Value { return &c.Scheduler.OverdueCheckInterval }},
+
+	{"webhooks.poll_interval", "WEBHOOK_POLL_INTERVAL", "how often due webhook deliveries are sent", func(c *Config) flag.Value { return &c.Webhooks.PollInterval }},
+	{"webhooks.timeout", "WEBHOOK_TIMEOUT", "timeout of one webhook delivery", func(c *Config) flag.Value { return &c.Webhooks.Timeout }},
+
+	{"auth.bootstrap_api_key", "BOOTSTRAP_API_KEY", "handler key registered on a database without keys", func(c *Config) flag.Value { return (*stringValue)(&c.Auth.BootstrapAPIKey) }},
+
+	{"log.level", "LOG_LEVEL", `log level, "debug", "info", "warn" or "error"`, func(c *Config) flag.Value { return (*stringValue)(&c.Log.Level) }},
+}
+
+// Load builds the configuration from the defaults, the file named by
+// --config or CONFIG_FILE, the environment read through getenv and the flags
+// in args, each overriding the ones before, and validates it. Empty
+// variables count as unset. flag.ErrHelp is returned after --help has
+// printed the usage to output.
+func Load(name string, args []string, getenv func(string) string, output io.Writer) (Config, error) {
+	type flagValue struct {
+		setting setting
+		raw     string
+	}
+	var (
+		path        string
+		printConfig bool
+		flags       []flagValue
+	)
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.StringVar(&path, "config", "", "YAML or TOML configuration file (env CONFIG_FILE)")
+	fs.BoolVar(&printConfig, "print-config", false, "print the configuration, with secrets redacted, and exit")
+	for _, s := range settings {
+		fs.Func(s.flag(), fmt.Sprintf("%s (env %s)", s.usage, s.env), func(raw string) error {
+			// Flags are applied once the file and the environment are read.
+			flags = append(flags, flagValue{s, raw})
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+	if fs.NArg() > 0 {
+		return Config{}, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+
+	config := Default()
+	config.PrintConfig = printConfig
+
+	if path == "" {
+		path = getenv("CONFIG_FILE")
+	}
+	if path != "" {
+		if err := readFile(path, &config); err != nil {
+			return Config{}, fmt.Errorf("config file %s: %w", path, err)
+		}
+	}
+
+	for _, s := range settings {
+		if raw := getenv(s.env); raw != "" {
+			if err := s.value(&config).Set(raw); err != nil {
+				return Config{}, fmt.Errorf("env %s: %w", s.env, err)
+			}
+		}
+	}
+
+	for _, f := range flags {
+		if err := f.setting.value(&config).Set(f.raw); err != nil {
+			return Config{}, fmt.Errorf("flag --%s: %w", f.setting.flag(), err)
+		}
+	}
+
+	if err := config.Validate(); err != nil {
+		return Config{}, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+
+	return config, nil
+}
+
+// readFile decodes the file at path over config, by its extension as YAML
+// or TOML. Unknown keys are an error, so a misspelled setting is not
+// silently ignored.
+func readFile(path string, config *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(config); err != nil && err != io.EOF {
+			return err
+		}
+	case ".toml":
+		decoder := toml.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(config); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown format %q, want .yaml, .yml or .toml", filepath.Ext(path))
+	}
+
+	return nil
+}
+
+// Print writes the configuration as YAML with its secrets redacted.
+func Print(w io.Writer, config Config) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(config.Redacted()); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+type stringValue string
+
+func (v *stringValue) Set(raw string) error {
+	*v = stringValue(raw)
+	return nil
+}
+
+func (v *stringValue) String() string {
+	return string(*v)
+}
+
+type intValue int
+
+func (v *intValue) Set(raw string) error {
+	n, err := strconv.Atoi(raw)
+	if err != nil {
+		return fmt.Errorf("invalid integer %q", raw)
+	}
+	*v = intValue(n)
+	return nil
+}
+
+func (v *intValue) String() string {
+	return strconv.Itoa(int(*v))
+}
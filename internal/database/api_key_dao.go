@@ -0,0 +1,131 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	appErrors "spy_cat_agency/internal/appErorrs"
+	"spy_cat_agency/internal/models"
+
+	"github.com/lib/pq"
+)
+
+type ApiKeyRepository struct {
+	*sql.DB
+}
+
+func NewApiKeyRepository(db *sql.DB) *ApiKeyRepository {
+	return &ApiKeyRepository{
+		db,
+	}
+}
+
+func (db *ApiKeyRepository) AddKey(ctx context.Context, key models.APIKey, keyHash string) (*models.APIKey, error) {
+	var res models.APIKey
+	query := "INSERT INTO api_keys (name, key_hash, role, cat_id) VALUES ($1, $2, $3, $4) RETURNING id, name, role, cat_id, created_at, revoked_at;"
+	row := dbtx(ctx, db.DB).QueryRowContext(ctx, query, key.Name, keyHash, key.Role, key.CatID)
+
+	err := row.Scan(
+		&res.ID,
+		&res.Name,
+		&res.Role,
+		&res.CatID,
+		&res.CreatedAt,
+		&res.RevokedAt,
+	)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code.Name() == "foreign_key_violation" {
+			return nil, appErrors.ErrCatNotFound
+		}
+
+		return nil, appErrors.DBError("ApiKeyRepository.AddKey", err)
+	}
+
+	return &res, nil
+}
+
+// GetActiveKey looks a key up by its hash, ignoring revoked keys and the keys
+// of fired cats.
+func (db *ApiKeyRepository) GetActiveKey(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	var res models.APIKey
+	query := "SELECT id, name, role, cat_id, created_at, revoked_at FROM api_keys WHERE key_hash = $1 AND revoked_at IS NULL AND NOT EXISTS (SELECT 1 FROM cats WHERE cats.id = api_keys.cat_id AND cats.fired_at IS NOT NULL);"
+	row := dbtx(ctx, db.DB).QueryRowContext(ctx, query, keyHash)
+
+	err := row.Scan(
+		&res.ID,
+		&res.Name,
+		&res.Role,
+		&res.CatID,
+		&res.CreatedAt,
+		&res.RevokedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, appErrors.ErrNotFound
+	}
+	if err != nil {
+		return nil, appErrors.DBError("ApiKeyRepository.GetActiveKey", err)
+	}
+
+	return &res, nil
+}
+
+func (db *ApiKeyRepository) ListKeys(ctx context.Context) ([]models.APIKey, error) {
+	list := []models.APIKey{}
+	query := "SELECT id, name, role, cat_id, created_at, revoked_at FROM api_keys ORDER BY id;"
+
+	rows, err := dbtx(ctx, db.DB).QueryContext(ctx, query)
+	if err != nil {
+		return nil, appErrors.DBError("ApiKeyRepository.ListKeys", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key models.APIKey
+		if err := rows.Scan(
+			&key.ID,
+			&key.Name,
+			&key.Role,
+			&key.CatID,
+			&key.CreatedAt,
+			&key.RevokedAt,
+		); err != nil {
+			return nil, appErrors.DBError("ApiKeyRepository.ListKeys", err)
+		}
+		list = append(list, key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, appErrors.DBError("ApiKeyRepository.ListKeys", err)
+	}
+
+	return list, nil
+}
+
+func (db *ApiKeyRepository) CountKeys(ctx context.Context) (int, error) {
+	var count int
+	query := "SELECT COUNT(*) FROM api_keys;"
+
+	if err := dbtx(ctx, db.DB).QueryRowContext(ctx, query).Scan(&count); err != nil {
+		return 0, appErrors.DBError("ApiKeyRepository.CountKeys", err)
+	}
+
+	return count, nil
+}
+
+func (db *ApiKeyRepository) RevokeKey(ctx context.Context, id uint) error {
+	query := "UPDATE api_keys SET revoked_at = NOW() WHERE id = $1 AND revoked_at IS NULL;"
+	res, err := dbtx(ctx, db.DB).ExecContext(ctx, query, id)
+	if err != nil {
+		return appErrors.DBError("ApiKeyRepository.RevokeKey", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return appErrors.DBError("ApiKeyRepository.RevokeKey", err)
+	}
+
+	if affected == 0 {
+		return appErrors.ErrNotFound
+	}
+
+	return nil
+}
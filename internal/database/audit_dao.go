@@ -0,0 +1,118 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	appErrors "spy_cat_agency/internal/appErorrs"
+	"spy_cat_agency/internal/models"
+)
+
+type AuditRepository struct {
+	*sql.DB
+}
+
+func NewAuditRepository(db *sql.DB) *AuditRepository {
+	return &AuditRepository{
+		db,
+	}
+}
+
+// Record inserts the event. Called with a transaction context it is written
+// atomically with the change it describes.
+func (db *AuditRepository) Record(ctx context.Context, event models.AuditEvent) error {
+	query := "INSERT INTO audit_events (actor, actor_key_id, action, entity_type, entity_id, before, after) VALUES ($1, $2, $3, $4, $5, $6, $7);"
+
+	_, err := dbtx(ctx, db.DB).ExecContext(ctx, query,
+		event.Actor,
+		event.ActorKeyID,
+		event.Action,
+		event.EntityType,
+		event.EntityID,
+		nullJSON(event.Before),
+		nullJSON(event.After),
+	)
+	if err != nil {
+		return appErrors.DBError("AuditRepository.Record", err)
+	}
+
+	return nil
+}
+
+// QueryEvents lists events newest first.
+func (db *AuditRepository) QueryEvents(ctx context.Context, filter models.AuditFilter) (models.Page[models.AuditEvent], error) {
+	page := models.Page[models.AuditEvent]{List: []models.AuditEvent{}}
+
+	where := &whereClause{}
+	if filter.EntityType != "" {
+		where.add("entity_type = ?", filter.EntityType)
+	}
+	if filter.EntityID != nil {
+		where.add("entity_id = ?", *filter.EntityID)
+	}
+	if filter.Actor != "" {
+		where.add("actor = ?", filter.Actor)
+	}
+	if filter.From != nil {
+		where.add("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		where.add("created_at < ?", *filter.To)
+	}
+
+	countWhere := where.clone()
+	query := "SELECT COUNT(*) FROM audit_events" + countWhere.String() + ";"
+	if err := dbtx(ctx, db.DB).QueryRowContext(ctx, query, countWhere.args...).Scan(&page.Total); err != nil {
+		return page, appErrors.DBError("AuditRepository.QueryEvents", err)
+	}
+
+	tail, err := paginate(where, newestFirst, filter.After, filter.Limit)
+	if err != nil {
+		return page, err
+	}
+
+	query = "SELECT id, actor, actor_key_id, action, entity_type, entity_id, before, after, created_at FROM audit_events" + where.String() + tail + ";"
+	rows, err := dbtx(ctx, db.DB).QueryContext(ctx, query, where.args...)
+	if err != nil {
+		return page, appErrors.DBError("AuditRepository.QueryEvents", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var event models.AuditEvent
+		var before, after []byte
+		if err := rows.Scan(
+			&event.ID,
+			&event.Actor,
+			&event.ActorKeyID,
+			&event.Action,
+			&event.EntityType,
+			&event.EntityID,
+			&before,
+			&after,
+			&event.CreatedAt,
+		); err != nil {
+			return page, appErrors.DBError("AuditRepository.QueryEvents", err)
+		}
+		event.Before = before
+		event.After = after
+		page.List = append(page.List, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return page, appErrors.DBError("AuditRepository.QueryEvents", err)
+	}
+
+	if size := pageSize(filter.Limit); len(page.List) > size {
+		page.List = page.List[:size]
+		page.NextCursor = encodeCursor(newestFirst, "", page.List[size-1].ID)
+	}
+
+	return page, nil
+}
+
+func nullJSON(data []byte) interface{} {
+	if len(data) == 0 {
+		return nil
+	}
+	return string(data)
+}
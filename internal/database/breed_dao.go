@@ -0,0 +1,46 @@
+package database
+
+import (
+	"database/sql"
+	appErrors "spy_cat_agency/internal/appErorrs"
+	"spy_cat_agency/internal/models"
+)
+
+type BreedRepository struct {
+	*sql.DB
+}
+
+func NewBreedRepository(db *sql.DB) *BreedRepository {
+	return &BreedRepository{
+		db,
+	}
+}
+
+func (db *BreedRepository) FetchBreeds() ([]models.Breed, error) {
+	list := []models.Breed{}
+	query := "SELECT id, name, origin FROM breeds ORDER BY name;"
+
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, appErrors.DBError("BreedRepository.FetchBreeds", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var breed models.Breed
+		if err := rows.Scan(
+			&breed.ID,
+			&breed.Name,
+			&breed.Origin,
+		); err != nil {
+			return nil, appErrors.DBError("BreedRepository.FetchBreeds", err)
+		}
+		list = append(list, breed)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, appErrors.DBError("BreedRepository.FetchBreeds", err)
+	}
+
+	return list, nil
+}
@@ -1,9 +1,12 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	appErrors "spy_cat_agency/internal/appErorrs"
 	"spy_cat_agency/internal/models"
+	"strconv"
+	"time"
 )
 
 type CatRepository struct {
@@ -16,95 +19,342 @@ func NewCatRepository(db *sql.DB) *CatRepository {
 	}
 }
 
-func (db *CatRepository) Add(cat models.Cat) error {
-	query := "INSERT INTO cats(name, years_of_experience, breed, salary ) VALUES ($1, $2, $3, $4);"
+const dateLayout = "2006-01-02"
 
-	_, err := db.Exec(query, cat.Name, cat.YearsOfExperience, cat.Breed, cat.Salary)
+const catColumns = "id, name, years_of_experience, breed, salary, currency, created_at, fired_at, fired_reason"
 
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanCat(row rowScanner) (models.Cat, error) {
+	var cat models.Cat
+	err := row.Scan(
+		&cat.ID,
+		&cat.Name,
+		&cat.YearsOfExperience,
+		&cat.Breed,
+		&cat.Salary,
+		&cat.Currency,
+		&cat.CreatedAt,
+		&cat.FiredAt,
+		&cat.FiredReason,
+	)
+
+	return cat, err
+}
+
+func (db *CatRepository) Add(ctx context.Context, cat models.Cat) (*models.Cat, error) {
+	query := "INSERT INTO cats(name, years_of_experience, breed, salary, currency) VALUES ($1, $2, $3, $4, $5) RETURNING " + catColumns + ";"
+
+	row := dbtx(ctx, db.DB).QueryRowContext(ctx, query, cat.Name, cat.YearsOfExperience, cat.Breed, cat.Salary, cat.Currency)
+
+	res, err := scanCat(row)
 	if err != nil {
-		return appErrors.ErrDatabase
+		return nil, appErrors.DBError("CatRepository.Add", err)
 	}
 
-	return nil
+	return &res, nil
 }
 
-func (db *CatRepository) Delete(id uint) error {
-	query := "DELETE FROM cats WHERE id = $1;"
+// Fire marks the cat as fired. The row is kept so that past missions still
+// reference it.
+func (db *CatRepository) Fire(ctx context.Context, id uint, reason *string) error {
+	query := "UPDATE cats SET fired_at = NOW(), fired_reason = $1 WHERE id = $2;"
+
+	_, err := dbtx(ctx, db.DB).ExecContext(ctx, query, reason, id)
 
-	_, err := db.Exec(query, id)
+	if err != nil {
+		return appErrors.DBError("CatRepository.Fire", err)
+	}
 
-	return err
+	return nil
 }
 
-func (db *CatRepository) Update(id uint, salary float64) error {
-	query := "UPDATE cats SET salary = $1 WHERE id = $2;"
+func (db *CatRepository) Rehire(ctx context.Context, id uint) error {
+	query := "UPDATE cats SET fired_at = NULL, fired_reason = NULL WHERE id = $1;"
 
-	_, err := db.Exec(query, salary, id)
+	_, err := dbtx(ctx, db.DB).ExecContext(ctx, query, id)
 
 	if err != nil {
-		return appErrors.ErrDatabase
+		return appErrors.DBError("CatRepository.Rehire", err)
 	}
 
 	return nil
 }
 
-func (db *CatRepository) List() ([]models.Cat, error) {
+// AddSalaryChange records the change and sets cats.salary to the salary in
+// effect today, the one recorded last if several start on the same day.
+// Today is the UTC date, as for the service, whatever the time zone of the
+// session. It returns that salary.
+func (db *CatRepository) AddSalaryChange(ctx context.Context, change models.SalaryChange) (models.Money, error) {
+	var current models.Money
+	query := "INSERT INTO salary_changes (cat_id, amount, currency, effective_from) VALUES ($1, $2, $3, $4);"
+
+	_, err := dbtx(ctx, db.DB).ExecContext(ctx, query, change.CatID, change.Amount, change.Currency, change.EffectiveFrom.Format(dateLayout))
+	if err != nil {
+		return 0, appErrors.DBError("CatRepository.AddSalaryChange", err)
+	}
+
+	query = `UPDATE cats SET salary = (
+		SELECT amount FROM salary_changes WHERE cat_id = $1 AND effective_from <= (NOW() AT TIME ZONE 'UTC')::date ORDER BY effective_from DESC, id DESC LIMIT 1
+	) WHERE id = $1 RETURNING salary;`
+
+	if err := dbtx(ctx, db.DB).QueryRowContext(ctx, query, change.CatID).Scan(&current); err != nil {
+		return 0, appErrors.DBError("CatRepository.AddSalaryChange", err)
+	}
+
+	return current, nil
+}
+
+// ListSalaryChanges returns the salary history of the cat, oldest first and
+// changes of the same day in the order they were recorded.
+func (db *CatRepository) ListSalaryChanges(ctx context.Context, catID uint) ([]models.SalaryChange, error) {
+	list := []models.SalaryChange{}
+	query := "SELECT id, cat_id, amount, currency, effective_from, created_at FROM salary_changes WHERE cat_id = $1 ORDER BY effective_from, id;"
+
+	rows, err := dbtx(ctx, db.DB).QueryContext(ctx, query, catID)
+	if err != nil {
+		return nil, appErrors.DBError("CatRepository.ListSalaryChanges", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		change, err := scanSalaryChange(rows)
+		if err != nil {
+			return nil, appErrors.DBError("CatRepository.ListSalaryChanges", err)
+		}
+		list = append(list, change)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, appErrors.DBError("CatRepository.ListSalaryChanges", err)
+	}
+
+	return list, nil
+}
+
+func scanSalaryChange(row rowScanner) (models.SalaryChange, error) {
+	var change models.SalaryChange
+	err := row.Scan(
+		&change.ID,
+		&change.CatID,
+		&change.Amount,
+		&change.Currency,
+		&change.EffectiveFrom,
+		&change.CreatedAt,
+	)
+
+	return change, err
+}
+
+// List returns the cats currently employed by the agency.
+func (db *CatRepository) List(ctx context.Context) ([]models.Cat, error) {
 	list := []models.Cat{}
-	query := "SELECT * FROM cats;"
+	query := "SELECT " + catColumns + " FROM cats WHERE fired_at IS NULL;"
 
-	rows, err := db.Query(query)
+	rows, err := dbtx(ctx, db.DB).QueryContext(ctx, query)
 
 	if err != nil {
-		return nil, appErrors.ErrDatabase
+		return nil, appErrors.DBError("CatRepository.List", err)
 	}
 
 	defer rows.Close()
 
 	for rows.Next() {
-		var cat models.Cat
-		if err := rows.Scan(
-			&cat.ID,
-			&cat.Name,
-			&cat.YearsOfExperience,
-			&cat.Breed,
-			&cat.Salary,
-			&cat.CreatedAt,
-		); err != nil {
-			return nil, appErrors.ErrDatabase
+		cat, err := scanCat(rows)
+		if err != nil {
+			return nil, appErrors.DBError("CatRepository.List", err)
 		}
 		list = append(list, cat)
 	}
 
 	if err := rows.Close(); err != nil {
-		return nil, appErrors.ErrDatabase
+		return nil, appErrors.DBError("CatRepository.List", err)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, appErrors.ErrDatabase
+		return nil, appErrors.DBError("CatRepository.List", err)
 	}
 
 	return list, nil
 }
 
-func (db *CatRepository) Get(id uint) (*models.Cat, error) {
-	var res models.Cat
-	query := "SELECT * FROM cats WHERE id = $1;"
+// Get returns the cat whether it is employed or fired.
+func (db *CatRepository) Get(ctx context.Context, id uint) (*models.Cat, error) {
+	query := "SELECT " + catColumns + " FROM cats WHERE id = $1;"
 
-	row := db.QueryRow(query, id)
+	row := dbtx(ctx, db.DB).QueryRowContext(ctx, query, id)
 
-	err := row.Scan(
-		&res.ID,
-		&res.Name,
-		&res.YearsOfExperience,
-		&res.Breed,
-		&res.Salary,
-		&res.CreatedAt,
-	)
+	res, err := scanCat(row)
 
+	if err == sql.ErrNoRows {
+		return nil, appErrors.ErrNotFound
+	}
 	if err != nil {
-		return nil, appErrors.ErrDatabase
+		return nil, appErrors.DBError("CatRepository.Get", err)
 	}
 
 	return &res, nil
 
 }
+
+// Lock reads the cat and locks its row until the surrounding transaction
+// ends, so firing cannot race with an assignment of the same cat.
+func (db *CatRepository) Lock(ctx context.Context, id uint) (*models.Cat, error) {
+	query := "SELECT " + catColumns + " FROM cats WHERE id = $1 FOR UPDATE;"
+
+	row := dbtx(ctx, db.DB).QueryRowContext(ctx, query, id)
+
+	res, err := scanCat(row)
+
+	if err == sql.ErrNoRows {
+		return nil, appErrors.ErrNotFound
+	}
+	if err != nil {
+		return nil, appErrors.DBError("CatRepository.Lock", err)
+	}
+
+	return &res, nil
+}
+
+func (db *CatRepository) HasActiveMission(ctx context.Context, id uint) (bool, error) {
+	var exists bool
+	query := "SELECT EXISTS (SELECT 1 FROM missions WHERE cat_id = $1 AND status IN ('assigned', 'in_progress'));"
+
+	if err := dbtx(ctx, db.DB).QueryRowContext(ctx, query, id).Scan(&exists); err != nil {
+		return false, appErrors.DBError("CatRepository.HasActiveMission", err)
+	}
+
+	return exists, nil
+}
+
+func (db *CatRepository) AddEmploymentEvent(ctx context.Context, event models.EmploymentEvent) error {
+	query := "INSERT INTO cat_employment_events (cat_id, event, reason) VALUES ($1, $2, $3);"
+
+	_, err := dbtx(ctx, db.DB).ExecContext(ctx, query, event.CatID, event.Event, event.Reason)
+
+	if err != nil {
+		return appErrors.DBError("CatRepository.AddEmploymentEvent", err)
+	}
+
+	return nil
+}
+
+// ListEmploymentEvents returns the hire, fire and rehire events of the cat,
+// oldest first.
+func (db *CatRepository) ListEmploymentEvents(ctx context.Context, catID uint) ([]models.EmploymentEvent, error) {
+	list := []models.EmploymentEvent{}
+	query := "SELECT id, cat_id, event, reason, created_at FROM cat_employment_events WHERE cat_id = $1 ORDER BY created_at, id;"
+
+	rows, err := dbtx(ctx, db.DB).QueryContext(ctx, query, catID)
+	if err != nil {
+		return nil, appErrors.DBError("CatRepository.ListEmploymentEvents", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var event models.EmploymentEvent
+		if err := rows.Scan(
+			&event.ID,
+			&event.CatID,
+			&event.Event,
+			&event.Reason,
+			&event.CreatedAt,
+		); err != nil {
+			return nil, appErrors.DBError("CatRepository.ListEmploymentEvents", err)
+		}
+		list = append(list, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, appErrors.DBError("CatRepository.ListEmploymentEvents", err)
+	}
+
+	return list, nil
+}
+
+var catSortColumns = map[string]sortColumn{
+	"id":                  {"id", "BIGINT"},
+	"name":                {"name", "VARCHAR"},
+	"salary":              {"salary", "NUMERIC"},
+	"years_of_experience": {"years_of_experience", "SMALLINT"},
+	"created_at":          {"created_at", "TIMESTAMPTZ"},
+}
+
+func catSortValue(cat models.Cat, column string) string {
+	switch column {
+	case "name":
+		return cat.Name
+	case "salary":
+		return cat.Salary.String()
+	case "years_of_experience":
+		return strconv.FormatUint(uint64(cat.YearsOfExperience), 10)
+	case "created_at":
+		return cat.CreatedAt.Format(time.RFC3339Nano)
+	}
+	return ""
+}
+
+func (db *CatRepository) QueryCats(ctx context.Context, filter models.CatFilter) (models.Page[models.Cat], error) {
+	page := models.Page[models.Cat]{List: []models.Cat{}}
+
+	where := &whereClause{}
+	if !filter.IncludeFired {
+		where.add("fired_at IS NULL")
+	}
+	if filter.Breed != "" {
+		where.add("breed = ?", filter.Breed)
+	}
+	if filter.MinSalary > 0 {
+		where.add("salary >= ?", filter.MinSalary)
+	}
+	if filter.MaxSalary > 0 {
+		where.add("salary <= ?", filter.MaxSalary)
+	}
+	if filter.MinExperience != nil {
+		where.add("years_of_experience >= ?", *filter.MinExperience)
+	}
+	if filter.MaxExperience != nil {
+		where.add("years_of_experience <= ?", *filter.MaxExperience)
+	}
+
+	countWhere := where.clone()
+	query := "SELECT COUNT(*) FROM cats" + countWhere.String() + ";"
+	if err := dbtx(ctx, db.DB).QueryRowContext(ctx, query, countWhere.args...).Scan(&page.Total); err != nil {
+		return page, appErrors.DBError("CatRepository.QueryCats", err)
+	}
+
+	order := parseSort(filter.Sort, catSortColumns)
+	tail, err := paginate(where, order, filter.After, filter.Limit)
+	if err != nil {
+		return page, err
+	}
+
+	query = "SELECT " + catColumns + " FROM cats" + where.String() + tail + ";"
+	rows, err := dbtx(ctx, db.DB).QueryContext(ctx, query, where.args...)
+	if err != nil {
+		return page, appErrors.DBError("CatRepository.QueryCats", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		cat, err := scanCat(rows)
+		if err != nil {
+			return page, appErrors.DBError("CatRepository.QueryCats", err)
+		}
+		page.List = append(page.List, cat)
+	}
+
+	if err := rows.Err(); err != nil {
+		return page, appErrors.DBError("CatRepository.QueryCats", err)
+	}
+
+	if size := pageSize(filter.Limit); len(page.List) > size {
+		page.List = page.List[:size]
+		last := page.List[size-1]
+		page.NextCursor = encodeCursor(order, catSortValue(last, order.column.name), last.ID)
+	}
+
+	return page, nil
+}
@@ -0,0 +1,74 @@
+CREATE TABLE "breeds" (
+"id" VARCHAR PRIMARY KEY,
+"name" VARCHAR NOT NULL UNIQUE,
+"origin" VARCHAR NOT NULL DEFAULT ''
+);
+
+INSERT INTO "breeds" ("id", "name", "origin") VALUES
+('abys', 'Abyssinian', 'Egypt'),
+('aege', 'Aegean', 'Greece'),
+('abob', 'American Bobtail', 'United States'),
+('acur', 'American Curl', 'United States'),
+('asho', 'American Shorthair', 'United States'),
+('awir', 'American Wirehair', 'United States'),
+('amau', 'Arabian Mau', 'United Arab Emirates'),
+('amis', 'Australian Mist', 'Australia'),
+('bali', 'Balinese', 'United States'),
+('bamb', 'Bambino', 'United States'),
+('beng', 'Bengal', 'United States'),
+('birm', 'Birman', 'France'),
+('bomb', 'Bombay', 'United States'),
+('bslo', 'British Longhair', 'United Kingdom'),
+('bsho', 'British Shorthair', 'United Kingdom'),
+('bure', 'Burmese', 'Burma'),
+('buri', 'Burmilla', 'United Kingdom'),
+('cspa', 'California Spangled', 'United States'),
+('ctif', 'Chantilly-Tiffany', 'United States'),
+('char', 'Chartreux', 'France'),
+('chau', 'Chausie', 'Egypt'),
+('chee', 'Cheetoh', 'United States'),
+('csho', 'Colorpoint Shorthair', 'United States'),
+('crex', 'Cornish Rex', 'United Kingdom'),
+('cymr', 'Cymric', 'Canada'),
+('cypr', 'Cyprus', 'Cyprus'),
+('drex', 'Devon Rex', 'United Kingdom'),
+('dons', 'Donskoy', 'Russia'),
+('lihu', 'Dragon Li', 'China'),
+('emau', 'Egyptian Mau', 'Egypt'),
+('ebur', 'European Burmese', 'Burma'),
+('esho', 'Exotic Shorthair', 'United States'),
+('hbro', 'Havana Brown', 'United Kingdom'),
+('hima', 'Himalayan', 'United States'),
+('jbob', 'Japanese Bobtail', 'Japan'),
+('java', 'Javanese', 'United States'),
+('khao', 'Khao Manee', 'Thailand'),
+('kora', 'Korat', 'Thailand'),
+('kuri', 'Kurilian', 'Russia'),
+('lape', 'LaPerm', 'Thailand'),
+('mcoo', 'Maine Coon', 'United States'),
+('mala', 'Malayan', 'United Kingdom'),
+('manx', 'Manx', 'Isle of Man'),
+('munc', 'Munchkin', 'United States'),
+('nebe', 'Nebelung', 'United States'),
+('norw', 'Norwegian Forest Cat', 'Norway'),
+('ocic', 'Ocicat', 'United States'),
+('orie', 'Oriental', 'United States'),
+('pers', 'Persian', 'Iran (Persia)'),
+('pixi', 'Pixie-bob', 'United States'),
+('raga', 'Ragamuffin', 'United States'),
+('ragd', 'Ragdoll', 'United States'),
+('rblu', 'Russian Blue', 'Russia'),
+('sava', 'Savannah', 'United States'),
+('sfol', 'Scottish Fold', 'United Kingdom'),
+('srex', 'Selkirk Rex', 'United States'),
+('siam', 'Siamese', 'Thailand'),
+('sibe', 'Siberian', 'Russia'),
+('sing', 'Singapura', 'Singapore'),
+('snow', 'Snowshoe', 'United States'),
+('soma', 'Somali', 'Somalia'),
+('sphy', 'Sphynx', 'Canada'),
+('tonk', 'Tonkinese', 'Canada'),
+('toyg', 'Toyger', 'United States'),
+('tang', 'Turkish Angora', 'Turkey'),
+('tvan', 'Turkish Van', 'Turkey'),
+('ycho', 'York Chocolate', 'United States');
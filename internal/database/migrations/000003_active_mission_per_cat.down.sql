@@ -0,0 +1 @@
+DROP INDEX IF EXISTS "missions_active_cat_id_idx";
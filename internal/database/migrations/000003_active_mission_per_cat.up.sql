@@ -0,0 +1 @@
+CREATE UNIQUE INDEX "missions_active_cat_id_idx" ON "missions" ("cat_id") WHERE "cat_id" IS NOT NULL AND "is_completed" = FALSE;
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS "targets_mission_id_idx";
@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS "targets_mission_id_idx" ON "targets" ("mission_id");
@@ -0,0 +1,12 @@
+CREATE TABLE "api_keys" (
+"id" BIGSERIAL PRIMARY KEY,
+"name" VARCHAR NOT NULL,
+"key_hash" CHAR(64) NOT NULL UNIQUE,
+"role" VARCHAR NOT NULL CHECK ("role" IN ('handler', 'agent')),
+"cat_id" BIGINT DEFAULT NULL,
+"created_at" TIMESTAMPTZ NOT NULL DEFAULT (NOW()),
+"revoked_at" TIMESTAMPTZ DEFAULT NULL,
+CHECK ("role" <> 'agent' OR "cat_id" IS NOT NULL)
+);
+
+ALTER TABLE "api_keys" ADD FOREIGN KEY ("cat_id") REFERENCES "cats" ("id") ON DELETE CASCADE;
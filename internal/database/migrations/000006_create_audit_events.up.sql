@@ -0,0 +1,15 @@
+CREATE TABLE "audit_events" (
+"id" BIGSERIAL PRIMARY KEY,
+"actor" VARCHAR NOT NULL,
+"actor_key_id" BIGINT DEFAULT NULL,
+"action" VARCHAR NOT NULL,
+"entity_type" VARCHAR NOT NULL,
+"entity_id" BIGINT NOT NULL,
+"before" JSONB DEFAULT NULL,
+"after" JSONB DEFAULT NULL,
+"created_at" TIMESTAMPTZ NOT NULL DEFAULT (NOW())
+);
+
+CREATE INDEX "audit_events_entity_idx" ON "audit_events" ("entity_type", "entity_id");
+CREATE INDEX "audit_events_actor_idx" ON "audit_events" ("actor");
+CREATE INDEX "audit_events_created_at_idx" ON "audit_events" ("created_at");
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS "cat_employment_events";
+
+DELETE FROM "cats" WHERE "fired_at" IS NOT NULL AND NOT EXISTS (SELECT 1 FROM "missions" WHERE "missions"."cat_id" = "cats"."id");
+
+DROP INDEX IF EXISTS "cats_employed_idx";
+ALTER TABLE "cats" DROP COLUMN IF EXISTS "fired_reason";
+ALTER TABLE "cats" DROP COLUMN IF EXISTS "fired_at";
@@ -0,0 +1,19 @@
+ALTER TABLE "cats" ADD COLUMN "fired_at" TIMESTAMPTZ DEFAULT NULL;
+ALTER TABLE "cats" ADD COLUMN "fired_reason" VARCHAR DEFAULT NULL;
+
+CREATE INDEX "cats_employed_idx" ON "cats" ("id") WHERE "fired_at" IS NULL;
+
+CREATE TABLE "cat_employment_events" (
+"id" BIGSERIAL PRIMARY KEY,
+"cat_id" BIGINT NOT NULL,
+"event" VARCHAR NOT NULL CHECK ("event" IN ('hire', 'fire', 'rehire')),
+"reason" VARCHAR DEFAULT NULL,
+"created_at" TIMESTAMPTZ NOT NULL DEFAULT (NOW())
+);
+
+ALTER TABLE "cat_employment_events" ADD FOREIGN KEY ("cat_id") REFERENCES "cats" ("id");
+
+CREATE INDEX "cat_employment_events_cat_id_idx" ON "cat_employment_events" ("cat_id");
+
+INSERT INTO "cat_employment_events" ("cat_id", "event", "created_at")
+SELECT "id", 'hire', "created_at" FROM "cats";
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS "salary_changes";
+
+ALTER TABLE "cats" DROP COLUMN IF EXISTS "currency";
+ALTER TABLE "cats" ALTER COLUMN "salary" TYPE DECIMAL;
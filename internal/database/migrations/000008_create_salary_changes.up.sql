@@ -0,0 +1,17 @@
+ALTER TABLE "cats" ALTER COLUMN "salary" TYPE NUMERIC(14, 2);
+ALTER TABLE "cats" ADD COLUMN "currency" CHAR(3) NOT NULL DEFAULT 'USD';
+
+CREATE TABLE "salary_changes" (
+"id" BIGSERIAL PRIMARY KEY,
+"cat_id" BIGINT NOT NULL,
+"amount" NUMERIC(14, 2) NOT NULL CHECK ("amount" > 0),
+"currency" CHAR(3) NOT NULL,
+"effective_from" DATE NOT NULL,
+"created_at" TIMESTAMPTZ NOT NULL DEFAULT (NOW()),
+UNIQUE ("cat_id", "effective_from")
+);
+
+ALTER TABLE "salary_changes" ADD FOREIGN KEY ("cat_id") REFERENCES "cats" ("id");
+
+INSERT INTO "salary_changes" ("cat_id", "amount", "currency", "effective_from", "created_at")
+SELECT "id", "salary", "currency", ("created_at" AT TIME ZONE 'UTC')::DATE, "created_at" FROM "cats";
@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS "mission_transitions";
+
+ALTER TABLE "missions" ADD COLUMN "is_completed" BOOLEAN NOT NULL DEFAULT FALSE;
+UPDATE "missions" SET "is_completed" = "status" IN ('completed', 'aborted', 'failed');
+
+DROP INDEX IF EXISTS "missions_active_cat_id_idx";
+ALTER TABLE "missions" DROP COLUMN "status";
+CREATE UNIQUE INDEX "missions_active_cat_id_idx" ON "missions" ("cat_id") WHERE "cat_id" IS NOT NULL AND "is_completed" = FALSE;
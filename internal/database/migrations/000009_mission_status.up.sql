@@ -0,0 +1,28 @@
+ALTER TABLE "missions" ADD COLUMN "status" VARCHAR NOT NULL DEFAULT 'draft'
+CHECK ("status" IN ('draft', 'assigned', 'in_progress', 'completed', 'aborted', 'failed'));
+
+UPDATE "missions" SET "status" = CASE
+    WHEN "is_completed" THEN 'completed'
+    WHEN "cat_id" IS NOT NULL THEN 'assigned'
+    ELSE 'draft'
+END;
+
+DROP INDEX IF EXISTS "missions_active_cat_id_idx";
+ALTER TABLE "missions" DROP COLUMN "is_completed";
+CREATE UNIQUE INDEX "missions_active_cat_id_idx" ON "missions" ("cat_id") WHERE "cat_id" IS NOT NULL AND "status" IN ('assigned', 'in_progress');
+
+CREATE TABLE "mission_transitions" (
+"id" BIGSERIAL PRIMARY KEY,
+"mission_id" BIGINT NOT NULL,
+"from_status" VARCHAR DEFAULT NULL,
+"to_status" VARCHAR NOT NULL,
+"actor" VARCHAR NOT NULL,
+"created_at" TIMESTAMPTZ NOT NULL DEFAULT (NOW())
+);
+
+ALTER TABLE "mission_transitions" ADD FOREIGN KEY ("mission_id") REFERENCES "missions" ("id") ON DELETE CASCADE;
+
+CREATE INDEX "mission_transitions_mission_id_idx" ON "mission_transitions" ("mission_id");
+
+INSERT INTO "mission_transitions" ("mission_id", "from_status", "to_status", "actor", "created_at")
+SELECT "id", NULL, "status", 'system', "created_at" FROM "missions";
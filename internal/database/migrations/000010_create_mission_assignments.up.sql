@@ -0,0 +1,20 @@
+CREATE TABLE "mission_assignments" (
+"id" BIGSERIAL PRIMARY KEY,
+"mission_id" BIGINT NOT NULL,
+"cat_id" BIGINT NOT NULL,
+"assigned_at" TIMESTAMPTZ NOT NULL DEFAULT (NOW()),
+"released_at" TIMESTAMPTZ DEFAULT NULL
+);
+
+ALTER TABLE "mission_assignments" ADD FOREIGN KEY ("mission_id") REFERENCES "missions" ("id") ON DELETE CASCADE;
+ALTER TABLE "mission_assignments" ADD FOREIGN KEY ("cat_id") REFERENCES "cats" ("id");
+
+CREATE INDEX "mission_assignments_mission_id_idx" ON "mission_assignments" ("mission_id");
+CREATE INDEX "mission_assignments_cat_id_idx" ON "mission_assignments" ("cat_id");
+CREATE UNIQUE INDEX "mission_assignments_open_idx" ON "mission_assignments" ("mission_id") WHERE "released_at" IS NULL;
+
+INSERT INTO "mission_assignments" ("mission_id", "cat_id", "assigned_at", "released_at")
+SELECT "id", "cat_id", "created_at",
+    CASE WHEN "status" IN ('assigned', 'in_progress') THEN NULL
+    ELSE (SELECT MAX("created_at") FROM "mission_transitions" WHERE "mission_transitions"."mission_id" = "missions"."id") END
+FROM "missions" WHERE "cat_id" IS NOT NULL;
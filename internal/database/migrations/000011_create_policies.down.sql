@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS "policies";
+
+-- Restores the one active mission per cat guarantee of 000003. It fails if
+-- a cat has several active missions, which must be resolved first.
+DROP INDEX IF EXISTS "missions_active_cat_id_idx";
+CREATE UNIQUE INDEX "missions_active_cat_id_idx" ON "missions" ("cat_id") WHERE "cat_id" IS NOT NULL AND "status" IN ('assigned', 'in_progress');
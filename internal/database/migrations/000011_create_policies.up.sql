@@ -0,0 +1,22 @@
+CREATE TABLE "policies" (
+"id" SMALLINT PRIMARY KEY DEFAULT 1 CHECK ("id" = 1),
+"document" JSONB NOT NULL,
+"updated_at" TIMESTAMPTZ NOT NULL DEFAULT (NOW())
+);
+
+INSERT INTO "policies" ("document") VALUES ('{
+  "targets_per_mission": {"min": 1, "max": 3},
+  "max_active_missions_per_cat": 1,
+  "min_experience_by_priority": {"default": 0},
+  "salary_range_by_breed": {}
+}');
+
+-- The number of active missions per cat is a policy setting now, so the
+-- partial index can no longer be UNIQUE: a policy allowing several missions
+-- would violate it. The limit is enforced by MissionService alone, which
+-- counts the active missions under the cat row lock. Writes that bypass the
+-- service, such as manual fixes or other DAO callers, must keep to the
+-- policy themselves; the database does not stop them from giving a cat more
+-- missions than max_active_missions_per_cat. The index is kept for the count.
+DROP INDEX IF EXISTS "missions_active_cat_id_idx";
+CREATE INDEX "missions_active_cat_id_idx" ON "missions" ("cat_id") WHERE "cat_id" IS NOT NULL AND "status" IN ('assigned', 'in_progress');
@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS "missions_open_deadline_idx";
+
+ALTER TABLE "targets"
+  DROP COLUMN IF EXISTS "deadline",
+  DROP COLUMN IF EXISTS "priority";
+
+ALTER TABLE "missions"
+  DROP COLUMN IF EXISTS "overdue_at",
+  DROP COLUMN IF EXISTS "deadline",
+  DROP COLUMN IF EXISTS "priority";
@@ -0,0 +1,14 @@
+-- Priorities are stored as ranks, 1 (low) to 4 (critical), so that ordering
+-- by the column orders by urgency.
+ALTER TABLE "missions"
+  ADD COLUMN "priority" SMALLINT NOT NULL DEFAULT 2 CHECK ("priority" BETWEEN 1 AND 4),
+  ADD COLUMN "deadline" TIMESTAMPTZ,
+  ADD COLUMN "overdue_at" TIMESTAMPTZ;
+
+ALTER TABLE "targets"
+  ADD COLUMN "priority" SMALLINT NOT NULL DEFAULT 2 CHECK ("priority" BETWEEN 1 AND 4),
+  ADD COLUMN "deadline" TIMESTAMPTZ;
+
+-- The scheduler looks for open missions past their deadline that it has not
+-- marked yet.
+CREATE INDEX "missions_open_deadline_idx" ON "missions" ("deadline") WHERE "deadline" IS NOT NULL AND "status" IN ('draft', 'assigned', 'in_progress');
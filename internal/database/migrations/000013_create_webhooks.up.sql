@@ -0,0 +1,36 @@
+CREATE TABLE "webhooks" (
+"id" BIGSERIAL PRIMARY KEY,
+"url" VARCHAR NOT NULL,
+"events" VARCHAR[] NOT NULL DEFAULT '{}',
+"secret" VARCHAR NOT NULL,
+"created_at" TIMESTAMPTZ NOT NULL DEFAULT (NOW())
+);
+
+-- Events are written here in the transaction of the change they describe.
+CREATE TABLE "outbox_events" (
+"id" BIGSERIAL PRIMARY KEY,
+"type" VARCHAR NOT NULL,
+"mission_id" BIGINT,
+"cat_id" BIGINT,
+"payload" JSONB NOT NULL,
+"created_at" TIMESTAMPTZ NOT NULL DEFAULT (NOW())
+);
+
+CREATE TABLE "webhook_deliveries" (
+"id" BIGSERIAL PRIMARY KEY,
+"webhook_id" BIGINT NOT NULL,
+"event_id" BIGINT NOT NULL,
+"status" VARCHAR NOT NULL DEFAULT 'pending' CHECK ("status" IN ('pending', 'delivered', 'dead')),
+"attempts" INT NOT NULL DEFAULT 0,
+"next_attempt_at" TIMESTAMPTZ NOT NULL DEFAULT (NOW()),
+"last_error" TEXT,
+"response_status" INT,
+"created_at" TIMESTAMPTZ NOT NULL DEFAULT (NOW()),
+"delivered_at" TIMESTAMPTZ
+);
+
+ALTER TABLE "webhook_deliveries" ADD FOREIGN KEY ("webhook_id") REFERENCES "webhooks" ("id") ON DELETE CASCADE;
+ALTER TABLE "webhook_deliveries" ADD FOREIGN KEY ("event_id") REFERENCES "outbox_events" ("id") ON DELETE CASCADE;
+
+CREATE INDEX "webhook_deliveries_webhook_id_idx" ON "webhook_deliveries" ("webhook_id");
+CREATE INDEX "webhook_deliveries_due_idx" ON "webhook_deliveries" ("next_attempt_at") WHERE "status" = 'pending';
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS "target_notes";
+
+ALTER TABLE "targets" DROP COLUMN IF EXISTS "notes_revision";
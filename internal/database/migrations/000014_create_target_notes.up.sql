@@ -0,0 +1,21 @@
+ALTER TABLE "targets" ADD COLUMN "notes_revision" INT NOT NULL DEFAULT 0;
+
+-- Every change of a target's notes is kept as a revision. targets.notes and
+-- targets.notes_revision hold the latest one.
+CREATE TABLE "target_notes" (
+"id" BIGSERIAL PRIMARY KEY,
+"target_id" BIGINT NOT NULL,
+"revision" INT NOT NULL,
+"notes" VARCHAR NOT NULL,
+"author" VARCHAR NOT NULL,
+"author_key_id" BIGINT,
+"created_at" TIMESTAMPTZ NOT NULL DEFAULT (NOW()),
+UNIQUE ("target_id", "revision")
+);
+
+ALTER TABLE "target_notes" ADD FOREIGN KEY ("target_id") REFERENCES "targets" ("id") ON DELETE CASCADE;
+
+INSERT INTO "target_notes" ("target_id", "revision", "notes", "author", "created_at")
+SELECT "id", 1, "notes", 'system', "created_at" FROM "targets" WHERE "notes" <> '';
+
+UPDATE "targets" SET "notes_revision" = 1 WHERE "notes" <> '';
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS "salary_changes_cat_id_effective_from_idx";
+
+-- Only the change recorded last on a day was in effect.
+DELETE FROM "salary_changes" AS "s" USING "salary_changes" AS "later"
+WHERE "later"."cat_id" = "s"."cat_id" AND "later"."effective_from" = "s"."effective_from" AND "later"."id" > "s"."id";
+
+ALTER TABLE "salary_changes" ADD CONSTRAINT "salary_changes_cat_id_effective_from_key" UNIQUE ("cat_id", "effective_from");
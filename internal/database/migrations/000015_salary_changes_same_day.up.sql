@@ -0,0 +1,5 @@
+-- Several changes may take effect on the same day, for example a raise on
+-- the hire day. All of them are kept; the one recorded last is in effect.
+ALTER TABLE "salary_changes" DROP CONSTRAINT IF EXISTS "salary_changes_cat_id_effective_from_key";
+
+CREATE INDEX "salary_changes_cat_id_effective_from_idx" ON "salary_changes" ("cat_id", "effective_from", "id");
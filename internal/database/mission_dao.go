@@ -1,9 +1,14 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	appErrors "spy_cat_agency/internal/appErorrs"
 	"spy_cat_agency/internal/models"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
 )
 
 type MissionRepository struct {
@@ -16,284 +21,741 @@ func NewMissionRepository(db *sql.DB) *MissionRepository {
 	}
 }
 
-func (db *MissionRepository) AddMission(mission models.Mission) error {
+const missionColumns = "id, name, cat_id, status, priority, deadline, overdue_at, created_at"
 
-	var res models.Mission
+func scanMission(row rowScanner) (models.Mission, error) {
+	mission := models.Mission{TargetList: make([]models.Target, 0)}
+	err := row.Scan(
+		&mission.ID,
+		&mission.Name,
+		&mission.CatId,
+		&mission.Status,
+		&mission.Priority,
+		&mission.Deadline,
+		&mission.OverdueAt,
+		&mission.CreatedAt,
+	)
+
+	return mission, err
+}
 
-	res.TargetList = make([]models.Target, 0)
-	query := "INSERT INTO missions (name) VALUES($1) RETURNING id, name, cat_id, is_completed, created_at;"
-	row := db.QueryRow(query, mission.Name)
+const targetColumns = "id, mission_id, name, country, notes, notes_revision, is_completed, priority, deadline, created_at"
 
+func scanTarget(row rowScanner) (models.Target, error) {
+	var target models.Target
 	err := row.Scan(
-		&res.ID,
-		&res.Name,
-		&res.CatId,
-		&res.IsCompleted,
-		&res.CreatedAt,
+		&target.ID,
+		&target.MissionID,
+		&target.Name,
+		&target.Country,
+		&target.Notes,
+		&target.NotesRevision,
+		&target.IsCompleted,
+		&target.Priority,
+		&target.Deadline,
+		&target.CreatedAt,
 	)
+
+	return target, err
+}
+
+// AddMission inserts a draft mission with its targets. Assigning a cat is a
+// separate transition.
+func (db *MissionRepository) AddMission(ctx context.Context, mission models.Mission) (*models.Mission, error) {
+
+	query := "INSERT INTO missions (name, priority, deadline) VALUES($1, $2, $3) RETURNING " + missionColumns + ";"
+	row := dbtx(ctx, db.DB).QueryRowContext(ctx, query, mission.Name, mission.Priority, mission.Deadline)
+
+	res, err := scanMission(row)
 	if err != nil {
 
-		return appErrors.ErrDatabase
+		return nil, appErrors.DBError("MissionRepository.AddMission", err)
 	}
 
-	if mission.CatId != nil {
-		err = db.Assign(res.ID, *mission.CatId)
+	for _, v := range mission.TargetList {
+		target, err := db.AddTarget(ctx, res.ID, v)
 		if err != nil {
-			return appErrors.ErrDatabase
+			return nil, err
 		}
+		res.TargetList = append(res.TargetList, *target)
 	}
 
-	for _, v := range mission.TargetList {
-		query := "INSERT INTO targets (name, country, notes, mission_id) VALUES ($1, $2, $3, $4);"
-		_, err = db.Exec(query, v.Name, v.Country, v.Notes, res.ID)
-		if err != nil {
-			return appErrors.ErrDatabase
+	return &res, nil
+}
+
+// Assign sets the cat and moves the mission to assigned only if it is still
+// an unassigned draft, so a concurrent assignment that won the race makes this
+// one fail with a conflict instead of being overwritten.
+func (db *MissionRepository) Assign(ctx context.Context, missionId, catId uint) error {
+	query := "UPDATE missions SET cat_id = $1, status = 'assigned' WHERE id = $2 AND cat_id IS NULL AND status = 'draft';"
+	res, err := dbtx(ctx, db.DB).ExecContext(ctx, query, catId, missionId)
+
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok {
+			switch pqErr.Code.Name() {
+			case "foreign_key_violation":
+				return appErrors.ErrCatNotFound
+			}
 		}
+
+		return appErrors.DBError("MissionRepository.Assign", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return appErrors.DBError("MissionRepository.Assign", err)
+	}
+
+	if affected == 0 {
+		return appErrors.ErrMissionAlreadyAssigned
 	}
 
 	return nil
 }
 
-func (db *MissionRepository) Assign(missionId, catId uint) error {
-	query := "UPDATE missions SET cat_id = $1 WHERE id = $2 ;"
-	_, err := db.Exec(query, catId, missionId)
+// Unassign takes the cat off an active mission and puts the mission back to
+// draft.
+func (db *MissionRepository) Unassign(ctx context.Context, missionId uint) error {
+	query := "UPDATE missions SET cat_id = NULL, status = 'draft' WHERE id = $1 AND cat_id IS NOT NULL AND status IN ('assigned', 'in_progress');"
+	res, err := dbtx(ctx, db.DB).ExecContext(ctx, query, missionId)
 
 	if err != nil {
+		return appErrors.DBError("MissionRepository.Unassign", err)
+	}
 
-		return appErrors.ErrDatabase
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return appErrors.DBError("MissionRepository.Unassign", err)
+	}
+
+	if affected == 0 {
+		return appErrors.ErrMissionNotAssigned
 	}
 
 	return nil
 }
 
-func (db *MissionRepository) GetMissionByID(id uint) (*models.Mission, error) {
-	var mission models.Mission
-	mission.TargetList = make([]models.Target, 0)
-	query := "SELECT * FROM missions WHERE id = $1;"
-	row := db.QueryRow(query, id)
+// Reassign hands an active mission over to another cat, keeping its status
+// and targets.
+func (db *MissionRepository) Reassign(ctx context.Context, missionId, catId uint) error {
+	query := "UPDATE missions SET cat_id = $1 WHERE id = $2 AND cat_id IS NOT NULL AND status IN ('assigned', 'in_progress');"
+	res, err := dbtx(ctx, db.DB).ExecContext(ctx, query, catId, missionId)
 
-	err := row.Scan(
-		&mission.ID,
-		&mission.Name,
-		&mission.CatId,
-		&mission.IsCompleted,
-		&mission.CreatedAt,
-	)
 	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok {
+			switch pqErr.Code.Name() {
+			case "foreign_key_violation":
+				return appErrors.ErrCatNotFound
+			}
+		}
 
-		return nil, appErrors.ErrDatabase
+		return appErrors.DBError("MissionRepository.Reassign", err)
 	}
 
-	query = "SELECT * FROM targets WHERE mission_id = $1;"
-	rows, err := db.Query(query, mission.ID)
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return appErrors.DBError("MissionRepository.Reassign", err)
+	}
+
+	if affected == 0 {
+		return appErrors.ErrMissionNotAssigned
+	}
+
+	return nil
+}
+
+// OpenAssignment starts a period of the cat working on the mission.
+func (db *MissionRepository) OpenAssignment(ctx context.Context, missionId, catId uint) error {
+	query := "INSERT INTO mission_assignments (mission_id, cat_id) VALUES ($1, $2);"
+	_, err := dbtx(ctx, db.DB).ExecContext(ctx, query, missionId, catId)
+
+	if err != nil {
+		return appErrors.DBError("MissionRepository.OpenAssignment", err)
+	}
+
+	return nil
+}
+
+// CloseAssignment ends the current assignment period of the mission, if any.
+func (db *MissionRepository) CloseAssignment(ctx context.Context, missionId uint) error {
+	query := "UPDATE mission_assignments SET released_at = NOW() WHERE mission_id = $1 AND released_at IS NULL;"
+	_, err := dbtx(ctx, db.DB).ExecContext(ctx, query, missionId)
 
 	if err != nil {
-		return nil, appErrors.ErrDatabase
+		return appErrors.DBError("MissionRepository.CloseAssignment", err)
+	}
+
+	return nil
+}
+
+// ListAssignments returns the assignment periods of a mission or of a cat,
+// oldest first. Zero ids are ignored.
+func (db *MissionRepository) ListAssignments(ctx context.Context, missionId, catId uint) ([]models.MissionAssignment, error) {
+	list := []models.MissionAssignment{}
+
+	where := &whereClause{}
+	if missionId != 0 {
+		where.add("mission_id = ?", missionId)
+	}
+	if catId != 0 {
+		where.add("cat_id = ?", catId)
+	}
+
+	query := "SELECT id, mission_id, cat_id, assigned_at, released_at FROM mission_assignments" + where.String() + " ORDER BY assigned_at, id;"
+	rows, err := dbtx(ctx, db.DB).QueryContext(ctx, query, where.args...)
+	if err != nil {
+		return nil, appErrors.DBError("MissionRepository.ListAssignments", err)
 	}
 	defer rows.Close()
+
 	for rows.Next() {
-		var target models.Target
+		var assignment models.MissionAssignment
 		if err := rows.Scan(
-			&target.ID,
-			&target.MissionID,
-			&target.Name,
-			&target.Country,
-			&target.Notes,
-			&target.IsCompleted,
-			&target.CreatedAt,
+			&assignment.ID,
+			&assignment.MissionID,
+			&assignment.CatID,
+			&assignment.AssignedAt,
+			&assignment.ReleasedAt,
 		); err != nil {
-			return nil, appErrors.ErrDatabase
+			return nil, appErrors.DBError("MissionRepository.ListAssignments", err)
 		}
-		mission.TargetList = append(mission.TargetList, target)
+		list = append(list, assignment)
 	}
 
-	return &mission, nil
+	if err := rows.Err(); err != nil {
+		return nil, appErrors.DBError("MissionRepository.ListAssignments", err)
+	}
+
+	return list, nil
 }
 
-func (db *MissionRepository) GetMissionByCatID(catID uint) (*models.Mission, error) {
-	var mission models.Mission
-	mission.TargetList = make([]models.Target, 0)
-	query := "SELECT * FROM missions WHERE cat_id = $1;"
-	row := db.QueryRow(query, catID)
+// LockMission reads the mission row with SELECT ... FOR UPDATE. It must be
+// called inside a transaction, the lock is held until it ends.
+func (db *MissionRepository) LockMission(ctx context.Context, id uint) (*models.Mission, error) {
+	query := "SELECT " + missionColumns + " FROM missions WHERE id = $1 FOR UPDATE;"
+	row := dbtx(ctx, db.DB).QueryRowContext(ctx, query, id)
 
-	err := row.Scan(
-		&mission.ID,
-		&mission.Name,
-		&mission.CatId,
-		&mission.IsCompleted,
-		&mission.CreatedAt,
-	)
-	if err != nil && err != sql.ErrNoRows {
+	mission, err := scanMission(row)
+	if err == sql.ErrNoRows {
+		return nil, appErrors.ErrNotFound
+	}
+	if err != nil {
+		return nil, appErrors.DBError("MissionRepository.LockMission", err)
+	}
 
-		return nil, appErrors.ErrDatabase
+	missions := []models.Mission{mission}
+	if err := db.loadTargets(ctx, missions); err != nil {
+		return nil, err
 	}
+	mission = missions[0]
+
+	return &mission, nil
+}
 
-	query = "SELECT * FROM targets WHERE mission_id = $1;"
-	rows, err := db.Query(query, mission.ID)
+// LockCat serializes assignments of the same cat by locking its row until the
+// surrounding transaction ends. Fired cats are reported as not found.
+func (db *MissionRepository) LockCat(ctx context.Context, catID uint) (*models.Cat, error) {
+	query := "SELECT " + catColumns + " FROM cats WHERE id = $1 AND fired_at IS NULL FOR UPDATE;"
+	cat, err := scanCat(dbtx(ctx, db.DB).QueryRowContext(ctx, query, catID))
 
+	if err == sql.ErrNoRows {
+		return nil, appErrors.ErrNotFound
+	}
 	if err != nil {
+		return nil, appErrors.DBError("MissionRepository.LockCat", err)
+	}
+
+	return &cat, nil
+}
+
+func (db *MissionRepository) GetMissionByID(ctx context.Context, id uint) (*models.Mission, error) {
+	query := "SELECT " + missionColumns + " FROM missions WHERE id = $1;"
+	row := dbtx(ctx, db.DB).QueryRowContext(ctx, query, id)
 
-		return nil, appErrors.ErrDatabase
+	mission, err := scanMission(row)
+	if err == sql.ErrNoRows {
+		return nil, appErrors.ErrNotFound
+	}
+	if err != nil {
+		return nil, appErrors.DBError("MissionRepository.GetMissionByID", err)
 	}
-	defer rows.Close()
-	for rows.Next() {
-		var target models.Target
-		if err := rows.Scan(
-			&target.ID,
-			&target.MissionID,
-			&target.Name,
-			&target.Country,
-			&target.Notes,
-			&target.IsCompleted,
-			&target.CreatedAt,
-		); err != nil {
 
-			return nil, appErrors.ErrDatabase
-		}
-		mission.TargetList = append(mission.TargetList, target)
+	missions := []models.Mission{mission}
+	if err := db.loadTargets(ctx, missions); err != nil {
+		return nil, err
 	}
+	mission = missions[0]
 
 	return &mission, nil
 }
 
-func (db *MissionRepository) DeleteMission(id uint) error {
+// CountActiveMissions returns the number of assigned and in-progress
+// missions of the cat.
+func (db *MissionRepository) CountActiveMissions(ctx context.Context, catID uint) (int, error) {
+	var count int
+	query := "SELECT COUNT(*) FROM missions WHERE cat_id = $1 AND status IN ('assigned', 'in_progress');"
+
+	if err := dbtx(ctx, db.DB).QueryRowContext(ctx, query, catID).Scan(&count); err != nil {
+		return 0, appErrors.DBError("MissionRepository.CountActiveMissions", err)
+	}
+
+	return count, nil
+}
+
+func (db *MissionRepository) DeleteMission(ctx context.Context, id uint) error {
 	query := "DELETE FROM missions WHERE id = $1;"
-	_, err := db.Exec(query, id)
+	_, err := dbtx(ctx, db.DB).ExecContext(ctx, query, id)
 	if err != nil {
-		return appErrors.ErrDatabase
+		return appErrors.DBError("MissionRepository.DeleteMission", err)
 	}
 
 	query = "DELETE FROM targets WHERE mission_id = $1;"
-	_, err = db.Exec(query, id)
+	_, err = dbtx(ctx, db.DB).ExecContext(ctx, query, id)
 
 	if err != nil {
-		return appErrors.ErrDatabase
+		return appErrors.DBError("MissionRepository.DeleteMission", err)
 	}
 
 	return nil
 }
 
-func (db *MissionRepository) ListMissions() ([]models.Mission, error) {
+func (db *MissionRepository) ListMissions(ctx context.Context) ([]models.Mission, error) {
 	res := make([]models.Mission, 0)
-	query := "SELECT * FROM missions;"
+	query := "SELECT " + missionColumns + " FROM missions;"
 
-	rows, err := db.Query(query)
+	rows, err := dbtx(ctx, db.DB).QueryContext(ctx, query)
 
 	if err != nil {
 
-		return nil, appErrors.ErrDatabase
+		return nil, appErrors.DBError("MissionRepository.ListMissions", err)
 	}
 	defer rows.Close()
 
 	for rows.Next() {
-		var mission models.Mission
-		mission.TargetList = make([]models.Target, 0)
-		if err := rows.Scan(
-			&mission.ID,
-			&mission.Name,
-			&mission.CatId,
-			&mission.IsCompleted,
-			&mission.CreatedAt,
-		); err != nil {
+		mission, err := scanMission(rows)
+		if err != nil {
 
-			return nil, appErrors.ErrDatabase
+			return nil, appErrors.DBError("MissionRepository.ListMissions", err)
 		}
 		res = append(res, mission)
 	}
 
-	query = "SELECT * FROM targets WHERE mission_id = $1"
-	for i, mission := range res {
-		rows, err := db.Query(query, mission.ID)
-		if err != nil {
+	if err := rows.Err(); err != nil {
+		return nil, appErrors.DBError("MissionRepository.ListMissions", err)
+	}
+	rows.Close()
 
-			return nil, appErrors.ErrDatabase
-		}
-		for rows.Next() {
-			var target models.Target
-			if err := rows.Scan(
-				&target.ID,
-				&target.MissionID,
-				&target.Name,
-				&target.Country,
-				&target.Notes,
-				&target.IsCompleted,
-				&target.CreatedAt,
-			); err != nil {
-
-				return nil, appErrors.ErrDatabase
-			}
+	if err := db.loadTargets(ctx, res); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
 
-			res[i].TargetList = append(res[i].TargetList, target)
-			mission.TargetList = append(mission.TargetList, target)
+// MarkOverdue stamps overdue_at on the open missions whose deadline passed
+// before now and returns them. Missions are marked only once, so each is
+// reported a single time.
+func (db *MissionRepository) MarkOverdue(ctx context.Context, now time.Time) ([]models.Mission, error) {
+	res := make([]models.Mission, 0)
+	query := "UPDATE missions SET overdue_at = $1 WHERE deadline < $1 AND overdue_at IS NULL AND status IN ('draft', 'assigned', 'in_progress') RETURNING " + missionColumns + ";"
+
+	rows, err := dbtx(ctx, db.DB).QueryContext(ctx, query, now)
+	if err != nil {
+		return nil, appErrors.DBError("MissionRepository.MarkOverdue", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		mission, err := scanMission(rows)
+		if err != nil {
+			return nil, appErrors.DBError("MissionRepository.MarkOverdue", err)
 		}
-		rows.Close()
+		res = append(res, mission)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, appErrors.DBError("MissionRepository.MarkOverdue", err)
+	}
+	rows.Close()
+
+	if err := db.loadTargets(ctx, res); err != nil {
+		return nil, err
 	}
 
 	return res, nil
 }
 
-func (db *MissionRepository) UpdateMission(id uint, completed bool) error {
-	query := "UPDATE missions SET is_completed = $1 WHERE id = $2;"
-	_, err := db.Exec(query, completed, id)
+// UpdateStatus moves the mission from one status to another. It fails with
+// ErrInvalidTransition when the mission is no longer in status from.
+func (db *MissionRepository) UpdateStatus(ctx context.Context, id uint, from, to models.MissionStatus) error {
+	query := "UPDATE missions SET status = $1 WHERE id = $2 AND status = $3;"
+	res, err := dbtx(ctx, db.DB).ExecContext(ctx, query, to, id, from)
+
+	if err != nil {
+		return appErrors.DBError("MissionRepository.UpdateStatus", err)
+	}
 
+	affected, err := res.RowsAffected()
 	if err != nil {
-		return appErrors.ErrDatabase
+		return appErrors.DBError("MissionRepository.UpdateStatus", err)
+	}
+
+	if affected == 0 {
+		return appErrors.ErrInvalidTransition
 	}
 
 	return nil
 }
 
-func (db *MissionRepository) GetTarget(id uint) (*models.Target, error) {
-	var target models.Target
-	query := "SELECT * FROM targets WHERE id = $1"
-	row := db.QueryRow(query, id)
-	err := row.Scan(
-		&target.ID,
-		&target.MissionID,
-		&target.Name,
-		&target.Country,
-		&target.Notes,
-		&target.IsCompleted,
-		&target.CreatedAt,
-	)
+func (db *MissionRepository) AddTransition(ctx context.Context, transition models.MissionTransition) error {
+	query := "INSERT INTO mission_transitions (mission_id, from_status, to_status, actor) VALUES ($1, $2, $3, $4);"
+	_, err := dbtx(ctx, db.DB).ExecContext(ctx, query, transition.MissionID, transition.From, transition.To, transition.Actor)
+
+	if err != nil {
+		return appErrors.DBError("MissionRepository.AddTransition", err)
+	}
+
+	return nil
+}
+
+// ListTransitions returns the status history of the mission, oldest first.
+func (db *MissionRepository) ListTransitions(ctx context.Context, missionID uint) ([]models.MissionTransition, error) {
+	list := []models.MissionTransition{}
+	query := "SELECT id, mission_id, from_status, to_status, actor, created_at FROM mission_transitions WHERE mission_id = $1 ORDER BY created_at, id;"
 
+	rows, err := dbtx(ctx, db.DB).QueryContext(ctx, query, missionID)
 	if err != nil {
+		return nil, appErrors.DBError("MissionRepository.ListTransitions", err)
+	}
+	defer rows.Close()
 
-		return nil, appErrors.ErrDatabase
+	for rows.Next() {
+		var transition models.MissionTransition
+		if err := rows.Scan(
+			&transition.ID,
+			&transition.MissionID,
+			&transition.From,
+			&transition.To,
+			&transition.Actor,
+			&transition.CreatedAt,
+		); err != nil {
+			return nil, appErrors.DBError("MissionRepository.ListTransitions", err)
+		}
+		list = append(list, transition)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, appErrors.DBError("MissionRepository.ListTransitions", err)
+	}
+
+	return list, nil
+}
+
+func (db *MissionRepository) GetTarget(ctx context.Context, id uint) (*models.Target, error) {
+	query := "SELECT " + targetColumns + " FROM targets WHERE id = $1"
+	row := dbtx(ctx, db.DB).QueryRowContext(ctx, query, id)
+	target, err := scanTarget(row)
+
+	if err == sql.ErrNoRows {
+		return nil, appErrors.ErrNotFound
+	}
+	if err != nil {
+		return nil, appErrors.DBError("MissionRepository.GetTarget", err)
 	}
 
 	return &target, nil
 }
 
-func (db *MissionRepository) DeleteTarget(id uint) error {
+func (db *MissionRepository) DeleteTarget(ctx context.Context, id uint) error {
 	query := "DELETE FROM targets WHERE id = $1;"
-	_, err := db.Exec(query, id)
-	return err
+	res, err := dbtx(ctx, db.DB).ExecContext(ctx, query, id)
+	if err != nil {
+		return appErrors.DBError("MissionRepository.DeleteTarget", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return appErrors.DBError("MissionRepository.DeleteTarget", err)
+	}
+
+	if affected == 0 {
+		return appErrors.ErrNotFound
+	}
+
+	return nil
 }
 
-func (db *MissionRepository) AddTarget(missionId uint, target models.Target) error {
-	query := "INSERT INTO targets (name, country, notes, mission_id) VALUES ($1, $2, $3, $4);"
-	_, err := db.Exec(query, target.Name, target.Country, target.Notes, missionId)
+func (db *MissionRepository) AddTarget(ctx context.Context, missionId uint, target models.Target) (*models.Target, error) {
+	query := "INSERT INTO targets (name, country, notes, notes_revision, priority, deadline, mission_id) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING " + targetColumns + ";"
+	row := dbtx(ctx, db.DB).QueryRowContext(ctx, query, target.Name, target.Country, target.Notes, target.NotesRevision, target.Priority, target.Deadline, missionId)
+	res, err := scanTarget(row)
 	if err != nil {
-		return appErrors.ErrDatabase
+		return nil, appErrors.DBError("MissionRepository.AddTarget", err)
 	}
+	return &res, nil
+}
+
+// CompleteTarget marks the target as completed. A target completed already
+// fails with ErrTargetCompleted, so of two concurrent completions only one
+// goes through.
+func (db *MissionRepository) CompleteTarget(ctx context.Context, id uint) error {
+	query := "UPDATE targets SET is_completed = TRUE WHERE id = $1 AND is_completed = FALSE;"
+	res, err := dbtx(ctx, db.DB).ExecContext(ctx, query, id)
+	if err != nil {
+		return appErrors.DBError("MissionRepository.CompleteTarget", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return appErrors.DBError("MissionRepository.CompleteTarget", err)
+	}
+
+	if affected == 0 {
+		if _, err := db.GetTarget(ctx, id); err != nil {
+			return err
+		}
+		return appErrors.ErrTargetCompleted
+	}
+
 	return nil
 }
 
-func (db *MissionRepository) CompleteTarget(id uint) error {
-	query := "UPDATE targets SET is_completed = TRUE WHERE id = $1;"
-	_, err := db.Exec(query, id)
+// UpdateTargetNotes replaces the notes of the target if they are still at
+// revision. A completed target fails with ErrTargetCompleted, a newer
+// revision with ErrNotesConflict.
+func (db *MissionRepository) UpdateTargetNotes(ctx context.Context, id uint, notes string, revision int) error {
+	query := `UPDATE targets SET notes = $1, notes_revision = notes_revision + 1
+		WHERE id = $2 AND notes_revision = $3 AND is_completed = FALSE;`
+	res, err := dbtx(ctx, db.DB).ExecContext(ctx, query, notes, id, revision)
+	if err != nil {
+		return appErrors.DBError("MissionRepository.UpdateTargetNotes", err)
+	}
+
+	affected, err := res.RowsAffected()
 	if err != nil {
-		return appErrors.ErrDatabase
+		return appErrors.DBError("MissionRepository.UpdateTargetNotes", err)
 	}
+
+	if affected == 0 {
+		target, err := db.GetTarget(ctx, id)
+		if err != nil {
+			return err
+		}
+		if target.IsCompleted {
+			return appErrors.ErrTargetCompleted
+		}
+		return appErrors.ErrNotesConflict
+	}
+
 	return nil
 }
 
-func (db *MissionRepository) UpdateTargetNotes(id uint, notes string) error {
-	query := "UPDATE targets SET notes = $1 WHERE id = $2;"
-	_, err := db.Exec(query, notes, id)
+const noteRevisionColumns = "id, target_id, revision, notes, author, author_key_id, created_at"
+
+func scanNoteRevision(row rowScanner) (models.NoteRevision, error) {
+	var revision models.NoteRevision
+	err := row.Scan(
+		&revision.ID,
+		&revision.TargetID,
+		&revision.Revision,
+		&revision.Notes,
+		&revision.Author,
+		&revision.AuthorKeyID,
+		&revision.CreatedAt,
+	)
+
+	return revision, err
+}
+
+func (db *MissionRepository) AddNoteRevision(ctx context.Context, revision models.NoteRevision) (*models.NoteRevision, error) {
+	query := "INSERT INTO target_notes (target_id, revision, notes, author, author_key_id) VALUES ($1, $2, $3, $4, $5) RETURNING " + noteRevisionColumns + ";"
+	row := dbtx(ctx, db.DB).QueryRowContext(ctx, query, revision.TargetID, revision.Revision, revision.Notes, revision.Author, revision.AuthorKeyID)
+
+	res, err := scanNoteRevision(row)
+	if err != nil {
+		return nil, appErrors.DBError("MissionRepository.AddNoteRevision", err)
+	}
+
+	return &res, nil
+}
+
+func (db *MissionRepository) GetNoteRevision(ctx context.Context, targetID uint, revision int) (*models.NoteRevision, error) {
+	query := "SELECT " + noteRevisionColumns + " FROM target_notes WHERE target_id = $1 AND revision = $2;"
+	res, err := scanNoteRevision(dbtx(ctx, db.DB).QueryRowContext(ctx, query, targetID, revision))
+
+	if err == sql.ErrNoRows {
+		return nil, appErrors.ErrNotFound
+	}
+	if err != nil {
+		return nil, appErrors.DBError("MissionRepository.GetNoteRevision", err)
+	}
+
+	return &res, nil
+}
+
+// ListNoteRevisions returns the notes journal of the target, oldest first.
+func (db *MissionRepository) ListNoteRevisions(ctx context.Context, targetID uint) ([]models.NoteRevision, error) {
+	list := []models.NoteRevision{}
+	query := "SELECT " + noteRevisionColumns + " FROM target_notes WHERE target_id = $1 ORDER BY revision;"
+
+	rows, err := dbtx(ctx, db.DB).QueryContext(ctx, query, targetID)
+	if err != nil {
+		return nil, appErrors.DBError("MissionRepository.ListNoteRevisions", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		revision, err := scanNoteRevision(rows)
+		if err != nil {
+			return nil, appErrors.DBError("MissionRepository.ListNoteRevisions", err)
+		}
+		list = append(list, revision)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, appErrors.DBError("MissionRepository.ListNoteRevisions", err)
+	}
+
+	return list, nil
+}
+
+// missionDeadline orders missions without a deadline after all others, so
+// the keyset condition never compares with NULL.
+const missionDeadline = "COALESCE(deadline, 'infinity')"
+
+var missionSortColumns = map[string]sortColumn{
+	"id":         {"id", "BIGINT"},
+	"name":       {"name", "VARCHAR"},
+	"created_at": {"created_at", "TIMESTAMPTZ"},
+	"priority":   {"priority", "SMALLINT"},
+	"deadline":   {missionDeadline, "TIMESTAMPTZ"},
+}
+
+func missionSortValue(mission models.Mission, column string) string {
+	switch column {
+	case "name":
+		return mission.Name
+	case "created_at":
+		return mission.CreatedAt.Format(time.RFC3339Nano)
+	case "priority":
+		return strconv.Itoa(mission.Priority.Rank())
+	case missionDeadline:
+		if mission.Deadline == nil {
+			return "infinity"
+		}
+		return mission.Deadline.Format(time.RFC3339Nano)
+	}
+	return ""
+}
+
+func (db *MissionRepository) QueryMissions(ctx context.Context, filter models.MissionFilter) (models.Page[models.Mission], error) {
+	page := models.Page[models.Mission]{List: []models.Mission{}}
+
+	where := &whereClause{}
+	if filter.Status != "" {
+		where.add("status = ?", filter.Status)
+	}
+	if filter.Assigned != nil {
+		if *filter.Assigned {
+			where.add("cat_id IS NOT NULL")
+		} else {
+			where.add("cat_id IS NULL")
+		}
+	}
+	if filter.CatID != nil {
+		where.add("cat_id = ?", *filter.CatID)
+	}
+	if filter.TargetCountry != "" {
+		where.add("EXISTS (SELECT 1 FROM targets WHERE targets.mission_id = missions.id AND targets.country = ?)", filter.TargetCountry)
+	}
+	if filter.Priority != "" {
+		where.add("priority = ?", filter.Priority)
+	}
+	if filter.Overdue {
+		where.add("deadline < NOW() AND status IN ('draft', 'assigned', 'in_progress')")
+	}
+
+	countWhere := where.clone()
+	query := "SELECT COUNT(*) FROM missions" + countWhere.String() + ";"
+	if err := dbtx(ctx, db.DB).QueryRowContext(ctx, query, countWhere.args...).Scan(&page.Total); err != nil {
+		return page, appErrors.DBError("MissionRepository.QueryMissions", err)
+	}
+
+	order := parseSort(filter.Sort, missionSortColumns)
+	tail, err := paginate(where, order, filter.After, filter.Limit)
+	if err != nil {
+		return page, err
+	}
+
+	query = "SELECT " + missionColumns + " FROM missions" + where.String() + tail + ";"
+	rows, err := dbtx(ctx, db.DB).QueryContext(ctx, query, where.args...)
+	if err != nil {
+		return page, appErrors.DBError("MissionRepository.QueryMissions", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		mission, err := scanMission(rows)
+		if err != nil {
+			return page, appErrors.DBError("MissionRepository.QueryMissions", err)
+		}
+		page.List = append(page.List, mission)
+	}
+
+	if err := rows.Err(); err != nil {
+		return page, appErrors.DBError("MissionRepository.QueryMissions", err)
+	}
+	rows.Close()
+
+	if size := pageSize(filter.Limit); len(page.List) > size {
+		page.List = page.List[:size]
+		last := page.List[size-1]
+		page.NextCursor = encodeCursor(order, missionSortValue(last, order.column.name), last.ID)
+	}
+
+	if err := db.loadTargets(ctx, page.List); err != nil {
+		return page, err
+	}
+
+	return page, nil
+}
+
+// loadTargets fills TargetList of every mission with one query and groups the
+// rows in memory, instead of querying targets mission by mission.
+func (db *MissionRepository) loadTargets(ctx context.Context, missions []models.Mission) error {
+	if len(missions) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(missions))
+	index := make(map[uint]int, len(missions))
+	for i, mission := range missions {
+		ids[i] = int64(mission.ID)
+		index[mission.ID] = i
+		if missions[i].TargetList == nil {
+			missions[i].TargetList = make([]models.Target, 0)
+		}
+	}
+
+	query := "SELECT " + targetColumns + " FROM targets WHERE mission_id = ANY($1) ORDER BY id;"
+	rows, err := dbtx(ctx, db.DB).QueryContext(ctx, query, pq.Array(ids))
 	if err != nil {
-		return appErrors.ErrDatabase
+		return appErrors.DBError("MissionRepository.loadTargets", err)
 	}
+	defer rows.Close()
+
+	for rows.Next() {
+		target, err := scanTarget(rows)
+		if err != nil {
+			return appErrors.DBError("MissionRepository.loadTargets", err)
+		}
+		i := index[target.MissionID]
+		missions[i].TargetList = append(missions[i].TargetList, target)
+	}
+
+	if err := rows.Err(); err != nil {
+		return appErrors.DBError("MissionRepository.loadTargets", err)
+	}
+
 	return nil
 }
@@ -0,0 +1,50 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	appErrors "spy_cat_agency/internal/appErorrs"
+	"spy_cat_agency/internal/events"
+	"time"
+)
+
+// OutboxRepository is the event publisher backed by the outbox_events table.
+// Publishing in the transaction of a change means an event is stored if and
+// only if the change is committed.
+type OutboxRepository struct {
+	*sql.DB
+}
+
+func NewOutboxRepository(db *sql.DB) *OutboxRepository {
+	return &OutboxRepository{
+		db,
+	}
+}
+
+// Publish stores the event and queues a delivery for every webhook
+// subscribed to its type.
+func (db *OutboxRepository) Publish(ctx context.Context, event events.Event) error {
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	var id uint
+	query := "INSERT INTO outbox_events (type, mission_id, cat_id, payload, created_at) VALUES ($1, $2, $3, $4, $5) RETURNING id;"
+	err = dbtx(ctx, db.DB).QueryRowContext(ctx, query, event.Type, event.MissionID, event.CatID, payload, event.OccurredAt).Scan(&id)
+	if err != nil {
+		return appErrors.DBError("OutboxRepository.Publish", err)
+	}
+
+	query = "INSERT INTO webhook_deliveries (webhook_id, event_id) SELECT id, $1 FROM webhooks WHERE cardinality(events) = 0 OR $2 = ANY(events);"
+	if _, err := dbtx(ctx, db.DB).ExecContext(ctx, query, id, event.Type); err != nil {
+		return appErrors.DBError("OutboxRepository.Publish", err)
+	}
+
+	return nil
+}
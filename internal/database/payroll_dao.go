@@ -0,0 +1,108 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	appErrors "spy_cat_agency/internal/appErorrs"
+	"spy_cat_agency/internal/models"
+	"time"
+)
+
+// PayrollRepository reads the salary and employment history of all cats at
+// once, for reports that span the whole agency.
+type PayrollRepository struct {
+	*sql.DB
+}
+
+func NewPayrollRepository(db *sql.DB) *PayrollRepository {
+	return &PayrollRepository{
+		db,
+	}
+}
+
+// ListCatsHiredBefore returns the cats, fired or not, hired on or before the
+// day of until.
+func (db *PayrollRepository) ListCatsHiredBefore(ctx context.Context, until time.Time) ([]models.Cat, error) {
+	list := []models.Cat{}
+	query := "SELECT " + catColumns + " FROM cats WHERE created_at < $1 ORDER BY id;"
+
+	rows, err := dbtx(ctx, db.DB).QueryContext(ctx, query, until.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, appErrors.DBError("PayrollRepository.ListCatsHiredBefore", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		cat, err := scanCat(rows)
+		if err != nil {
+			return nil, appErrors.DBError("PayrollRepository.ListCatsHiredBefore", err)
+		}
+		list = append(list, cat)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, appErrors.DBError("PayrollRepository.ListCatsHiredBefore", err)
+	}
+
+	return list, nil
+}
+
+// ListSalaryChanges returns the changes effective on or before until, ordered
+// by cat, effective date and the order they were recorded in.
+func (db *PayrollRepository) ListSalaryChanges(ctx context.Context, until time.Time) ([]models.SalaryChange, error) {
+	list := []models.SalaryChange{}
+	query := "SELECT id, cat_id, amount, currency, effective_from, created_at FROM salary_changes WHERE effective_from <= $1 ORDER BY cat_id, effective_from, id;"
+
+	rows, err := dbtx(ctx, db.DB).QueryContext(ctx, query, until.Format(dateLayout))
+	if err != nil {
+		return nil, appErrors.DBError("PayrollRepository.ListSalaryChanges", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		change, err := scanSalaryChange(rows)
+		if err != nil {
+			return nil, appErrors.DBError("PayrollRepository.ListSalaryChanges", err)
+		}
+		list = append(list, change)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, appErrors.DBError("PayrollRepository.ListSalaryChanges", err)
+	}
+
+	return list, nil
+}
+
+// ListEmploymentEvents returns the events that happened on or before the day
+// of until, ordered by cat and time.
+func (db *PayrollRepository) ListEmploymentEvents(ctx context.Context, until time.Time) ([]models.EmploymentEvent, error) {
+	list := []models.EmploymentEvent{}
+	query := "SELECT id, cat_id, event, reason, created_at FROM cat_employment_events WHERE created_at < $1 ORDER BY cat_id, created_at, id;"
+
+	rows, err := dbtx(ctx, db.DB).QueryContext(ctx, query, until.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, appErrors.DBError("PayrollRepository.ListEmploymentEvents", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var event models.EmploymentEvent
+		if err := rows.Scan(
+			&event.ID,
+			&event.CatID,
+			&event.Event,
+			&event.Reason,
+			&event.CreatedAt,
+		); err != nil {
+			return nil, appErrors.DBError("PayrollRepository.ListEmploymentEvents", err)
+		}
+		list = append(list, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, appErrors.DBError("PayrollRepository.ListEmploymentEvents", err)
+	}
+
+	return list, nil
+}
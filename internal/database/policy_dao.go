@@ -0,0 +1,37 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	appErrors "spy_cat_agency/internal/appErorrs"
+	"spy_cat_agency/internal/models"
+)
+
+// PolicyRepository reads the policy document stored in the single row of the
+// policies table.
+type PolicyRepository struct {
+	*sql.DB
+}
+
+func NewPolicyRepository(db *sql.DB) *PolicyRepository {
+	return &PolicyRepository{
+		db,
+	}
+}
+
+func (db *PolicyRepository) FetchPolicy() (models.Policy, error) {
+	var document []byte
+	query := "SELECT document FROM policies WHERE id = 1;"
+
+	if err := db.QueryRow(query).Scan(&document); err != nil {
+		return models.Policy{}, appErrors.DBError("PolicyRepository.FetchPolicy", err)
+	}
+
+	p := models.DefaultPolicy()
+	if err := json.Unmarshal(document, &p); err != nil {
+		return models.Policy{}, fmt.Errorf("decode policy document: %w", err)
+	}
+
+	return p, nil
+}
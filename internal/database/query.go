@@ -0,0 +1,154 @@
+package database
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	appErrors "spy_cat_agency/internal/appErorrs"
+	"strings"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// sortColumn describes a column a list can be ordered by. sqlType is used to
+// cast the cursor value back when it is compared in the keyset condition.
+type sortColumn struct {
+	name    string
+	sqlType string
+}
+
+// sortOrder is the order of a list. key is the name the column is sorted by
+// in the API, which the cursor records.
+type sortOrder struct {
+	key    string
+	column sortColumn
+	desc   bool
+}
+
+// newestFirst orders lists without a choice of order, such as the audit log.
+var newestFirst = sortOrder{key: "id", column: sortColumn{"id", "BIGINT"}, desc: true}
+
+// String returns the order the way it is requested, "name" or "-name".
+func (o sortOrder) String() string {
+	if o.desc {
+		return "-" + o.key
+	}
+	return o.key
+}
+
+// cursor points at the last row of a page. Rows are ordered by the sort column
+// and then by id, so the pair identifies the position unambiguously. Sort is
+// the order the page was listed in; the value means nothing in another one.
+type cursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    uint   `json:"id"`
+}
+
+func encodeCursor(order sortOrder, value string, id uint) string {
+	data, _ := json.Marshal(cursor{Sort: order.String(), Value: value, ID: id})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor reads a cursor issued for a list in order.
+func decodeCursor(s string, order sortOrder) (*cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, appErrors.ErrInvalidCursor
+	}
+
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, appErrors.ErrInvalidCursor
+	}
+
+	if c.Sort != order.String() {
+		return nil, appErrors.ErrInvalidCursor.WithDetail(fmt.Sprintf("cursor was issued for sort %q, not %q", c.Sort, order))
+	}
+
+	return &c, nil
+}
+
+// whereClause collects conditions written with ? placeholders and numbers
+// them as $1, $2, ... in the order they were added.
+type whereClause struct {
+	conds []string
+	args  []interface{}
+}
+
+func (w *whereClause) add(cond string, args ...interface{}) {
+	for _, arg := range args {
+		w.args = append(w.args, arg)
+		cond = strings.Replace(cond, "?", fmt.Sprintf("$%d", len(w.args)), 1)
+	}
+	w.conds = append(w.conds, cond)
+}
+
+func (w *whereClause) String() string {
+	if len(w.conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(w.conds, " AND ")
+}
+
+func (w *whereClause) clone() *whereClause {
+	return &whereClause{
+		conds: append([]string(nil), w.conds...),
+		args:  append([]interface{}(nil), w.args...),
+	}
+}
+
+// parseSort resolves "name" or "-name" against the allowed columns. An empty
+// value sorts by id ascending.
+func parseSort(sort string, columns map[string]sortColumn) sortOrder {
+	desc := strings.HasPrefix(sort, "-")
+	key := strings.TrimPrefix(sort, "-")
+	if key == "" {
+		key = "id"
+	}
+
+	return sortOrder{key: key, column: columns[key], desc: desc}
+}
+
+// paginate adds the keyset condition for the cursor and returns the ORDER BY
+// and LIMIT part of the query. One extra row is requested to find out whether
+// there is a next page.
+func paginate(where *whereClause, order sortOrder, after string, limit int) (string, error) {
+	column := order.column
+	op, dir := ">", "ASC"
+	if order.desc {
+		op, dir = "<", "DESC"
+	}
+
+	if after != "" {
+		c, err := decodeCursor(after, order)
+		if err != nil {
+			return "", err
+		}
+
+		if column.name == "id" {
+			where.add(fmt.Sprintf("id %s ?", op), c.ID)
+		} else {
+			where.add(fmt.Sprintf("(%s, id) %s (CAST(? AS %s), ?)", column.name, op, column.sqlType), c.Value, c.ID)
+		}
+	}
+
+	if column.name == "id" {
+		return fmt.Sprintf(" ORDER BY id %s LIMIT %d", dir, pageSize(limit)+1), nil
+	}
+
+	return fmt.Sprintf(" ORDER BY %s %s, id %s LIMIT %d", column.name, dir, dir, pageSize(limit)+1), nil
+}
+
+func pageSize(limit int) int {
+	if limit <= 0 {
+		return defaultPageSize
+	}
+	if limit > maxPageSize {
+		return maxPageSize
+	}
+	return limit
+}
@@ -0,0 +1,133 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	appErrors "spy_cat_agency/internal/appErorrs"
+	"spy_cat_agency/internal/events"
+	"spy_cat_agency/internal/logging"
+)
+
+// DBTX is the part of *sql.DB and *sql.Tx the repositories need, so the same
+// query code runs inside and outside of a transaction.
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+type txKey struct{}
+
+type afterCommitKey struct{}
+
+// afterCommit collects the functions to run once the transaction commits.
+type afterCommit struct {
+	fns []func()
+}
+
+type UnitOfWork struct {
+	*sql.DB
+}
+
+func NewUnitOfWork(db *sql.DB) *UnitOfWork {
+	return &UnitOfWork{
+		db,
+	}
+}
+
+// WithinTx runs fn in a transaction that is committed when fn returns nil and
+// rolled back otherwise. Repository calls made with the context passed to fn
+// join the transaction, and nested WithinTx calls reuse the outer one.
+func (db *UnitOfWork) WithinTx(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	if _, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return appErrors.DBError("UnitOfWork.WithinTx", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			rollback(ctx, tx)
+			panic(p)
+		}
+	}()
+
+	hooks := &afterCommit{}
+	ctx = context.WithValue(ctx, afterCommitKey{}, hooks)
+
+	if err = fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		rollback(ctx, tx)
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return appErrors.DBError("UnitOfWork.WithinTx", err)
+	}
+
+	for _, fn := range hooks.fns {
+		fn()
+	}
+
+	return nil
+}
+
+// rollback rolls tx back. A failure leaves nothing to undo, the connection is
+// discarded, so it is only logged.
+func rollback(ctx context.Context, tx *sql.Tx) {
+	if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+		logging.FromContext(ctx).Warn("failed to roll back transaction", logging.Err(err))
+	}
+}
+
+// AfterCommit runs fn once the transaction in ctx has been committed, and
+// never if it is rolled back. Outside of a transaction fn runs right away.
+func AfterCommit(ctx context.Context, fn func()) {
+	if hooks, ok := ctx.Value(afterCommitKey{}).(*afterCommit); ok {
+		hooks.fns = append(hooks.fns, fn)
+		return
+	}
+
+	fn()
+}
+
+// AfterCommitPublisher hands events to a publisher that lives outside of
+// the database, such as the in-process event bus, only once the change they
+// describe is committed.
+type AfterCommitPublisher struct {
+	publisher events.Publisher
+}
+
+func NewAfterCommitPublisher(publisher events.Publisher) *AfterCommitPublisher {
+	return &AfterCommitPublisher{
+		publisher: publisher,
+	}
+}
+
+func (p *AfterCommitPublisher) Publish(ctx context.Context, event events.Event) error {
+	// The change may still modify what Data points at before it commits.
+	event, err := event.Snapshot()
+	if err != nil {
+		return err
+	}
+
+	AfterCommit(ctx, func() {
+		if err := p.publisher.Publish(context.WithoutCancel(ctx), event); err != nil {
+			logging.FromContext(ctx).Warn("failed to publish committed event", "event", event.Type, logging.Err(err))
+		}
+	})
+
+	return nil
+}
+
+// dbtx returns the transaction stored in ctx, or db when there is none.
+func dbtx(ctx context.Context, db *sql.DB) DBTX {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return tx
+	}
+
+	return db
+}
@@ -0,0 +1,275 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	appErrors "spy_cat_agency/internal/appErorrs"
+	"spy_cat_agency/internal/models"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type WebhookRepository struct {
+	*sql.DB
+}
+
+func NewWebhookRepository(db *sql.DB) *WebhookRepository {
+	return &WebhookRepository{
+		db,
+	}
+}
+
+const webhookColumns = "id, url, events, created_at"
+
+func scanWebhook(row rowScanner) (models.Webhook, error) {
+	var hook models.Webhook
+	err := row.Scan(
+		&hook.ID,
+		&hook.URL,
+		pq.Array(&hook.Events),
+		&hook.CreatedAt,
+	)
+
+	if hook.Events == nil {
+		hook.Events = []string{}
+	}
+
+	return hook, err
+}
+
+// AddWebhook stores the webhook. The result is the only one that carries the
+// secret.
+func (db *WebhookRepository) AddWebhook(ctx context.Context, hook models.Webhook) (*models.Webhook, error) {
+	query := "INSERT INTO webhooks (url, events, secret) VALUES ($1, $2, $3) RETURNING " + webhookColumns + ";"
+	row := dbtx(ctx, db.DB).QueryRowContext(ctx, query, hook.URL, pq.Array(hook.Events), hook.Secret)
+
+	res, err := scanWebhook(row)
+	if err != nil {
+		return nil, appErrors.DBError("WebhookRepository.AddWebhook", err)
+	}
+	res.Secret = hook.Secret
+
+	return &res, nil
+}
+
+func (db *WebhookRepository) ListWebhooks(ctx context.Context) ([]models.Webhook, error) {
+	list := []models.Webhook{}
+	query := "SELECT " + webhookColumns + " FROM webhooks ORDER BY id;"
+
+	rows, err := dbtx(ctx, db.DB).QueryContext(ctx, query)
+	if err != nil {
+		return nil, appErrors.DBError("WebhookRepository.ListWebhooks", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		hook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, appErrors.DBError("WebhookRepository.ListWebhooks", err)
+		}
+		list = append(list, hook)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, appErrors.DBError("WebhookRepository.ListWebhooks", err)
+	}
+
+	return list, nil
+}
+
+func (db *WebhookRepository) GetWebhook(ctx context.Context, id uint) (*models.Webhook, error) {
+	query := "SELECT " + webhookColumns + " FROM webhooks WHERE id = $1;"
+	hook, err := scanWebhook(dbtx(ctx, db.DB).QueryRowContext(ctx, query, id))
+
+	if err == sql.ErrNoRows {
+		return nil, appErrors.ErrNotFound
+	}
+	if err != nil {
+		return nil, appErrors.DBError("WebhookRepository.GetWebhook", err)
+	}
+
+	return &hook, nil
+}
+
+// DeleteWebhook removes the webhook together with its deliveries.
+func (db *WebhookRepository) DeleteWebhook(ctx context.Context, id uint) error {
+	query := "DELETE FROM webhooks WHERE id = $1;"
+	res, err := dbtx(ctx, db.DB).ExecContext(ctx, query, id)
+	if err != nil {
+		return appErrors.DBError("WebhookRepository.DeleteWebhook", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return appErrors.DBError("WebhookRepository.DeleteWebhook", err)
+	}
+
+	if affected == 0 {
+		return appErrors.ErrNotFound
+	}
+
+	return nil
+}
+
+const deliveryColumns = "d.id, d.webhook_id, d.event_id, e.type, d.status, d.attempts, d.next_attempt_at, d.last_error, d.response_status, d.created_at, d.delivered_at"
+
+func scanDelivery(row rowScanner, dest ...interface{}) (models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	err := row.Scan(append([]interface{}{
+		&delivery.ID,
+		&delivery.WebhookID,
+		&delivery.EventID,
+		&delivery.EventType,
+		&delivery.Status,
+		&delivery.Attempts,
+		&delivery.NextAttemptAt,
+		&delivery.LastError,
+		&delivery.ResponseStatus,
+		&delivery.CreatedAt,
+		&delivery.DeliveredAt,
+	}, dest...)...)
+
+	return delivery, err
+}
+
+// QueryDeliveries lists the deliveries of a webhook, newest first, without
+// their payloads.
+func (db *WebhookRepository) QueryDeliveries(ctx context.Context, webhookID uint, filter models.DeliveryFilter) (models.Page[models.WebhookDelivery], error) {
+	page := models.Page[models.WebhookDelivery]{List: []models.WebhookDelivery{}}
+
+	where := &whereClause{}
+	where.add("webhook_id = ?", webhookID)
+	if filter.Status != "" {
+		where.add("status = ?", filter.Status)
+	}
+
+	countWhere := where.clone()
+	query := "SELECT COUNT(*) FROM webhook_deliveries" + countWhere.String() + ";"
+	if err := dbtx(ctx, db.DB).QueryRowContext(ctx, query, countWhere.args...).Scan(&page.Total); err != nil {
+		return page, appErrors.DBError("WebhookRepository.QueryDeliveries", err)
+	}
+
+	tail, err := paginate(where, newestFirst, filter.After, filter.Limit)
+	if err != nil {
+		return page, err
+	}
+
+	query = "SELECT " + deliveryColumns + " FROM (SELECT * FROM webhook_deliveries" + where.String() + tail + ") d JOIN outbox_events e ON e.id = d.event_id ORDER BY d.id DESC;"
+	rows, err := dbtx(ctx, db.DB).QueryContext(ctx, query, where.args...)
+	if err != nil {
+		return page, appErrors.DBError("WebhookRepository.QueryDeliveries", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		delivery, err := scanDelivery(rows)
+		if err != nil {
+			return page, appErrors.DBError("WebhookRepository.QueryDeliveries", err)
+		}
+		page.List = append(page.List, delivery)
+	}
+
+	if err := rows.Err(); err != nil {
+		return page, appErrors.DBError("WebhookRepository.QueryDeliveries", err)
+	}
+
+	if size := pageSize(filter.Limit); len(page.List) > size {
+		page.List = page.List[:size]
+		page.NextCursor = encodeCursor(newestFirst, "", page.List[size-1].ID)
+	}
+
+	return page, nil
+}
+
+// GetDelivery returns the delivery with the payload that is sent.
+func (db *WebhookRepository) GetDelivery(ctx context.Context, id uint) (*models.WebhookDelivery, error) {
+	var payload []byte
+	query := "SELECT " + deliveryColumns + ", e.payload FROM webhook_deliveries d JOIN outbox_events e ON e.id = d.event_id WHERE d.id = $1;"
+	delivery, err := scanDelivery(dbtx(ctx, db.DB).QueryRowContext(ctx, query, id), &payload)
+
+	if err == sql.ErrNoRows {
+		return nil, appErrors.ErrNotFound
+	}
+	if err != nil {
+		return nil, appErrors.DBError("WebhookRepository.GetDelivery", err)
+	}
+	delivery.Payload = payload
+
+	return &delivery, nil
+}
+
+// RetryDelivery queues a dead delivery again with a fresh attempt count.
+func (db *WebhookRepository) RetryDelivery(ctx context.Context, id uint) error {
+	query := "UPDATE webhook_deliveries SET status = 'pending', attempts = 0, next_attempt_at = NOW(), last_error = NULL, response_status = NULL WHERE id = $1 AND status = 'dead';"
+	res, err := dbtx(ctx, db.DB).ExecContext(ctx, query, id)
+	if err != nil {
+		return appErrors.DBError("WebhookRepository.RetryDelivery", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return appErrors.DBError("WebhookRepository.RetryDelivery", err)
+	}
+
+	if affected == 0 {
+		return appErrors.ErrDeliveryNotDead
+	}
+
+	return nil
+}
+
+// ClaimDue takes up to limit pending deliveries whose time has come and
+// pushes their next attempt lease into the future, so concurrent workers do
+// not send them twice and a worker that dies leaves them to be retried.
+func (db *WebhookRepository) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]models.DueDelivery, error) {
+	list := []models.DueDelivery{}
+	query := "WITH d AS (" +
+		"UPDATE webhook_deliveries SET next_attempt_at = NOW() + $2 * INTERVAL '1 millisecond' " +
+		"WHERE id IN (SELECT id FROM webhook_deliveries WHERE status = 'pending' AND next_attempt_at <= NOW() ORDER BY next_attempt_at, id LIMIT $1 FOR UPDATE SKIP LOCKED) " +
+		"RETURNING *) " +
+		"SELECT " + deliveryColumns + ", e.payload, w.url, w.secret FROM d " +
+		"JOIN outbox_events e ON e.id = d.event_id JOIN webhooks w ON w.id = d.webhook_id ORDER BY d.id;"
+
+	rows, err := dbtx(ctx, db.DB).QueryContext(ctx, query, limit, lease.Milliseconds())
+	if err != nil {
+		return nil, appErrors.DBError("WebhookRepository.ClaimDue", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var due models.DueDelivery
+		var payload []byte
+		due.WebhookDelivery, err = scanDelivery(rows, &payload, &due.URL, &due.Secret)
+		if err != nil {
+			return nil, appErrors.DBError("WebhookRepository.ClaimDue", err)
+		}
+		due.Payload = payload
+		list = append(list, due)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, appErrors.DBError("WebhookRepository.ClaimDue", err)
+	}
+
+	return list, nil
+}
+
+// RecordAttempt stores the outcome of a delivery attempt.
+func (db *WebhookRepository) RecordAttempt(ctx context.Context, delivery models.WebhookDelivery) error {
+	query := "UPDATE webhook_deliveries SET status = $1, attempts = $2, next_attempt_at = $3, last_error = $4, response_status = $5, delivered_at = $6 WHERE id = $7;"
+	_, err := dbtx(ctx, db.DB).ExecContext(ctx, query,
+		delivery.Status,
+		delivery.Attempts,
+		delivery.NextAttemptAt,
+		delivery.LastError,
+		delivery.ResponseStatus,
+		delivery.DeliveredAt,
+		delivery.ID,
+	)
+	if err != nil {
+		return appErrors.DBError("WebhookRepository.RecordAttempt", err)
+	}
+
+	return nil
+}
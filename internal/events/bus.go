@@ -0,0 +1,164 @@
+package events
+
+import (
+	"context"
+	"sync"
+)
+
+// subscriberBuffer is how many records a subscriber may fall behind before
+// it is dropped. A dropped client reconnects and catches up from the replay
+// buffer.
+const subscriberBuffer = 64
+
+// Record is an event as numbered by the bus.
+type Record struct {
+	ID uint64 `json:"id"`
+	Event
+}
+
+// Filter selects the events about a mission and/or a cat. Nil fields match
+// every event.
+type Filter struct {
+	MissionID *uint
+	CatID     *uint
+}
+
+func (f Filter) Match(event Event) bool {
+	if f.MissionID != nil && (event.MissionID == nil || *event.MissionID != *f.MissionID) {
+		return false
+	}
+	if f.CatID != nil && (event.CatID == nil || *event.CatID != *f.CatID) {
+		return false
+	}
+	return true
+}
+
+// Bus is an in-process publisher that numbers events, keeps the most recent
+// ones for replay and fans them out to subscribers.
+type Bus struct {
+	mu     sync.Mutex
+	lastID uint64
+	replay []Record
+	start  int
+	subs   map[*Subscription]struct{}
+	closed bool
+}
+
+// NewBus creates a bus that keeps the last size events for replay.
+func NewBus(size int) *Bus {
+	return &Bus{
+		replay: make([]Record, 0, size),
+		subs:   make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish numbers the event and sends it to the matching subscribers. Data
+// is encoded right away, so records never change once they are published.
+func (b *Bus) Publish(ctx context.Context, event Event) error {
+	event, err := event.Snapshot()
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastID++
+	record := Record{ID: b.lastID, Event: event}
+
+	if len(b.replay) < cap(b.replay) {
+		b.replay = append(b.replay, record)
+	} else if cap(b.replay) > 0 {
+		b.replay[b.start] = record
+		b.start = (b.start + 1) % cap(b.replay)
+	}
+
+	for sub := range b.subs {
+		if !sub.filter.Match(event) {
+			continue
+		}
+
+		select {
+		case sub.ch <- record:
+		default:
+			b.drop(sub)
+		}
+	}
+
+	return nil
+}
+
+// Subscribe starts receiving the events that match filter. The records after
+// lastID still in the replay buffer are returned for the caller to send
+// first; lastID 0 replays nothing. An id the bus has not issued, such as one
+// from before a restart, replays the whole buffer.
+func (b *Bus) Subscribe(filter Filter, lastID uint64) (*Subscription, []Record) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	sub := &Subscription{
+		bus:    b,
+		filter: filter,
+		ch:     make(chan Record, subscriberBuffer),
+	}
+	if b.closed {
+		close(sub.ch)
+		return sub, nil
+	}
+	b.subs[sub] = struct{}{}
+
+	if lastID == 0 {
+		return sub, nil
+	}
+	if lastID > b.lastID {
+		lastID = 0
+	}
+
+	var replay []Record
+	for i := range b.replay {
+		record := b.replay[(b.start+i)%len(b.replay)]
+		if record.ID > lastID && filter.Match(record.Event) {
+			replay = append(replay, record)
+		}
+	}
+
+	return sub, replay
+}
+
+// Close ends every subscription, and those made afterwards, so open streams
+// return during a shutdown. Events are still numbered and kept for replay.
+func (b *Bus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for sub := range b.subs {
+		b.drop(sub)
+	}
+}
+
+func (b *Bus) drop(sub *Subscription) {
+	if _, ok := b.subs[sub]; ok {
+		delete(b.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// Subscription delivers records until it is closed, or until the subscriber
+// falls too far behind, in which case the channel is closed by the bus.
+type Subscription struct {
+	bus    *Bus
+	filter Filter
+	ch     chan Record
+}
+
+func (s *Subscription) C() <-chan Record {
+	return s.ch
+}
+
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+
+	s.bus.drop(s)
+}
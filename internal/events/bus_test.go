@@ -0,0 +1,51 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+type payload struct {
+	Status string `json:"status"`
+}
+
+func TestPublishKeepsDataAsPublished(t *testing.T) {
+	bus := NewBus(8)
+	sub, _ := bus.Subscribe(Filter{}, 0)
+	defer sub.Close()
+
+	data := &payload{Status: "draft"}
+	if err := bus.Publish(context.Background(), Event{Type: MissionCreated, Data: data}); err != nil {
+		t.Fatal(err)
+	}
+	data.Status = "assigned"
+
+	check := func(from string, record Record) {
+		t.Helper()
+
+		body, err := json.Marshal(record)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got struct {
+			Data payload `json:"data"`
+		}
+		if err := json.Unmarshal(body, &got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Data.Status != "draft" {
+			t.Errorf("%s: status = %q, want %q", from, got.Data.Status, "draft")
+		}
+	}
+
+	check("stream", <-sub.C())
+
+	// An id the bus has not issued replays the whole buffer.
+	again, replay := bus.Subscribe(Filter{}, 1000)
+	defer again.Close()
+	if len(replay) != 1 {
+		t.Fatalf("replayed %d records, want 1", len(replay))
+	}
+	check("replay", replay[0])
+}
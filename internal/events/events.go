@@ -0,0 +1,111 @@
+// Package events describes what happens in the agency in a form other
+// systems can consume. Services emit events through a Publisher; where they
+// end up depends on the implementation wired in the server.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+type Type string
+
+const (
+	CatHired           Type = "cat.hired"
+	CatFired           Type = "cat.fired"
+	CatRehired         Type = "cat.rehired"
+	MissionCreated     Type = "mission.created"
+	MissionAssigned    Type = "mission.assigned"
+	MissionUnassigned  Type = "mission.unassigned"
+	MissionReassigned  Type = "mission.reassigned"
+	MissionStarted     Type = "mission.started"
+	MissionCompleted   Type = "mission.completed"
+	MissionAborted     Type = "mission.aborted"
+	MissionFailed      Type = "mission.failed"
+	MissionOverdue     Type = "mission.overdue"
+	TargetCompleted    Type = "target.completed"
+	TargetNotesUpdated Type = "target.notes_updated"
+)
+
+// Types lists every event type, in the order they are documented.
+func Types() []Type {
+	return []Type{
+		CatHired,
+		CatFired,
+		CatRehired,
+		MissionCreated,
+		MissionAssigned,
+		MissionUnassigned,
+		MissionReassigned,
+		MissionStarted,
+		MissionCompleted,
+		MissionAborted,
+		MissionFailed,
+		MissionOverdue,
+		TargetCompleted,
+		TargetNotesUpdated,
+	}
+}
+
+func (t Type) IsKnown() bool {
+	for _, known := range Types() {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Event is a fact about a mission or a cat. MissionID and CatID are set when
+// the event concerns them, so consumers can filter without decoding Data.
+type Event struct {
+	Type       Type        `json:"type"`
+	MissionID  *uint       `json:"mission_id,omitempty"`
+	CatID      *uint       `json:"cat_id,omitempty"`
+	Data       interface{} `json:"data,omitempty"`
+	OccurredAt time.Time   `json:"occurred_at"`
+}
+
+// Snapshot returns the event with Data encoded as it is now, so that later
+// changes to a value Data points at do not show in the event.
+func (e Event) Snapshot() (Event, error) {
+	if e.Data == nil {
+		return e, nil
+	}
+	if _, ok := e.Data.(json.RawMessage); ok {
+		return e, nil
+	}
+
+	data, err := json.Marshal(e.Data)
+	if err != nil {
+		return e, err
+	}
+	e.Data = json.RawMessage(data)
+
+	return e, nil
+}
+
+// Publisher takes events for delivery. Publish is called with the context of
+// the transaction making the change, so an implementation that writes to the
+// database commits or rolls back together with it.
+type Publisher interface {
+	Publish(ctx context.Context, event Event) error
+}
+
+type multiPublisher []Publisher
+
+// Multi publishes every event to each of publishers in turn, stopping at the
+// first error.
+func Multi(publishers ...Publisher) Publisher {
+	return multiPublisher(publishers)
+}
+
+func (m multiPublisher) Publish(ctx context.Context, event Event) error {
+	for _, p := range m {
+		if err := p.Publish(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
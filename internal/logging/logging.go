@@ -0,0 +1,43 @@
+// Package logging sets up the structured logger of the service and carries a
+// request-scoped logger in the context, so controllers, services and DAOs
+// log with the request ID of the request they are serving.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+)
+
+type loggerKey struct{}
+
+// New returns a logger writing one JSON object per line to w, from level on:
+// "debug", "info", "warn" or "error". An unknown level is "info".
+func New(w io.Writer, level string) *slog.Logger {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		l = slog.LevelInfo
+	}
+
+	return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: l}))
+}
+
+// WithLogger returns a copy of ctx that carries logger.
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext returns the logger carried by ctx, or the default logger.
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+
+	return slog.Default()
+}
+
+// Err is the attribute an error is logged under. The message keeps the whole
+// chain of wrapped errors.
+func Err(err error) slog.Attr {
+	return slog.Any("error", err)
+}
@@ -0,0 +1,135 @@
+package memstore
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	appErrors "spy_cat_agency/internal/appErorrs"
+	"spy_cat_agency/internal/models"
+)
+
+// apiKey is a row of api_keys: the key with the hash it is looked up by.
+type apiKey struct {
+	models.APIKey
+	KeyHash string `json:"key_hash"`
+}
+
+type ApiKeyRepository struct {
+	*Store
+}
+
+func NewApiKeyRepository(store *Store) *ApiKeyRepository {
+	return &ApiKeyRepository{
+		store,
+	}
+}
+
+func (db *ApiKeyRepository) AddKey(ctx context.Context, key models.APIKey, keyHash string) (*models.APIKey, error) {
+	var res models.APIKey
+
+	err := db.run(ctx, func(t *tables) error {
+		if key.CatID != nil {
+			if _, ok := t.cats.get(*key.CatID); !ok {
+				return appErrors.ErrCatNotFound
+			}
+		}
+
+		switch {
+		case key.Role != models.RoleHandler && key.Role != models.RoleAgent:
+			return violation("ApiKeyRepository.AddKey", "api_keys_role_check")
+		case key.Role == models.RoleAgent && key.CatID == nil:
+			return violation("ApiKeyRepository.AddKey", "api_keys_check")
+		}
+
+		for _, existing := range t.apiKeys.rows {
+			if existing.KeyHash == keyHash {
+				return violation("ApiKeyRepository.AddKey", "api_keys_key_hash_key")
+			}
+		}
+
+		res = models.APIKey{
+			ID:        db.next("api_keys"),
+			Name:      key.Name,
+			Role:      key.Role,
+			CatID:     key.CatID,
+			CreatedAt: db.now(ctx),
+		}
+		t.apiKeys.put(res.ID, apiKey{APIKey: res, KeyHash: keyHash})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+// GetActiveKey looks a key up by its hash, ignoring revoked keys and the keys
+// of fired cats.
+func (db *ApiKeyRepository) GetActiveKey(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	var res models.APIKey
+
+	err := db.run(ctx, func(t *tables) error {
+		for _, key := range t.apiKeys.rows {
+			if key.KeyHash != keyHash || key.RevokedAt != nil {
+				continue
+			}
+			if key.CatID != nil {
+				if cat, ok := t.cats.get(*key.CatID); ok && cat.IsFired() {
+					continue
+				}
+			}
+
+			res = key.APIKey
+			return nil
+		}
+		return appErrors.ErrNotFound
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+func (db *ApiKeyRepository) ListKeys(ctx context.Context) ([]models.APIKey, error) {
+	list := []models.APIKey{}
+
+	err := db.run(ctx, func(t *tables) error {
+		for _, key := range t.apiKeys.rows {
+			list = append(list, key.APIKey)
+		}
+		return nil
+	})
+
+	slices.SortFunc(list, func(a, b models.APIKey) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return list, err
+}
+
+func (db *ApiKeyRepository) CountKeys(ctx context.Context) (int, error) {
+	var count int
+
+	err := db.run(ctx, func(t *tables) error {
+		count = len(t.apiKeys.rows)
+		return nil
+	})
+
+	return count, err
+}
+
+func (db *ApiKeyRepository) RevokeKey(ctx context.Context, id uint) error {
+	return db.run(ctx, func(t *tables) error {
+		key, ok := t.apiKeys.get(id)
+		if !ok || key.RevokedAt != nil {
+			return appErrors.ErrNotFound
+		}
+
+		revokedAt := db.now(ctx)
+		key.RevokedAt = &revokedAt
+		t.apiKeys.put(id, key)
+		return nil
+	})
+}
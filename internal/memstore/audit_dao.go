@@ -0,0 +1,62 @@
+package memstore
+
+import (
+	"context"
+	"spy_cat_agency/internal/models"
+)
+
+type AuditRepository struct {
+	*Store
+}
+
+func NewAuditRepository(store *Store) *AuditRepository {
+	return &AuditRepository{
+		store,
+	}
+}
+
+// Record stores the event. Called with a transaction context it is rolled
+// back together with the change it describes.
+func (db *AuditRepository) Record(ctx context.Context, event models.AuditEvent) error {
+	return db.run(ctx, func(t *tables) error {
+		event.ID = db.next("audit_events")
+		event.CreatedAt = db.now(ctx)
+		t.auditEvents.put(event.ID, event)
+		return nil
+	})
+}
+
+// QueryEvents lists events newest first.
+func (db *AuditRepository) QueryEvents(ctx context.Context, filter models.AuditFilter) (models.Page[models.AuditEvent], error) {
+	var rows []models.AuditEvent
+
+	err := db.run(ctx, func(t *tables) error {
+		for _, event := range t.auditEvents.rows {
+			if matchAuditEvent(event, filter) {
+				rows = append(rows, event)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return models.Page[models.AuditEvent]{List: []models.AuditEvent{}}, err
+	}
+
+	return paginate(rows, func(event models.AuditEvent) uint { return event.ID }, byID[models.AuditEvent](), true, filter.After, filter.Limit)
+}
+
+func matchAuditEvent(event models.AuditEvent, filter models.AuditFilter) bool {
+	switch {
+	case filter.EntityType != "" && event.EntityType != filter.EntityType:
+		return false
+	case filter.EntityID != nil && event.EntityID != *filter.EntityID:
+		return false
+	case filter.Actor != "" && event.Actor != filter.Actor:
+		return false
+	case filter.From != nil && event.CreatedAt.Before(*filter.From):
+		return false
+	case filter.To != nil && !event.CreatedAt.Before(*filter.To):
+		return false
+	}
+	return true
+}
@@ -0,0 +1,35 @@
+package memstore
+
+import (
+	"context"
+	"slices"
+	"spy_cat_agency/internal/models"
+	"strings"
+)
+
+type BreedRepository struct {
+	*Store
+}
+
+func NewBreedRepository(store *Store) *BreedRepository {
+	return &BreedRepository{
+		store,
+	}
+}
+
+func (db *BreedRepository) FetchBreeds() ([]models.Breed, error) {
+	list := []models.Breed{}
+
+	err := db.run(context.Background(), func(t *tables) error {
+		for _, breed := range t.breeds.rows {
+			list = append(list, breed)
+		}
+		return nil
+	})
+
+	slices.SortFunc(list, func(a, b models.Breed) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return list, err
+}
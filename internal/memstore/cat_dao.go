@@ -0,0 +1,307 @@
+package memstore
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	appErrors "spy_cat_agency/internal/appErorrs"
+	"spy_cat_agency/internal/models"
+	"strconv"
+	"time"
+)
+
+type CatRepository struct {
+	*Store
+}
+
+func NewCatRepository(store *Store) *CatRepository {
+	return &CatRepository{
+		store,
+	}
+}
+
+const dateLayout = "2006-01-02"
+
+// date keeps only the calendar day of t, as a DATE column does.
+func date(t time.Time) time.Time {
+	d, _ := time.Parse(dateLayout, t.Format(dateLayout))
+	return d
+}
+
+func (db *CatRepository) Add(ctx context.Context, cat models.Cat) (*models.Cat, error) {
+	var res models.Cat
+
+	err := db.run(ctx, func(t *tables) error {
+		res = models.Cat{
+			ID:                db.next("cats"),
+			Name:              cat.Name,
+			YearsOfExperience: cat.YearsOfExperience,
+			Breed:             cat.Breed,
+			Salary:            cat.Salary,
+			Currency:          cat.Currency,
+			CreatedAt:         db.now(ctx),
+		}
+		t.cats.put(res.ID, res)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+// Fire marks the cat as fired. The row is kept so that past missions still
+// reference it.
+func (db *CatRepository) Fire(ctx context.Context, id uint, reason *string) error {
+	return db.run(ctx, func(t *tables) error {
+		cat, ok := t.cats.get(id)
+		if !ok {
+			return nil
+		}
+
+		firedAt := db.now(ctx)
+		cat.FiredAt = &firedAt
+		cat.FiredReason = reason
+		t.cats.put(id, cat)
+		return nil
+	})
+}
+
+func (db *CatRepository) Rehire(ctx context.Context, id uint) error {
+	return db.run(ctx, func(t *tables) error {
+		cat, ok := t.cats.get(id)
+		if !ok {
+			return nil
+		}
+
+		cat.FiredAt = nil
+		cat.FiredReason = nil
+		t.cats.put(id, cat)
+		return nil
+	})
+}
+
+// AddSalaryChange records the change and sets the cat's salary to the salary
+// in effect today, the UTC date as for the service, the one recorded last if
+// several start on the same day. It returns that salary.
+func (db *CatRepository) AddSalaryChange(ctx context.Context, change models.SalaryChange) (models.Money, error) {
+	var current models.Money
+
+	err := db.run(ctx, func(t *tables) error {
+		cat, ok := t.cats.get(change.CatID)
+		if !ok {
+			return violation("CatRepository.AddSalaryChange", "salary_changes_cat_id_fkey")
+		}
+		if change.Amount <= 0 {
+			return violation("CatRepository.AddSalaryChange", "salary_changes_amount_check")
+		}
+
+		change.EffectiveFrom = date(change.EffectiveFrom)
+		change.CreatedAt = db.now(ctx)
+		change.ID = db.next("salary_changes")
+		t.salaryChanges.put(change.ID, change)
+
+		today := date(db.now(ctx).UTC())
+		var latest *models.SalaryChange
+		for _, c := range t.salaryChanges.rows {
+			if c.CatID == change.CatID && !c.EffectiveFrom.After(today) && (latest == nil || latestSalaryChange(c, *latest)) {
+				latest = &c
+			}
+		}
+		if latest == nil {
+			return violation("CatRepository.AddSalaryChange", "cats_salary_not_null")
+		}
+
+		cat.Salary = latest.Amount
+		t.cats.put(cat.ID, cat)
+		current = cat.Salary
+		return nil
+	})
+
+	return current, err
+}
+
+// latestSalaryChange reports whether a took effect after b, or on the same
+// day and was recorded after it.
+func latestSalaryChange(a, b models.SalaryChange) bool {
+	return a.EffectiveFrom.After(b.EffectiveFrom) || a.EffectiveFrom.Equal(b.EffectiveFrom) && a.ID > b.ID
+}
+
+// ListSalaryChanges returns the salary history of the cat, oldest first and
+// changes of the same day in the order they were recorded.
+func (db *CatRepository) ListSalaryChanges(ctx context.Context, catID uint) ([]models.SalaryChange, error) {
+	list := []models.SalaryChange{}
+
+	err := db.run(ctx, func(t *tables) error {
+		for _, change := range t.salaryChanges.rows {
+			if change.CatID == catID {
+				list = append(list, change)
+			}
+		}
+		return nil
+	})
+
+	slices.SortFunc(list, func(a, b models.SalaryChange) int {
+		return cmp.Or(a.EffectiveFrom.Compare(b.EffectiveFrom), cmp.Compare(a.ID, b.ID))
+	})
+
+	return list, err
+}
+
+// List returns the cats currently employed by the agency.
+func (db *CatRepository) List(ctx context.Context) ([]models.Cat, error) {
+	list := []models.Cat{}
+
+	err := db.run(ctx, func(t *tables) error {
+		for _, cat := range t.cats.rows {
+			if !cat.IsFired() {
+				list = append(list, cat)
+			}
+		}
+		return nil
+	})
+
+	slices.SortFunc(list, func(a, b models.Cat) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return list, err
+}
+
+// Get returns the cat whether it is employed or fired.
+func (db *CatRepository) Get(ctx context.Context, id uint) (*models.Cat, error) {
+	var res models.Cat
+
+	err := db.run(ctx, func(t *tables) error {
+		cat, ok := t.cats.get(id)
+		if !ok {
+			return appErrors.ErrNotFound
+		}
+		res = cat
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+// Lock reads the cat. Inside a transaction the whole store is locked
+// already, so there is no row to lock.
+func (db *CatRepository) Lock(ctx context.Context, id uint) (*models.Cat, error) {
+	return db.Get(ctx, id)
+}
+
+func (db *CatRepository) HasActiveMission(ctx context.Context, id uint) (bool, error) {
+	var exists bool
+
+	err := db.run(ctx, func(t *tables) error {
+		for _, mission := range t.missions.rows {
+			if mission.CatId != nil && *mission.CatId == id && mission.Status.IsActive() {
+				exists = true
+			}
+		}
+		return nil
+	})
+
+	return exists, err
+}
+
+func (db *CatRepository) AddEmploymentEvent(ctx context.Context, event models.EmploymentEvent) error {
+	return db.run(ctx, func(t *tables) error {
+		if _, ok := t.cats.get(event.CatID); !ok {
+			return violation("CatRepository.AddEmploymentEvent", "cat_employment_events_cat_id_fkey")
+		}
+
+		switch event.Event {
+		case models.EmploymentHire, models.EmploymentFire, models.EmploymentRehire:
+		default:
+			return violation("CatRepository.AddEmploymentEvent", "cat_employment_events_event_check")
+		}
+
+		event.ID = db.next("cat_employment_events")
+		event.CreatedAt = db.now(ctx)
+		t.employmentEvents.put(event.ID, event)
+		return nil
+	})
+}
+
+// ListEmploymentEvents returns the hire, fire and rehire events of the cat,
+// oldest first.
+func (db *CatRepository) ListEmploymentEvents(ctx context.Context, catID uint) ([]models.EmploymentEvent, error) {
+	list := []models.EmploymentEvent{}
+
+	err := db.run(ctx, func(t *tables) error {
+		for _, event := range t.employmentEvents.rows {
+			if event.CatID == catID {
+				list = append(list, event)
+			}
+		}
+		return nil
+	})
+
+	slices.SortFunc(list, func(a, b models.EmploymentEvent) int {
+		return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), cmp.Compare(a.ID, b.ID))
+	})
+
+	return list, err
+}
+
+var catSortColumns = map[string]sortColumn[models.Cat]{
+	"name": {
+		value: func(cat models.Cat) string { return cat.Name },
+		order: byString,
+	},
+	"salary": {
+		value: func(cat models.Cat) string { return cat.Salary.String() },
+		order: byMoney,
+	},
+	"years_of_experience": {
+		value: func(cat models.Cat) string { return strconv.FormatUint(uint64(cat.YearsOfExperience), 10) },
+		order: byInt,
+	},
+	"created_at": {
+		value: func(cat models.Cat) string { return cat.CreatedAt.Format(time.RFC3339Nano) },
+		order: byTime,
+	},
+}
+
+func (db *CatRepository) QueryCats(ctx context.Context, filter models.CatFilter) (models.Page[models.Cat], error) {
+	var rows []models.Cat
+
+	err := db.run(ctx, func(t *tables) error {
+		for _, cat := range t.cats.rows {
+			if matchCat(cat, filter) {
+				rows = append(rows, cat)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return models.Page[models.Cat]{List: []models.Cat{}}, err
+	}
+
+	column, desc := parseSort(filter.Sort, catSortColumns)
+
+	return paginate(rows, func(cat models.Cat) uint { return cat.ID }, column, desc, filter.After, filter.Limit)
+}
+
+func matchCat(cat models.Cat, filter models.CatFilter) bool {
+	switch {
+	case !filter.IncludeFired && cat.IsFired():
+		return false
+	case filter.Breed != "" && cat.Breed != filter.Breed:
+		return false
+	case filter.MinSalary > 0 && cat.Salary < filter.MinSalary:
+		return false
+	case filter.MaxSalary > 0 && cat.Salary > filter.MaxSalary:
+		return false
+	case filter.MinExperience != nil && cat.YearsOfExperience < *filter.MinExperience:
+		return false
+	case filter.MaxExperience != nil && cat.YearsOfExperience > *filter.MaxExperience:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,93 @@
+package memstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"spy_cat_agency/internal/breeds"
+	"spy_cat_agency/internal/models"
+	"time"
+)
+
+// migration takes the data of a store from the previous version to version.
+// Unlike Postgres there is no schema to create, a table exists once it has
+// rows, so migrations seed data and rewrite rows whose shape changes.
+// Released migrations are never edited; new ones are appended.
+type migration struct {
+	version     uint
+	description string
+	up          func(s *Store, t *tables) error
+}
+
+var migrations = []migration{
+	{1, "seed breeds", seedBreeds},
+	{2, "seed policy", seedPolicy},
+}
+
+// LatestVersion returns the version the migrations of this build bring a
+// store to.
+func LatestVersion() uint {
+	return migrations[len(migrations)-1].version
+}
+
+// Migrate applies the migrations the store has not had yet, each committed
+// with the version it reaches. A store written by a newer build is refused.
+func (s *Store) Migrate() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	latest := LatestVersion()
+	if s.version > latest {
+		return fmt.Errorf("store is at version %d, this build knows up to %d", s.version, latest)
+	}
+
+	for _, m := range migrations {
+		if m.version <= s.version {
+			continue
+		}
+
+		previous := s.version
+		if err := m.up(s, s.data); err != nil {
+			s.rollback()
+			return fmt.Errorf("migration %d (%s): %w", m.version, m.description, err)
+		}
+
+		s.version = m.version
+		if err := s.commit(); err != nil {
+			s.version = previous
+			return fmt.Errorf("migration %d (%s): %w", m.version, m.description, err)
+		}
+	}
+
+	return nil
+}
+
+// Version returns the version of the last migration applied to the store.
+func (s *Store) Version() uint {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.version
+}
+
+func seedBreeds(s *Store, t *tables) error {
+	list, err := breeds.Bundled()
+	if err != nil {
+		return err
+	}
+
+	for _, breed := range list {
+		t.breeds.put(s.next("breeds"), breed)
+	}
+
+	return nil
+}
+
+func seedPolicy(s *Store, t *tables) error {
+	document, err := json.Marshal(models.DefaultPolicy())
+	if err != nil {
+		return err
+	}
+
+	t.policies.put(policyID, policy{Document: document, UpdatedAt: time.Now().Truncate(time.Microsecond)})
+	return nil
+}
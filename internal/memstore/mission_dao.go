@@ -0,0 +1,644 @@
+package memstore
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	appErrors "spy_cat_agency/internal/appErorrs"
+	"spy_cat_agency/internal/models"
+	"strconv"
+	"time"
+)
+
+type MissionRepository struct {
+	*Store
+}
+
+func NewMissionRepository(store *Store) *MissionRepository {
+	return &MissionRepository{
+		store,
+	}
+}
+
+// isOpen reports whether the mission may still become overdue.
+func isOpen(status models.MissionStatus) bool {
+	return status == models.MissionDraft || status.IsActive()
+}
+
+// withTargets returns a copy of the mission with its targets, ordered by id.
+func withTargets(t *tables, mission models.Mission) models.Mission {
+	mission.TargetList = make([]models.Target, 0)
+	for _, target := range t.targets.rows {
+		if target.MissionID == mission.ID {
+			mission.TargetList = append(mission.TargetList, target)
+		}
+	}
+
+	slices.SortFunc(mission.TargetList, func(a, b models.Target) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return mission
+}
+
+// AddMission inserts a draft mission with its targets. Assigning a cat is a
+// separate transition.
+func (db *MissionRepository) AddMission(ctx context.Context, mission models.Mission) (*models.Mission, error) {
+	var res models.Mission
+
+	err := db.run(ctx, func(t *tables) error {
+		if mission.Priority.Rank() == 0 {
+			return violation("MissionRepository.AddMission", "missions_priority_check")
+		}
+
+		res = models.Mission{
+			ID:        db.next("missions"),
+			Name:      mission.Name,
+			Status:    models.MissionDraft,
+			Priority:  mission.Priority,
+			Deadline:  mission.Deadline,
+			CreatedAt: db.now(ctx),
+		}
+		t.missions.put(res.ID, res)
+
+		res.TargetList = make([]models.Target, 0, len(mission.TargetList))
+		for _, v := range mission.TargetList {
+			target, err := db.addTarget(ctx, t, res.ID, v)
+			if err != nil {
+				return err
+			}
+			res.TargetList = append(res.TargetList, target)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+// Assign sets the cat and moves the mission to assigned only if it is still
+// an unassigned draft.
+func (db *MissionRepository) Assign(ctx context.Context, missionId, catId uint) error {
+	return db.run(ctx, func(t *tables) error {
+		mission, ok := t.missions.get(missionId)
+		if !ok || mission.CatId != nil || mission.Status != models.MissionDraft {
+			return appErrors.ErrMissionAlreadyAssigned
+		}
+
+		if _, ok := t.cats.get(catId); !ok {
+			return appErrors.ErrCatNotFound
+		}
+
+		mission.CatId = &catId
+		mission.Status = models.MissionAssigned
+		t.missions.put(missionId, mission)
+		return nil
+	})
+}
+
+// Unassign takes the cat off an active mission and puts the mission back to
+// draft.
+func (db *MissionRepository) Unassign(ctx context.Context, missionId uint) error {
+	return db.run(ctx, func(t *tables) error {
+		mission, ok := t.missions.get(missionId)
+		if !ok || mission.CatId == nil || !mission.Status.IsActive() {
+			return appErrors.ErrMissionNotAssigned
+		}
+
+		mission.CatId = nil
+		mission.Status = models.MissionDraft
+		t.missions.put(missionId, mission)
+		return nil
+	})
+}
+
+// Reassign hands an active mission over to another cat, keeping its status
+// and targets.
+func (db *MissionRepository) Reassign(ctx context.Context, missionId, catId uint) error {
+	return db.run(ctx, func(t *tables) error {
+		mission, ok := t.missions.get(missionId)
+		if !ok || mission.CatId == nil || !mission.Status.IsActive() {
+			return appErrors.ErrMissionNotAssigned
+		}
+
+		if _, ok := t.cats.get(catId); !ok {
+			return appErrors.ErrCatNotFound
+		}
+
+		mission.CatId = &catId
+		t.missions.put(missionId, mission)
+		return nil
+	})
+}
+
+// OpenAssignment starts a period of the cat working on the mission. A
+// mission has at most one open period.
+func (db *MissionRepository) OpenAssignment(ctx context.Context, missionId, catId uint) error {
+	return db.run(ctx, func(t *tables) error {
+		if _, ok := t.missions.get(missionId); !ok {
+			return violation("MissionRepository.OpenAssignment", "mission_assignments_mission_id_fkey")
+		}
+		if _, ok := t.cats.get(catId); !ok {
+			return violation("MissionRepository.OpenAssignment", "mission_assignments_cat_id_fkey")
+		}
+
+		for _, assignment := range t.assignments.rows {
+			if assignment.MissionID == missionId && assignment.ReleasedAt == nil {
+				return violation("MissionRepository.OpenAssignment", "mission_assignments_open_idx")
+			}
+		}
+
+		assignment := models.MissionAssignment{
+			ID:         db.next("mission_assignments"),
+			MissionID:  missionId,
+			CatID:      catId,
+			AssignedAt: db.now(ctx),
+		}
+		t.assignments.put(assignment.ID, assignment)
+		return nil
+	})
+}
+
+// CloseAssignment ends the current assignment period of the mission, if any.
+func (db *MissionRepository) CloseAssignment(ctx context.Context, missionId uint) error {
+	return db.run(ctx, func(t *tables) error {
+		for id, assignment := range t.assignments.rows {
+			if assignment.MissionID == missionId && assignment.ReleasedAt == nil {
+				releasedAt := db.now(ctx)
+				assignment.ReleasedAt = &releasedAt
+				t.assignments.put(id, assignment)
+			}
+		}
+		return nil
+	})
+}
+
+// ListAssignments returns the assignment periods of a mission or of a cat,
+// oldest first. Zero ids are ignored.
+func (db *MissionRepository) ListAssignments(ctx context.Context, missionId, catId uint) ([]models.MissionAssignment, error) {
+	list := []models.MissionAssignment{}
+
+	err := db.run(ctx, func(t *tables) error {
+		for _, assignment := range t.assignments.rows {
+			if (missionId == 0 || assignment.MissionID == missionId) && (catId == 0 || assignment.CatID == catId) {
+				list = append(list, assignment)
+			}
+		}
+		return nil
+	})
+
+	slices.SortFunc(list, func(a, b models.MissionAssignment) int {
+		return cmp.Or(a.AssignedAt.Compare(b.AssignedAt), cmp.Compare(a.ID, b.ID))
+	})
+
+	return list, err
+}
+
+// LockMission reads the mission with its targets. Inside a transaction the
+// whole store is locked already.
+func (db *MissionRepository) LockMission(ctx context.Context, id uint) (*models.Mission, error) {
+	return db.GetMissionByID(ctx, id)
+}
+
+// LockCat reads the cat. Fired cats are reported as not found.
+func (db *MissionRepository) LockCat(ctx context.Context, catID uint) (*models.Cat, error) {
+	var res models.Cat
+
+	err := db.run(ctx, func(t *tables) error {
+		cat, ok := t.cats.get(catID)
+		if !ok || cat.IsFired() {
+			return appErrors.ErrNotFound
+		}
+		res = cat
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+func (db *MissionRepository) GetMissionByID(ctx context.Context, id uint) (*models.Mission, error) {
+	var res models.Mission
+
+	err := db.run(ctx, func(t *tables) error {
+		mission, ok := t.missions.get(id)
+		if !ok {
+			return appErrors.ErrNotFound
+		}
+		res = withTargets(t, mission)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+// CountActiveMissions returns the number of assigned and in-progress
+// missions of the cat.
+func (db *MissionRepository) CountActiveMissions(ctx context.Context, catID uint) (int, error) {
+	var count int
+
+	err := db.run(ctx, func(t *tables) error {
+		for _, mission := range t.missions.rows {
+			if mission.CatId != nil && *mission.CatId == catID && mission.Status.IsActive() {
+				count++
+			}
+		}
+		return nil
+	})
+
+	return count, err
+}
+
+// DeleteMission removes the mission together with its targets, their notes,
+// its transitions and its assignments.
+func (db *MissionRepository) DeleteMission(ctx context.Context, id uint) error {
+	return db.run(ctx, func(t *tables) error {
+		t.missions.remove(id)
+
+		for targetID, target := range t.targets.rows {
+			if target.MissionID == id {
+				deleteTarget(t, targetID)
+			}
+		}
+		for transitionID, transition := range t.transitions.rows {
+			if transition.MissionID == id {
+				t.transitions.remove(transitionID)
+			}
+		}
+		for assignmentID, assignment := range t.assignments.rows {
+			if assignment.MissionID == id {
+				t.assignments.remove(assignmentID)
+			}
+		}
+
+		return nil
+	})
+}
+
+// ListMissions returns every mission, ordered by id.
+func (db *MissionRepository) ListMissions(ctx context.Context) ([]models.Mission, error) {
+	res := make([]models.Mission, 0)
+
+	err := db.run(ctx, func(t *tables) error {
+		for _, mission := range t.missions.rows {
+			res = append(res, withTargets(t, mission))
+		}
+		return nil
+	})
+
+	slices.SortFunc(res, func(a, b models.Mission) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return res, err
+}
+
+// MarkOverdue stamps OverdueAt on the open missions whose deadline passed
+// before now and returns them, ordered by id. Missions are marked only once.
+func (db *MissionRepository) MarkOverdue(ctx context.Context, now time.Time) ([]models.Mission, error) {
+	res := make([]models.Mission, 0)
+
+	err := db.run(ctx, func(t *tables) error {
+		for id, mission := range t.missions.rows {
+			if mission.Deadline == nil || !mission.Deadline.Before(now) || mission.OverdueAt != nil || !isOpen(mission.Status) {
+				continue
+			}
+
+			overdueAt := now
+			mission.OverdueAt = &overdueAt
+			t.missions.put(id, mission)
+			res = append(res, withTargets(t, mission))
+		}
+		return nil
+	})
+
+	slices.SortFunc(res, func(a, b models.Mission) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return res, err
+}
+
+// UpdateStatus moves the mission from one status to another. It fails with
+// ErrInvalidTransition when the mission is no longer in status from.
+func (db *MissionRepository) UpdateStatus(ctx context.Context, id uint, from, to models.MissionStatus) error {
+	return db.run(ctx, func(t *tables) error {
+		if !validStatus(to) {
+			return violation("MissionRepository.UpdateStatus", "missions_status_check")
+		}
+
+		mission, ok := t.missions.get(id)
+		if !ok || mission.Status != from {
+			return appErrors.ErrInvalidTransition
+		}
+
+		mission.Status = to
+		t.missions.put(id, mission)
+		return nil
+	})
+}
+
+func validStatus(status models.MissionStatus) bool {
+	switch status {
+	case models.MissionDraft, models.MissionAssigned, models.MissionInProgress,
+		models.MissionCompleted, models.MissionAborted, models.MissionFailed:
+		return true
+	}
+	return false
+}
+
+func (db *MissionRepository) AddTransition(ctx context.Context, transition models.MissionTransition) error {
+	return db.run(ctx, func(t *tables) error {
+		if _, ok := t.missions.get(transition.MissionID); !ok {
+			return violation("MissionRepository.AddTransition", "mission_transitions_mission_id_fkey")
+		}
+
+		transition.ID = db.next("mission_transitions")
+		transition.CreatedAt = db.now(ctx)
+		t.transitions.put(transition.ID, transition)
+		return nil
+	})
+}
+
+// ListTransitions returns the status history of the mission, oldest first.
+func (db *MissionRepository) ListTransitions(ctx context.Context, missionID uint) ([]models.MissionTransition, error) {
+	list := []models.MissionTransition{}
+
+	err := db.run(ctx, func(t *tables) error {
+		for _, transition := range t.transitions.rows {
+			if transition.MissionID == missionID {
+				list = append(list, transition)
+			}
+		}
+		return nil
+	})
+
+	slices.SortFunc(list, func(a, b models.MissionTransition) int {
+		return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), cmp.Compare(a.ID, b.ID))
+	})
+
+	return list, err
+}
+
+func (db *MissionRepository) GetTarget(ctx context.Context, id uint) (*models.Target, error) {
+	var res models.Target
+
+	err := db.run(ctx, func(t *tables) error {
+		target, ok := t.targets.get(id)
+		if !ok {
+			return appErrors.ErrNotFound
+		}
+		res = target
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+func (db *MissionRepository) DeleteTarget(ctx context.Context, id uint) error {
+	return db.run(ctx, func(t *tables) error {
+		if _, ok := t.targets.get(id); !ok {
+			return appErrors.ErrNotFound
+		}
+
+		deleteTarget(t, id)
+		return nil
+	})
+}
+
+// deleteTarget removes the target and its notes journal.
+func deleteTarget(t *tables, id uint) {
+	t.targets.remove(id)
+
+	for revisionID, revision := range t.noteRevisions.rows {
+		if revision.TargetID == id {
+			t.noteRevisions.remove(revisionID)
+		}
+	}
+}
+
+func (db *MissionRepository) AddTarget(ctx context.Context, missionId uint, target models.Target) (*models.Target, error) {
+	var res models.Target
+
+	err := db.run(ctx, func(t *tables) error {
+		var err error
+		res, err = db.addTarget(ctx, t, missionId, target)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+func (db *MissionRepository) addTarget(ctx context.Context, t *tables, missionId uint, target models.Target) (models.Target, error) {
+	if _, ok := t.missions.get(missionId); !ok {
+		return target, violation("MissionRepository.AddTarget", "targets_mission_id_fkey")
+	}
+	if target.Priority.Rank() == 0 {
+		return target, violation("MissionRepository.AddTarget", "targets_priority_check")
+	}
+
+	res := models.Target{
+		ID:            db.next("targets"),
+		MissionID:     missionId,
+		Name:          target.Name,
+		Country:       target.Country,
+		Notes:         target.Notes,
+		NotesRevision: target.NotesRevision,
+		Priority:      target.Priority,
+		Deadline:      target.Deadline,
+		CreatedAt:     db.now(ctx),
+	}
+	t.targets.put(res.ID, res)
+
+	return res, nil
+}
+
+// CompleteTarget marks the target as completed. A target completed already
+// fails with ErrTargetCompleted.
+func (db *MissionRepository) CompleteTarget(ctx context.Context, id uint) error {
+	return db.run(ctx, func(t *tables) error {
+		target, ok := t.targets.get(id)
+		if !ok {
+			return appErrors.ErrNotFound
+		}
+		if target.IsCompleted {
+			return appErrors.ErrTargetCompleted
+		}
+
+		target.IsCompleted = true
+		t.targets.put(id, target)
+		return nil
+	})
+}
+
+// UpdateTargetNotes replaces the notes of the target if they are still at
+// revision. A completed target fails with ErrTargetCompleted, a newer
+// revision with ErrNotesConflict.
+func (db *MissionRepository) UpdateTargetNotes(ctx context.Context, id uint, notes string, revision int) error {
+	return db.run(ctx, func(t *tables) error {
+		target, ok := t.targets.get(id)
+		if !ok {
+			return appErrors.ErrNotFound
+		}
+		if target.IsCompleted {
+			return appErrors.ErrTargetCompleted
+		}
+		if target.NotesRevision != revision {
+			return appErrors.ErrNotesConflict
+		}
+
+		target.Notes = notes
+		target.NotesRevision++
+		t.targets.put(id, target)
+		return nil
+	})
+}
+
+func (db *MissionRepository) AddNoteRevision(ctx context.Context, revision models.NoteRevision) (*models.NoteRevision, error) {
+	err := db.run(ctx, func(t *tables) error {
+		if _, ok := t.targets.get(revision.TargetID); !ok {
+			return violation("MissionRepository.AddNoteRevision", "target_notes_target_id_fkey")
+		}
+
+		for _, existing := range t.noteRevisions.rows {
+			if existing.TargetID == revision.TargetID && existing.Revision == revision.Revision {
+				return violation("MissionRepository.AddNoteRevision", "target_notes_target_id_revision_key")
+			}
+		}
+
+		revision.ID = db.next("target_notes")
+		revision.CreatedAt = db.now(ctx)
+		t.noteRevisions.put(revision.ID, revision)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &revision, nil
+}
+
+func (db *MissionRepository) GetNoteRevision(ctx context.Context, targetID uint, revision int) (*models.NoteRevision, error) {
+	var res models.NoteRevision
+
+	err := db.run(ctx, func(t *tables) error {
+		for _, existing := range t.noteRevisions.rows {
+			if existing.TargetID == targetID && existing.Revision == revision {
+				res = existing
+				return nil
+			}
+		}
+		return appErrors.ErrNotFound
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+// ListNoteRevisions returns the notes journal of the target, oldest first.
+func (db *MissionRepository) ListNoteRevisions(ctx context.Context, targetID uint) ([]models.NoteRevision, error) {
+	list := []models.NoteRevision{}
+
+	err := db.run(ctx, func(t *tables) error {
+		for _, revision := range t.noteRevisions.rows {
+			if revision.TargetID == targetID {
+				list = append(list, revision)
+			}
+		}
+		return nil
+	})
+
+	slices.SortFunc(list, func(a, b models.NoteRevision) int {
+		return cmp.Compare(a.Revision, b.Revision)
+	})
+
+	return list, err
+}
+
+var missionSortColumns = map[string]sortColumn[models.Mission]{
+	"name": {
+		value: func(mission models.Mission) string { return mission.Name },
+		order: byString,
+	},
+	"created_at": {
+		value: func(mission models.Mission) string { return mission.CreatedAt.Format(time.RFC3339Nano) },
+		order: byTime,
+	},
+	"priority": {
+		value: func(mission models.Mission) string { return strconv.Itoa(mission.Priority.Rank()) },
+		order: byInt,
+	},
+	// Missions without a deadline come after all others.
+	"deadline": {
+		value: func(mission models.Mission) string {
+			if mission.Deadline == nil {
+				return "infinity"
+			}
+			return mission.Deadline.Format(time.RFC3339Nano)
+		},
+		order: byTime,
+	},
+}
+
+func (db *MissionRepository) QueryMissions(ctx context.Context, filter models.MissionFilter) (models.Page[models.Mission], error) {
+	var rows []models.Mission
+
+	err := db.run(ctx, func(t *tables) error {
+		now := db.now(ctx)
+		for _, mission := range t.missions.rows {
+			mission = withTargets(t, mission)
+			if matchMission(mission, filter, now) {
+				rows = append(rows, mission)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return models.Page[models.Mission]{List: []models.Mission{}}, err
+	}
+
+	column, desc := parseSort(filter.Sort, missionSortColumns)
+
+	return paginate(rows, func(mission models.Mission) uint { return mission.ID }, column, desc, filter.After, filter.Limit)
+}
+
+func matchMission(mission models.Mission, filter models.MissionFilter, now time.Time) bool {
+	switch {
+	case filter.Status != "" && string(mission.Status) != filter.Status:
+		return false
+	case filter.Assigned != nil && *filter.Assigned != (mission.CatId != nil):
+		return false
+	case filter.CatID != nil && (mission.CatId == nil || *mission.CatId != *filter.CatID):
+		return false
+	case filter.Priority != "" && mission.Priority != filter.Priority:
+		return false
+	case filter.Overdue && (mission.Deadline == nil || !mission.Deadline.Before(now) || !isOpen(mission.Status)):
+		return false
+	}
+
+	if filter.TargetCountry == "" {
+		return true
+	}
+	for _, target := range mission.TargetList {
+		if target.Country == filter.TargetCountry {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package memstore
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"spy_cat_agency/internal/models"
+	"time"
+)
+
+// PayrollRepository reads the salary and employment history of all cats at
+// once, for reports that span the whole agency.
+type PayrollRepository struct {
+	*Store
+}
+
+func NewPayrollRepository(store *Store) *PayrollRepository {
+	return &PayrollRepository{
+		store,
+	}
+}
+
+// ListCatsHiredBefore returns the cats, fired or not, hired on or before the
+// day of until.
+func (db *PayrollRepository) ListCatsHiredBefore(ctx context.Context, until time.Time) ([]models.Cat, error) {
+	list := []models.Cat{}
+	end := until.AddDate(0, 0, 1)
+
+	err := db.run(ctx, func(t *tables) error {
+		for _, cat := range t.cats.rows {
+			if cat.CreatedAt.Before(end) {
+				list = append(list, cat)
+			}
+		}
+		return nil
+	})
+
+	slices.SortFunc(list, func(a, b models.Cat) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return list, err
+}
+
+// ListSalaryChanges returns the changes effective on or before until, ordered
+// by cat, effective date and the order they were recorded in.
+func (db *PayrollRepository) ListSalaryChanges(ctx context.Context, until time.Time) ([]models.SalaryChange, error) {
+	list := []models.SalaryChange{}
+	day := date(until)
+
+	err := db.run(ctx, func(t *tables) error {
+		for _, change := range t.salaryChanges.rows {
+			if !change.EffectiveFrom.After(day) {
+				list = append(list, change)
+			}
+		}
+		return nil
+	})
+
+	slices.SortFunc(list, func(a, b models.SalaryChange) int {
+		return cmp.Or(cmp.Compare(a.CatID, b.CatID), a.EffectiveFrom.Compare(b.EffectiveFrom), cmp.Compare(a.ID, b.ID))
+	})
+
+	return list, err
+}
+
+// ListEmploymentEvents returns the events that happened on or before the day
+// of until, ordered by cat and time.
+func (db *PayrollRepository) ListEmploymentEvents(ctx context.Context, until time.Time) ([]models.EmploymentEvent, error) {
+	list := []models.EmploymentEvent{}
+	end := until.AddDate(0, 0, 1)
+
+	err := db.run(ctx, func(t *tables) error {
+		for _, event := range t.employmentEvents.rows {
+			if event.CreatedAt.Before(end) {
+				list = append(list, event)
+			}
+		}
+		return nil
+	})
+
+	slices.SortFunc(list, func(a, b models.EmploymentEvent) int {
+		return cmp.Or(cmp.Compare(a.CatID, b.CatID), a.CreatedAt.Compare(b.CreatedAt), cmp.Compare(a.ID, b.ID))
+	})
+
+	return list, err
+}
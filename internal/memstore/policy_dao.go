@@ -0,0 +1,56 @@
+package memstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	appErrors "spy_cat_agency/internal/appErorrs"
+	"spy_cat_agency/internal/models"
+	"time"
+)
+
+// policyID is the id of the single row of policies.
+const policyID = 1
+
+// policy is the row of policies. The document is kept as written and
+// decoded on every fetch, like the JSONB column in Postgres.
+type policy struct {
+	Document  json.RawMessage `json:"document"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// PolicyRepository reads the policy document stored in the single row of the
+// policies table.
+type PolicyRepository struct {
+	*Store
+}
+
+func NewPolicyRepository(store *Store) *PolicyRepository {
+	return &PolicyRepository{
+		store,
+	}
+}
+
+func (db *PolicyRepository) FetchPolicy() (models.Policy, error) {
+	var document json.RawMessage
+
+	err := db.run(context.Background(), func(t *tables) error {
+		row, ok := t.policies.get(policyID)
+		if !ok {
+			return appErrors.DBError("PolicyRepository.FetchPolicy", errors.New("no policy row"))
+		}
+		document = row.Document
+		return nil
+	})
+	if err != nil {
+		return models.Policy{}, err
+	}
+
+	p := models.DefaultPolicy()
+	if err := json.Unmarshal(document, &p); err != nil {
+		return models.Policy{}, fmt.Errorf("decode policy document: %w", err)
+	}
+
+	return p, nil
+}
@@ -0,0 +1,212 @@
+package memstore
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+	appErrors "spy_cat_agency/internal/appErorrs"
+	"spy_cat_agency/internal/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// cursor has the encoding of the Postgres repositories, so a cursor stays
+// valid when the backend changes. Sort is the order the page was listed in,
+// "name" or "-name"; the value means nothing in another one.
+type cursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    uint   `json:"id"`
+}
+
+func encodeCursor(sort, value string, id uint) string {
+	data, _ := json.Marshal(cursor{Sort: sort, Value: value, ID: id})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor reads a cursor issued for a list in sort.
+func decodeCursor(s, sort string) (*cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, appErrors.ErrInvalidCursor
+	}
+
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, appErrors.ErrInvalidCursor
+	}
+
+	if c.Sort != sort {
+		return nil, appErrors.ErrInvalidCursor.WithDetail(fmt.Sprintf("cursor was issued for sort %q, not %q", c.Sort, sort))
+	}
+
+	return &c, nil
+}
+
+func pageSize(limit int) int {
+	if limit <= 0 {
+		return defaultPageSize
+	}
+	if limit > maxPageSize {
+		return maxPageSize
+	}
+	return limit
+}
+
+// ordering compares two sort values in their cursor encoding. It fails for
+// a value it cannot read, which can only come from a forged cursor.
+type ordering func(a, b string) (int, error)
+
+func byString(a, b string) (int, error) {
+	return strings.Compare(a, b), nil
+}
+
+func byInt(a, b string) (int, error) {
+	x, err := strconv.ParseInt(a, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	y, err := strconv.ParseInt(b, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return cmp.Compare(x, y), nil
+}
+
+func byMoney(a, b string) (int, error) {
+	x, err := models.ParseMoney(a)
+	if err != nil {
+		return 0, err
+	}
+	y, err := models.ParseMoney(b)
+	if err != nil {
+		return 0, err
+	}
+	return cmp.Compare(x, y), nil
+}
+
+// byTime orders timestamps, with "infinity" after all of them.
+func byTime(a, b string) (int, error) {
+	x, err := parseTime(a)
+	if err != nil {
+		return 0, err
+	}
+	y, err := parseTime(b)
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case x == nil && y == nil:
+		return 0, nil
+	case x == nil:
+		return 1, nil
+	case y == nil:
+		return -1, nil
+	}
+	return x.Compare(*y), nil
+}
+
+func parseTime(s string) (*time.Time, error) {
+	if s == "infinity" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	return &t, err
+}
+
+// sortColumn is a column a list can be ordered by. value encodes it the way
+// the Postgres repositories put it in a cursor. key is its name in the API,
+// set by parseSort.
+type sortColumn[T any] struct {
+	key   string
+	value func(row T) string
+	order ordering
+}
+
+// byID is the default order. Rows are compared by id alone.
+func byID[T any]() sortColumn[T] {
+	return sortColumn[T]{
+		key:   "id",
+		value: func(T) string { return "" },
+		order: byString,
+	}
+}
+
+// parseSort resolves "name" or "-name" against the allowed columns. An empty
+// value sorts by id ascending.
+func parseSort[T any](sort string, columns map[string]sortColumn[T]) (sortColumn[T], bool) {
+	desc := strings.HasPrefix(sort, "-")
+	key := strings.TrimPrefix(sort, "-")
+
+	column, ok := columns[key]
+	if !ok {
+		return byID[T](), desc
+	}
+	column.key = key
+
+	return column, desc
+}
+
+// paginate orders rows by the column and then by id, skips the rows up to
+// the cursor and cuts out one page. Total counts rows before the cursor is
+// applied, like the COUNT(*) of the Postgres repositories.
+func paginate[T any](rows []T, id func(T) uint, column sortColumn[T], desc bool, after string, limit int) (models.Page[T], error) {
+	page := models.Page[T]{List: []T{}, Total: len(rows)}
+
+	sort := column.key
+	if desc {
+		sort = "-" + sort
+	}
+
+	compare := func(value string, rowID uint, row T) int {
+		c, _ := column.order(value, column.value(row))
+		if c == 0 {
+			c = cmp.Compare(rowID, id(row))
+		}
+		if desc {
+			c = -c
+		}
+		return c
+	}
+
+	slices.SortFunc(rows, func(a, b T) int {
+		return compare(column.value(a), id(a), b)
+	})
+
+	if after != "" {
+		c, err := decodeCursor(after, sort)
+		if err != nil {
+			return page, err
+		}
+		if _, err := column.order(c.Value, c.Value); err != nil {
+			return page, appErrors.ErrInvalidCursor
+		}
+
+		start := len(rows)
+		for i, row := range rows {
+			if compare(c.Value, c.ID, row) < 0 {
+				start = i
+				break
+			}
+		}
+		rows = rows[start:]
+	}
+
+	if size := pageSize(limit); len(rows) > size {
+		rows = rows[:size]
+		last := rows[size-1]
+		page.NextCursor = encodeCursor(sort, column.value(last), id(last))
+	}
+	page.List = append(page.List, rows...)
+
+	return page, nil
+}
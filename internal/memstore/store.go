@@ -0,0 +1,486 @@
+// Package memstore keeps the agency's data in memory. Its repositories
+// implement the same DAO interfaces as the Postgres ones in package database
+// and follow the same rules: foreign keys are checked, deleting a mission
+// cascades to its targets and history, and ids come from sequences that, as
+// in Postgres, are not rolled back with a transaction.
+//
+// A store made with New lives only as long as the process, which is what
+// tests want. A store made with Open is kept in a bbolt file: every commit
+// writes the rows it changed, so the service can run as a single binary
+// without a database server. The whole data set is still held in memory,
+// which suits demos and small deployments.
+package memstore
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	appErrors "spy_cat_agency/internal/appErorrs"
+	"spy_cat_agency/internal/models"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// change is a row as it was before the writes that are not committed yet.
+type change[T any] struct {
+	row     T
+	existed bool
+}
+
+// table holds the rows of one table by id. Rows are kept by value and never
+// changed in place; writes go through put and remove, which remember the
+// previous rows so a rollback can restore them and a commit knows what to
+// write to the file.
+type table[T any] struct {
+	name    string
+	rows    map[uint]T
+	changed map[uint]change[T]
+}
+
+func newTable[T any](name string) table[T] {
+	return table[T]{
+		name:    name,
+		rows:    map[uint]T{},
+		changed: map[uint]change[T]{},
+	}
+}
+
+func (t *table[T]) get(id uint) (T, bool) {
+	row, ok := t.rows[id]
+	return row, ok
+}
+
+func (t *table[T]) put(id uint, row T) {
+	t.touch(id)
+	t.rows[id] = row
+}
+
+func (t *table[T]) remove(id uint) {
+	t.touch(id)
+	delete(t.rows, id)
+}
+
+func (t *table[T]) touch(id uint) {
+	if _, ok := t.changed[id]; ok {
+		return
+	}
+	row, existed := t.rows[id]
+	t.changed[id] = change[T]{row: row, existed: existed}
+}
+
+func (t *table[T]) bucket() []byte {
+	return []byte(t.name)
+}
+
+func (t *table[T]) dirty() bool {
+	return len(t.changed) > 0
+}
+
+func (t *table[T]) rollback() {
+	for id, c := range t.changed {
+		if c.existed {
+			t.rows[id] = c.row
+		} else {
+			delete(t.rows, id)
+		}
+	}
+	clear(t.changed)
+}
+
+func (t *table[T]) commit() {
+	clear(t.changed)
+}
+
+// save writes the changed rows to the table's bucket.
+func (t *table[T]) save(tx *bolt.Tx) error {
+	b, err := tx.CreateBucketIfNotExists(t.bucket())
+	if err != nil {
+		return err
+	}
+
+	for id := range t.changed {
+		row, ok := t.rows[id]
+		if !ok {
+			if err := b.Delete(key(id)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		data, err := json.Marshal(row)
+		if err != nil {
+			return fmt.Errorf("encode %s %d: %w", t.name, id, err)
+		}
+		if err := b.Put(key(id), data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// load reads every row of the table's bucket.
+func (t *table[T]) load(tx *bolt.Tx) error {
+	b := tx.Bucket(t.bucket())
+	if b == nil {
+		return nil
+	}
+
+	return b.ForEach(func(k, v []byte) error {
+		var row T
+		if err := json.Unmarshal(v, &row); err != nil {
+			return fmt.Errorf("decode %s %d: %w", t.name, binary.BigEndian.Uint64(k), err)
+		}
+		t.rows[uint(binary.BigEndian.Uint64(k))] = row
+		return nil
+	})
+}
+
+// key encodes an id so that bbolt keeps rows in id order.
+func key(id uint) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(id))
+}
+
+// anyTable is what the store needs of a table regardless of its row type.
+type anyTable interface {
+	dirty() bool
+	rollback()
+	commit()
+	save(tx *bolt.Tx) error
+	load(tx *bolt.Tx) error
+}
+
+// tables are the rows of the store. Tables are named after their Postgres
+// counterparts, and so are the buckets they are saved in.
+type tables struct {
+	cats             table[models.Cat]
+	salaryChanges    table[models.SalaryChange]
+	employmentEvents table[models.EmploymentEvent]
+	missions         table[models.Mission]
+	targets          table[models.Target]
+	transitions      table[models.MissionTransition]
+	assignments      table[models.MissionAssignment]
+	noteRevisions    table[models.NoteRevision]
+	auditEvents      table[models.AuditEvent]
+	apiKeys          table[apiKey]
+	webhooks         table[models.Webhook]
+	outboxEvents     table[outboxEvent]
+	deliveries       table[models.WebhookDelivery]
+	breeds           table[models.Breed]
+	policies         table[policy]
+}
+
+func newTables() *tables {
+	return &tables{
+		cats:             newTable[models.Cat]("cats"),
+		salaryChanges:    newTable[models.SalaryChange]("salary_changes"),
+		employmentEvents: newTable[models.EmploymentEvent]("cat_employment_events"),
+		missions:         newTable[models.Mission]("missions"),
+		targets:          newTable[models.Target]("targets"),
+		transitions:      newTable[models.MissionTransition]("mission_transitions"),
+		assignments:      newTable[models.MissionAssignment]("mission_assignments"),
+		noteRevisions:    newTable[models.NoteRevision]("target_notes"),
+		auditEvents:      newTable[models.AuditEvent]("audit_events"),
+		apiKeys:          newTable[apiKey]("api_keys"),
+		webhooks:         newTable[models.Webhook]("webhooks"),
+		outboxEvents:     newTable[outboxEvent]("outbox_events"),
+		deliveries:       newTable[models.WebhookDelivery]("webhook_deliveries"),
+		breeds:           newTable[models.Breed]("breeds"),
+		policies:         newTable[policy]("policies"),
+	}
+}
+
+func (t *tables) all() []anyTable {
+	return []anyTable{
+		&t.cats,
+		&t.salaryChanges,
+		&t.employmentEvents,
+		&t.missions,
+		&t.targets,
+		&t.transitions,
+		&t.assignments,
+		&t.noteRevisions,
+		&t.auditEvents,
+		&t.apiKeys,
+		&t.webhooks,
+		&t.outboxEvents,
+		&t.deliveries,
+		&t.breeds,
+		&t.policies,
+	}
+}
+
+var (
+	metaBucket      = []byte("meta")
+	sequencesBucket = []byte("sequences")
+	versionKey      = []byte("version")
+)
+
+// Store holds the tables shared by the repositories. A transaction holds the
+// store's lock from start to end, which serializes transactions the way row
+// locks serialize conflicting ones in Postgres.
+type Store struct {
+	mu      sync.Mutex
+	data    *tables
+	seq     map[string]uint
+	version uint
+	// saved is the version last written to the file.
+	saved uint
+	file  *bolt.DB
+}
+
+// New creates an empty store that is not saved anywhere.
+func New() *Store {
+	return &Store{
+		data: newTables(),
+		seq:  map[string]uint{},
+	}
+}
+
+// Open loads the store kept in the file at path, creating the file if it
+// does not exist. Only one process can have the file open at a time.
+// Sequences are saved with the commits, so ids taken by transactions rolled
+// back since the last commit may be handed out again after reopening.
+func Open(path string) (*Store, error) {
+	file, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, appErrors.DBError("memstore.Open", err)
+	}
+
+	s := New()
+	s.file = file
+
+	err = file.View(func(tx *bolt.Tx) error {
+		for _, t := range s.data.all() {
+			if err := t.load(tx); err != nil {
+				return err
+			}
+		}
+
+		if b := tx.Bucket(sequencesBucket); b != nil {
+			if err := b.ForEach(func(k, v []byte) error {
+				s.seq[string(k)] = uint(binary.BigEndian.Uint64(v))
+				return nil
+			}); err != nil {
+				return err
+			}
+		}
+
+		if b := tx.Bucket(metaBucket); b != nil {
+			if v := b.Get(versionKey); v != nil {
+				s.version = uint(binary.BigEndian.Uint64(v))
+				s.saved = s.version
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		file.Close()
+		return nil, appErrors.DBError("memstore.Open", err)
+	}
+
+	return s, nil
+}
+
+// Ping checks that the file of the store, if it has one, is still open.
+func (s *Store) Ping() error {
+	if s.file == nil {
+		return nil
+	}
+	if err := s.file.View(func(*bolt.Tx) error { return nil }); err != nil {
+		return appErrors.DBError("memstore.Ping", err)
+	}
+	return nil
+}
+
+// Close closes the file of the store, if it has one.
+func (s *Store) Close() error {
+	if s.file == nil {
+		return nil
+	}
+	return s.file.Close()
+}
+
+type txKey struct{}
+
+// tx is the transaction of a store in progress. now is fixed when it starts,
+// like NOW() in Postgres.
+type tx struct {
+	store *Store
+	now   time.Time
+	hooks []func()
+}
+
+func (s *Store) currentTx(ctx context.Context) (*tx, bool) {
+	t, ok := ctx.Value(txKey{}).(*tx)
+	return t, ok && t.store == s
+}
+
+// run calls fn with the tables. Inside a transaction of the store the lock is
+// already held; outside of one fn runs as a statement of its own, undone if
+// it fails.
+func (s *Store) run(ctx context.Context, fn func(t *tables) error) error {
+	if _, ok := s.currentTx(ctx); ok {
+		return fn(s.data)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := fn(s.data); err != nil {
+		s.rollback()
+		return err
+	}
+
+	return s.commit()
+}
+
+// commit makes the changes since the last commit permanent, writing them to
+// the file when the store has one. Changes that cannot be written are rolled
+// back. It must be called with the lock held.
+func (s *Store) commit() error {
+	var dirty []anyTable
+	for _, t := range s.data.all() {
+		if t.dirty() {
+			dirty = append(dirty, t)
+		}
+	}
+
+	if s.file != nil && (len(dirty) > 0 || s.version != s.saved) {
+		err := s.file.Update(func(tx *bolt.Tx) error {
+			for _, t := range dirty {
+				if err := t.save(tx); err != nil {
+					return err
+				}
+			}
+			return s.saveMeta(tx)
+		})
+		if err != nil {
+			s.rollback()
+			return appErrors.DBError("Store.commit", err)
+		}
+	}
+
+	for _, t := range dirty {
+		t.commit()
+	}
+	s.saved = s.version
+
+	return nil
+}
+
+func (s *Store) saveMeta(tx *bolt.Tx) error {
+	b, err := tx.CreateBucketIfNotExists(sequencesBucket)
+	if err != nil {
+		return err
+	}
+	for name, value := range s.seq {
+		if err := b.Put([]byte(name), key(value)); err != nil {
+			return err
+		}
+	}
+
+	b, err = tx.CreateBucketIfNotExists(metaBucket)
+	if err != nil {
+		return err
+	}
+	return b.Put(versionKey, key(s.version))
+}
+
+// rollback undoes the changes since the last commit. It must be called with
+// the lock held.
+func (s *Store) rollback() {
+	for _, t := range s.data.all() {
+		t.rollback()
+	}
+}
+
+// now returns the start of the transaction in ctx, or the current time
+// outside of one, at the microsecond precision of a TIMESTAMPTZ.
+func (s *Store) now(ctx context.Context) time.Time {
+	if t, ok := s.currentTx(ctx); ok {
+		return t.now
+	}
+	return time.Now().Truncate(time.Microsecond)
+}
+
+// next returns the next id of the table. It must be called with the lock held.
+func (s *Store) next(table string) uint {
+	s.seq[table]++
+	return s.seq[table]
+}
+
+type UnitOfWork struct {
+	*Store
+}
+
+func NewUnitOfWork(store *Store) *UnitOfWork {
+	return &UnitOfWork{
+		store,
+	}
+}
+
+// WithinTx runs fn in a transaction that is committed when fn returns nil and
+// rolled back otherwise, also when fn panics. Sequences keep their new
+// values. Nested calls join the outer transaction.
+func (s *UnitOfWork) WithinTx(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	if _, ok := s.currentTx(ctx); ok {
+		return fn(ctx)
+	}
+
+	s.mu.Lock()
+	locked := true
+	defer func() {
+		if locked {
+			s.mu.Unlock()
+		}
+	}()
+
+	defer func() {
+		if p := recover(); p != nil {
+			s.rollback()
+			panic(p)
+		}
+	}()
+
+	t := &tx{store: s.Store, now: time.Now().Truncate(time.Microsecond)}
+	if err = fn(context.WithValue(ctx, txKey{}, t)); err != nil {
+		s.rollback()
+		return err
+	}
+
+	if err = s.commit(); err != nil {
+		return err
+	}
+
+	s.mu.Unlock()
+	locked = false
+
+	for _, fn := range t.hooks {
+		fn()
+	}
+
+	return nil
+}
+
+// AfterCommit runs fn once the transaction of the store in ctx has been
+// committed, and never if it is rolled back. Outside of a transaction fn
+// runs right away.
+func (s *Store) AfterCommit(ctx context.Context, fn func()) {
+	if t, ok := s.currentTx(ctx); ok {
+		t.hooks = append(t.hooks, fn)
+		return
+	}
+
+	fn()
+}
+
+// violation reports a write the Postgres schema would reject, as the same
+// kind of error the Postgres repositories return for it.
+func violation(op, constraint string) error {
+	return appErrors.DBError(op, fmt.Errorf("violates constraint %q", constraint))
+}
@@ -0,0 +1,125 @@
+package memstore
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"spy_cat_agency/internal/models"
+	"testing"
+)
+
+func open(t *testing.T, path string) *Store {
+	t.Helper()
+
+	store, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	return store
+}
+
+func TestOpenKeepsCommittedChanges(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "store.db")
+
+	store := open(t, path)
+	cats := NewCatRepository(store)
+	uow := NewUnitOfWork(store)
+
+	kept, err := cats.Add(ctx, models.Cat{Name: "Tom", Breed: "Siamese", Salary: 100000})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rollback := errors.New("rollback")
+	err = uow.WithinTx(ctx, func(ctx context.Context) error {
+		if _, err := cats.Add(ctx, models.Cat{Name: "Felix", Breed: "Siamese", Salary: 100000}); err != nil {
+			return err
+		}
+		if err := cats.Fire(ctx, kept.ID, nil); err != nil {
+			return err
+		}
+		return rollback
+	})
+	if !errors.Is(err, rollback) {
+		t.Fatalf("WithinTx = %v, want %v", err, rollback)
+	}
+
+	// A failed statement outside of a transaction leaves no trace either.
+	if _, err := cats.AddSalaryChange(ctx, models.SalaryChange{CatID: kept.ID, Amount: 0}); err == nil {
+		t.Fatal("salary change of 0 was accepted")
+	}
+
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	store = open(t, path)
+	defer store.Close()
+	cats = NewCatRepository(store)
+
+	list, err := cats.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].ID != kept.ID || list[0].Name != "Tom" || list[0].Salary != 100000 || !list[0].CreatedAt.Equal(kept.CreatedAt) {
+		t.Fatalf("cats after reopening = %+v, want only %+v", list, kept)
+	}
+
+	next, err := cats.Add(ctx, models.Cat{Name: "Garfield", Breed: "Persian", Salary: 100000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.ID <= kept.ID {
+		t.Errorf("next cat id = %d, want more than %d", next.ID, kept.ID)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.db")
+	latest := migrations[len(migrations)-1].version
+
+	store := open(t, path)
+	if err := store.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	if store.Version() != latest {
+		t.Errorf("version = %d, want %d", store.Version(), latest)
+	}
+
+	breeds, err := NewBreedRepository(store).FetchBreeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(breeds) == 0 || breeds[0].Name != "Abyssinian" {
+		t.Errorf("breeds = %v, want the bundled list sorted by name", breeds)
+	}
+
+	if _, err := NewPolicyRepository(store).FetchPolicy(); err != nil {
+		t.Errorf("fetch policy: %v", err)
+	}
+	store.Close()
+
+	// Migrating again changes nothing.
+	store = open(t, path)
+	if store.Version() != latest {
+		t.Errorf("version after reopening = %d, want %d", store.Version(), latest)
+	}
+	if err := store.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	again, err := NewBreedRepository(store).FetchBreeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(again) != len(breeds) {
+		t.Errorf("%d breeds after migrating twice, want %d", len(again), len(breeds))
+	}
+
+	// A store from a newer build is refused.
+	store.version = latest + 1
+	if err := store.Migrate(); err == nil {
+		t.Error("store of a newer version was migrated")
+	}
+	store.Close()
+}
@@ -0,0 +1,333 @@
+package memstore
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"slices"
+	appErrors "spy_cat_agency/internal/appErorrs"
+	"spy_cat_agency/internal/events"
+	"spy_cat_agency/internal/logging"
+	"spy_cat_agency/internal/models"
+	"time"
+)
+
+// outboxEvent is a row of outbox_events: an event stored in the transaction
+// of the change it describes.
+type outboxEvent struct {
+	ID        uint            `json:"id"`
+	Type      events.Type     `json:"type"`
+	MissionID *uint           `json:"mission_id"`
+	CatID     *uint           `json:"cat_id"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// OutboxRepository is the event publisher backed by the outbox_events table.
+// Publishing in the transaction of a change means an event is stored if and
+// only if the change is committed.
+type OutboxRepository struct {
+	*Store
+}
+
+func NewOutboxRepository(store *Store) *OutboxRepository {
+	return &OutboxRepository{
+		store,
+	}
+}
+
+// Publish stores the event and queues a delivery for every webhook
+// subscribed to its type.
+func (db *OutboxRepository) Publish(ctx context.Context, event events.Event) error {
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	return db.run(ctx, func(t *tables) error {
+		stored := outboxEvent{
+			ID:        db.next("outbox_events"),
+			Type:      event.Type,
+			MissionID: event.MissionID,
+			CatID:     event.CatID,
+			Payload:   payload,
+			CreatedAt: event.OccurredAt,
+		}
+		t.outboxEvents.put(stored.ID, stored)
+
+		for _, hook := range t.webhooks.rows {
+			if len(hook.Events) > 0 && !slices.Contains(hook.Events, string(event.Type)) {
+				continue
+			}
+
+			delivery := models.WebhookDelivery{
+				ID:            db.next("webhook_deliveries"),
+				WebhookID:     hook.ID,
+				EventID:       stored.ID,
+				Status:        models.DeliveryPending,
+				NextAttemptAt: db.now(ctx),
+				CreatedAt:     db.now(ctx),
+			}
+			t.deliveries.put(delivery.ID, delivery)
+		}
+
+		return nil
+	})
+}
+
+// AfterCommitPublisher hands events to a publisher that lives outside of
+// the store, such as the in-process event bus, only once the change they
+// describe is committed.
+type AfterCommitPublisher struct {
+	store     *Store
+	publisher events.Publisher
+}
+
+func NewAfterCommitPublisher(store *Store, publisher events.Publisher) *AfterCommitPublisher {
+	return &AfterCommitPublisher{
+		store:     store,
+		publisher: publisher,
+	}
+}
+
+func (p *AfterCommitPublisher) Publish(ctx context.Context, event events.Event) error {
+	// The change may still modify what Data points at before it commits.
+	event, err := event.Snapshot()
+	if err != nil {
+		return err
+	}
+
+	p.store.AfterCommit(ctx, func() {
+		if err := p.publisher.Publish(context.WithoutCancel(ctx), event); err != nil {
+			logging.FromContext(ctx).Warn("failed to publish committed event", "event", event.Type, logging.Err(err))
+		}
+	})
+
+	return nil
+}
+
+type WebhookRepository struct {
+	*Store
+}
+
+func NewWebhookRepository(store *Store) *WebhookRepository {
+	return &WebhookRepository{
+		store,
+	}
+}
+
+// AddWebhook stores the webhook. The result is the only one that carries the
+// secret.
+func (db *WebhookRepository) AddWebhook(ctx context.Context, hook models.Webhook) (*models.Webhook, error) {
+	var res models.Webhook
+
+	err := db.run(ctx, func(t *tables) error {
+		res = models.Webhook{
+			ID:        db.next("webhooks"),
+			URL:       hook.URL,
+			Events:    slices.Clone(hook.Events),
+			Secret:    hook.Secret,
+			CreatedAt: db.now(ctx),
+		}
+		if res.Events == nil {
+			res.Events = []string{}
+		}
+		t.webhooks.put(res.ID, res)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+func (db *WebhookRepository) ListWebhooks(ctx context.Context) ([]models.Webhook, error) {
+	list := []models.Webhook{}
+
+	err := db.run(ctx, func(t *tables) error {
+		for _, hook := range t.webhooks.rows {
+			hook.Secret = ""
+			list = append(list, hook)
+		}
+		return nil
+	})
+
+	slices.SortFunc(list, func(a, b models.Webhook) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return list, err
+}
+
+func (db *WebhookRepository) GetWebhook(ctx context.Context, id uint) (*models.Webhook, error) {
+	var res models.Webhook
+
+	err := db.run(ctx, func(t *tables) error {
+		hook, ok := t.webhooks.get(id)
+		if !ok {
+			return appErrors.ErrNotFound
+		}
+		res = hook
+		res.Secret = ""
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+// DeleteWebhook removes the webhook together with its deliveries.
+func (db *WebhookRepository) DeleteWebhook(ctx context.Context, id uint) error {
+	return db.run(ctx, func(t *tables) error {
+		if _, ok := t.webhooks.get(id); !ok {
+			return appErrors.ErrNotFound
+		}
+		t.webhooks.remove(id)
+
+		for deliveryID, delivery := range t.deliveries.rows {
+			if delivery.WebhookID == id {
+				t.deliveries.remove(deliveryID)
+			}
+		}
+		return nil
+	})
+}
+
+// withEvent returns the delivery with the type of its event, and with the
+// payload too if asked for.
+func withEvent(t *tables, delivery models.WebhookDelivery, payload bool) models.WebhookDelivery {
+	event, _ := t.outboxEvents.get(delivery.EventID)
+	delivery.EventType = string(event.Type)
+	if payload {
+		delivery.Payload = event.Payload
+	}
+	return delivery
+}
+
+// QueryDeliveries lists the deliveries of a webhook, newest first, without
+// their payloads.
+func (db *WebhookRepository) QueryDeliveries(ctx context.Context, webhookID uint, filter models.DeliveryFilter) (models.Page[models.WebhookDelivery], error) {
+	var rows []models.WebhookDelivery
+
+	err := db.run(ctx, func(t *tables) error {
+		for _, delivery := range t.deliveries.rows {
+			if delivery.WebhookID != webhookID || (filter.Status != "" && string(delivery.Status) != filter.Status) {
+				continue
+			}
+			rows = append(rows, withEvent(t, delivery, false))
+		}
+		return nil
+	})
+	if err != nil {
+		return models.Page[models.WebhookDelivery]{List: []models.WebhookDelivery{}}, err
+	}
+
+	return paginate(rows, func(delivery models.WebhookDelivery) uint { return delivery.ID }, byID[models.WebhookDelivery](), true, filter.After, filter.Limit)
+}
+
+// GetDelivery returns the delivery with the payload that is sent.
+func (db *WebhookRepository) GetDelivery(ctx context.Context, id uint) (*models.WebhookDelivery, error) {
+	var res models.WebhookDelivery
+
+	err := db.run(ctx, func(t *tables) error {
+		delivery, ok := t.deliveries.get(id)
+		if !ok {
+			return appErrors.ErrNotFound
+		}
+		res = withEvent(t, delivery, true)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+// RetryDelivery queues a dead delivery again with a fresh attempt count.
+func (db *WebhookRepository) RetryDelivery(ctx context.Context, id uint) error {
+	return db.run(ctx, func(t *tables) error {
+		delivery, ok := t.deliveries.get(id)
+		if !ok || delivery.Status != models.DeliveryDead {
+			return appErrors.ErrDeliveryNotDead
+		}
+
+		delivery.Status = models.DeliveryPending
+		delivery.Attempts = 0
+		delivery.NextAttemptAt = db.now(ctx)
+		delivery.LastError = nil
+		delivery.ResponseStatus = nil
+		t.deliveries.put(id, delivery)
+		return nil
+	})
+}
+
+// ClaimDue takes up to limit pending deliveries whose time has come and
+// pushes their next attempt lease into the future, so a worker that dies
+// leaves them to be retried.
+func (db *WebhookRepository) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]models.DueDelivery, error) {
+	list := []models.DueDelivery{}
+
+	err := db.run(ctx, func(t *tables) error {
+		now := db.now(ctx)
+
+		var due []models.WebhookDelivery
+		for _, delivery := range t.deliveries.rows {
+			if delivery.Status == models.DeliveryPending && !delivery.NextAttemptAt.After(now) {
+				due = append(due, delivery)
+			}
+		}
+
+		slices.SortFunc(due, func(a, b models.WebhookDelivery) int {
+			return cmp.Or(a.NextAttemptAt.Compare(b.NextAttemptAt), cmp.Compare(a.ID, b.ID))
+		})
+		if len(due) > limit {
+			due = due[:limit]
+		}
+
+		for _, delivery := range due {
+			delivery.NextAttemptAt = now.Add(lease)
+			t.deliveries.put(delivery.ID, delivery)
+
+			hook, _ := t.webhooks.get(delivery.WebhookID)
+			list = append(list, models.DueDelivery{
+				WebhookDelivery: withEvent(t, delivery, true),
+				URL:             hook.URL,
+				Secret:          hook.Secret,
+			})
+		}
+		return nil
+	})
+
+	slices.SortFunc(list, func(a, b models.DueDelivery) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return list, err
+}
+
+// RecordAttempt stores the outcome of a delivery attempt.
+func (db *WebhookRepository) RecordAttempt(ctx context.Context, delivery models.WebhookDelivery) error {
+	return db.run(ctx, func(t *tables) error {
+		stored, ok := t.deliveries.get(delivery.ID)
+		if !ok {
+			return nil
+		}
+
+		stored.Status = delivery.Status
+		stored.Attempts = delivery.Attempts
+		stored.NextAttemptAt = delivery.NextAttemptAt
+		stored.LastError = delivery.LastError
+		stored.ResponseStatus = delivery.ResponseStatus
+		stored.DeliveredAt = delivery.DeliveredAt
+		t.deliveries.put(stored.ID, stored)
+		return nil
+	})
+}
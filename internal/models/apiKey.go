@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+type Role string
+
+const (
+	// RoleHandler is agency staff managing cats and missions.
+	RoleHandler Role = "handler"
+	// RoleAgent is a cat working in the field. An agent key is bound to CatID.
+	RoleAgent Role = "agent"
+)
+
+type APIKey struct {
+	ID        uint       `json:"id"`
+	Name      string     `json:"name" binding:"required"`
+	Role      Role       `json:"role" binding:"required,oneof=handler agent"`
+	CatID     *uint      `json:"cat_id" binding:"omitempty,gt=0"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+}
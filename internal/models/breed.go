@@ -0,0 +1,7 @@
+package models
+
+type Breed struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Origin string `json:"origin"`
+}
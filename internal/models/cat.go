@@ -3,10 +3,45 @@ package models
 import "time"
 
 type Cat struct {
-	ID                uint      `json:"id"`
-	Name              string    `json:"name" binding:"required,alpha"`
-	YearsOfExperience uint      `json:"years_of_experience" binding:"required,numeric"`
-	Breed             string    `json:"breed" binding:"required,alpha,breed"`
-	Salary            float64   `json:"salary" binding:"required,numeric"`
-	CreatedAt         time.Time `json:"created_at"`
+	ID                uint       `json:"id"`
+	Name              string     `json:"name" binding:"required,alpha"`
+	YearsOfExperience uint       `json:"years_of_experience" binding:"required,numeric"`
+	Breed             string     `json:"breed" binding:"required,alpha,breed"`
+	Salary            Money      `json:"salary" binding:"required,gt=0"`
+	Currency          string     `json:"currency" binding:"omitempty,iso4217"`
+	CreatedAt         time.Time  `json:"created_at"`
+	FiredAt           *time.Time `json:"fired_at,omitempty"`
+	FiredReason       *string    `json:"fired_reason,omitempty"`
+}
+
+// IsFired reports whether the cat has been fired and not rehired since.
+func (c *Cat) IsFired() bool {
+	return c.FiredAt != nil
+}
+
+type EmploymentEventType string
+
+const (
+	EmploymentHire   EmploymentEventType = "hire"
+	EmploymentFire   EmploymentEventType = "fire"
+	EmploymentRehire EmploymentEventType = "rehire"
+)
+
+type EmploymentEvent struct {
+	ID        uint                `json:"id"`
+	CatID     uint                `json:"cat_id"`
+	Event     EmploymentEventType `json:"event"`
+	Reason    *string             `json:"reason,omitempty"`
+	CreatedAt time.Time           `json:"created_at"`
+}
+
+// SalaryChange sets the monthly salary of a cat from EffectiveFrom until the
+// next change.
+type SalaryChange struct {
+	ID            uint      `json:"id"`
+	CatID         uint      `json:"cat_id"`
+	Amount        Money     `json:"amount"`
+	Currency      string    `json:"currency"`
+	EffectiveFrom time.Time `json:"effective_from"`
+	CreatedAt     time.Time `json:"created_at"`
 }
@@ -1,22 +1,143 @@
 package models
 
-import "time"
+import (
+	"database/sql/driver"
+	"fmt"
+	"time"
+)
+
+type MissionStatus string
+
+const (
+	MissionDraft      MissionStatus = "draft"
+	MissionAssigned   MissionStatus = "assigned"
+	MissionInProgress MissionStatus = "in_progress"
+	MissionCompleted  MissionStatus = "completed"
+	MissionAborted    MissionStatus = "aborted"
+	MissionFailed     MissionStatus = "failed"
+)
+
+// missionTransitions lists the states a mission may move to from each state.
+// Completed, aborted and failed missions are final. Unassigning the cat puts
+// an active mission back to draft.
+var missionTransitions = map[MissionStatus][]MissionStatus{
+	MissionDraft:      {MissionAssigned, MissionAborted},
+	MissionAssigned:   {MissionInProgress, MissionDraft, MissionAborted},
+	MissionInProgress: {MissionCompleted, MissionDraft, MissionAborted, MissionFailed},
+}
+
+// Next returns the states the mission may move to from s.
+func (s MissionStatus) Next() []MissionStatus {
+	next := missionTransitions[s]
+	if next == nil {
+		return []MissionStatus{}
+	}
+	return next
+}
+
+func (s MissionStatus) CanTransitionTo(next MissionStatus) bool {
+	for _, allowed := range missionTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// IsFinal reports whether no transition leaves s.
+func (s MissionStatus) IsFinal() bool {
+	return len(missionTransitions[s]) == 0
+}
+
+// IsActive reports whether a cat is working on a mission in state s.
+func (s MissionStatus) IsActive() bool {
+	return s == MissionAssigned || s == MissionInProgress
+}
+
+// Priority tells how urgent a mission or a target is. It is stored as its
+// rank, so ordering by the column orders by urgency.
+type Priority string
+
+const (
+	PriorityLow      Priority = "low"
+	PriorityNormal   Priority = "normal"
+	PriorityHigh     Priority = "high"
+	PriorityCritical Priority = "critical"
+)
+
+var priorities = []Priority{PriorityLow, PriorityNormal, PriorityHigh, PriorityCritical}
+
+// Rank is 1 for low up to 4 for critical, 0 for an unknown priority.
+func (p Priority) Rank() int {
+	for i, priority := range priorities {
+		if p == priority {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+func (p *Priority) Scan(src interface{}) error {
+	rank, ok := src.(int64)
+	if !ok || rank < 1 || int(rank) > len(priorities) {
+		return fmt.Errorf("priority: cannot scan %v", src)
+	}
+
+	*p = priorities[rank-1]
+	return nil
+}
+
+func (p Priority) Value() (driver.Value, error) {
+	rank := p.Rank()
+	if rank == 0 {
+		return nil, fmt.Errorf("priority: unknown priority %q", p)
+	}
+
+	return int64(rank), nil
+}
 
 type Mission struct {
-	ID          uint      `json:"id"`
-	Name        string    `json:"name" binding:"required,alpha"`
-	CatId       *uint     `json:"cat_id"`
-	TargetList  []Target  `json:"target_list" binding:"required"`
-	IsCompleted bool      `json:"is_completed"`
-	CreatedAt   time.Time `json:"created_at"`
+	ID         uint          `json:"id"`
+	Name       string        `json:"name" binding:"required,alpha"`
+	CatId      *uint         `json:"cat_id"`
+	TargetList []Target      `json:"target_list" binding:"required"`
+	Status     MissionStatus `json:"status"`
+	Priority   Priority      `json:"priority" binding:"omitempty,oneof=low normal high critical"`
+	Deadline   *time.Time    `json:"deadline"`
+	OverdueAt  *time.Time    `json:"overdue_at"`
+	CreatedAt  time.Time     `json:"created_at"`
+}
+
+// MissionTransition is one change of a mission's status. From is nil for the
+// creation of the mission.
+type MissionTransition struct {
+	ID        uint           `json:"id"`
+	MissionID uint           `json:"mission_id"`
+	From      *MissionStatus `json:"from"`
+	To        MissionStatus  `json:"to"`
+	Actor     string         `json:"actor"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// MissionAssignment is a period during which a cat worked on a mission.
+// ReleasedAt is nil while the cat is still on it.
+type MissionAssignment struct {
+	ID         uint       `json:"id"`
+	MissionID  uint       `json:"mission_id"`
+	CatID      uint       `json:"cat_id"`
+	AssignedAt time.Time  `json:"assigned_at"`
+	ReleasedAt *time.Time `json:"released_at"`
 }
 
 type Target struct {
-	ID          uint      `json:"id"`
-	MissionID   uint      `json:"mission_id" `
-	Name        string    `json:"name" binding:"required,alpha"`
-	Country     string    `json:"country" binding:"required,alpha"`
-	Notes       string    `json:"notes"`
-	IsCompleted bool      `json:"is_completed"`
-	CreatedAt   time.Time `json:"created_at"`
+	ID            uint       `json:"id"`
+	MissionID     uint       `json:"mission_id" `
+	Name          string     `json:"name" binding:"required,alpha"`
+	Country       string     `json:"country" binding:"required,alpha"`
+	Notes         string     `json:"notes"`
+	NotesRevision int        `json:"notes_revision"`
+	IsCompleted   bool       `json:"is_completed"`
+	Priority      Priority   `json:"priority" binding:"omitempty,oneof=low normal high critical"`
+	Deadline      *time.Time `json:"deadline"`
+	CreatedAt     time.Time  `json:"created_at"`
 }
@@ -0,0 +1,128 @@
+package models
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency is used for cats hired without an explicit currency.
+const DefaultCurrency = "USD"
+
+// Money is an exact amount in hundredths of the currency unit, so 12.34 is
+// stored as 1234. It is written to JSON as a number with two decimals and to
+// the database as a NUMERIC string, never going through float64.
+type Money int64
+
+var errMoneyFormat = errors.New("money: expected a non-negative decimal number with at most two fractional digits")
+
+// ParseMoney reads amounts like "12", "12.3" or "12.34". Salaries and costs
+// are never negative, so neither are the amounts it accepts.
+func ParseMoney(s string) (Money, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "+")
+
+	whole, frac, _ := strings.Cut(s, ".")
+	if !isDigits(whole) || len(frac) > 2 || (frac != "" && !isDigits(frac)) {
+		return 0, errMoneyFormat
+	}
+	for len(frac) < 2 {
+		frac += "0"
+	}
+
+	units, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || units > (1<<63-1)/100-1 {
+		return 0, errMoneyFormat
+	}
+	cents, _ := strconv.ParseInt(frac, 10, 64)
+
+	return Money(units*100 + cents), nil
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func (m Money) String() string {
+	sign := ""
+	v := int64(m)
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+
+	return fmt.Sprintf("%s%d.%02d", sign, v/100, v%100)
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON accepts both 12.34 and "12.34".
+func (m *Money) UnmarshalJSON(data []byte) error {
+	res, err := ParseMoney(strings.Trim(string(data), `"`))
+	if err != nil {
+		return err
+	}
+
+	*m = res
+	return nil
+}
+
+// UnmarshalParam lets gin bind Money from query parameters.
+func (m *Money) UnmarshalParam(param string) error {
+	res, err := ParseMoney(param)
+	if err != nil {
+		return err
+	}
+
+	*m = res
+	return nil
+}
+
+func (m *Money) Scan(src interface{}) error {
+	var s string
+
+	switch v := src.(type) {
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	case int64:
+		*m = Money(v * 100)
+		return nil
+	default:
+		return fmt.Errorf("money: cannot scan %T", src)
+	}
+
+	res, err := ParseMoney(s)
+	if err != nil {
+		return err
+	}
+
+	*m = res
+	return nil
+}
+
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+// Prorate returns m * num / den rounded half away from zero to a whole cent.
+func (m Money) Prorate(num, den int64) Money {
+	v := int64(m) * num
+	if v < 0 {
+		return -Money((-v*2 + den) / (den * 2))
+	}
+
+	return Money((v*2 + den) / (den * 2))
+}
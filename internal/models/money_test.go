@@ -0,0 +1,120 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Money
+		wantErr bool
+	}{
+		{in: "12", want: 1200},
+		{in: "12.3", want: 1230},
+		{in: "12.34", want: 1234},
+		{in: " 0.05 ", want: 5},
+		{in: "+7", want: 700},
+		{in: "12.345", wantErr: true},
+		{in: "0.001", wantErr: true},
+		{in: "-12.34", wantErr: true},
+		{in: "-0", wantErr: true},
+		{in: "", wantErr: true},
+		{in: ".5", wantErr: true},
+		{in: "12.3a", wantErr: true},
+		{in: "1e3", wantErr: true},
+		{in: "12,34", wantErr: true},
+		{in: "99999999999999999999", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseMoney(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseMoney(%q) = %s, want an error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseMoney(%q) = %d, %v, want %d", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestMoneyProrate(t *testing.T) {
+	tests := []struct {
+		m        Money
+		num, den int64
+		want     Money
+	}{
+		{m: 310000, num: 16, den: 31, want: 160000},
+		{m: 1000, num: 1, den: 3, want: 333},
+		{m: 2000, num: 1, den: 3, want: 667},
+		{m: 5, num: 1, den: 2, want: 3},
+		{m: 1, num: 1, den: 3, want: 0},
+		{m: -5, num: 1, den: 2, want: -3},
+		{m: 0, num: 10, den: 30, want: 0},
+	}
+
+	for _, tt := range tests {
+		if got := tt.m.Prorate(tt.num, tt.den); got != tt.want {
+			t.Errorf("Money(%d).Prorate(%d, %d) = %d, want %d", tt.m, tt.num, tt.den, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyScanValue(t *testing.T) {
+	tests := []struct {
+		src     interface{}
+		want    Money
+		wantErr bool
+	}{
+		{src: []byte("12.34"), want: 1234},
+		{src: "3000.00", want: 300000},
+		{src: int64(7), want: 700},
+		{src: "12.345", wantErr: true},
+		{src: 12.34, wantErr: true},
+		{src: nil, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		var got Money
+		err := got.Scan(tt.src)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Scan(%#v) = %s, want an error", tt.src, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("Scan(%#v) = %d, %v, want %d", tt.src, got, err, tt.want)
+		}
+
+		value, err := got.Value()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var back Money
+		if err := back.Scan(value); err != nil || back != got {
+			t.Errorf("Scan(Value()) of %s = %s, %v", got, back, err)
+		}
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	data, err := json.Marshal(struct{ Salary Money }{1205})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"Salary":12.05}` {
+		t.Errorf("Marshal = %s", data)
+	}
+
+	for _, in := range []string{`12.05`, `"12.05"`} {
+		var m Money
+		if err := json.Unmarshal([]byte(in), &m); err != nil || m != 1205 {
+			t.Errorf("Unmarshal(%s) = %d, %v, want 1205", in, m, err)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"spy_cat_agency/internal/textdiff"
+	"time"
+)
+
+// NoteRevision is one entry of a target's notes journal: the whole text of
+// the notes as written by Author. Revisions are numbered from 1 per target
+// and never change; revision 0 stands for the empty notes a target starts
+// with.
+type NoteRevision struct {
+	ID          uint      `json:"id"`
+	TargetID    uint      `json:"target_id"`
+	Revision    int       `json:"revision"`
+	Notes       string    `json:"notes"`
+	Author      string    `json:"author"`
+	AuthorKeyID *uint     `json:"author_key_id"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// NotesDiff is the line by line change of a target's notes between two
+// revisions.
+type NotesDiff struct {
+	TargetID uint            `json:"target_id"`
+	From     int             `json:"from"`
+	To       int             `json:"to"`
+	Lines    []textdiff.Line `json:"lines"`
+}
@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+type PayrollFilter struct {
+	From   time.Time `form:"from" binding:"required" time_format:"2006-01-02" time_utc:"1"`
+	To     time.Time `form:"to" binding:"required" time_format:"2006-01-02" time_utc:"1"`
+	Format string    `form:"format" binding:"omitempty,oneof=json csv"`
+}
+
+// PayrollReport is the salary cost of the agency between From and To, both
+// days included. Amounts in different currencies are never added together.
+type PayrollReport struct {
+	From   time.Time      `json:"from"`
+	To     time.Time      `json:"to"`
+	Cats   []CatPayroll   `json:"cats"`
+	Agency []PayrollMonth `json:"agency"`
+	Totals []PayrollTotal `json:"totals"`
+}
+
+type CatPayroll struct {
+	CatID    uint           `json:"cat_id"`
+	Name     string         `json:"name"`
+	Currency string         `json:"currency"`
+	Months   []PayrollMonth `json:"months"`
+	Total    Money          `json:"total"`
+}
+
+// PayrollMonth is the cost of one calendar month, formatted as "2006-01".
+// Days is the number of paid days and is only set for single cats.
+type PayrollMonth struct {
+	Month    string `json:"month"`
+	Days     int    `json:"days,omitempty"`
+	Amount   Money  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+type PayrollTotal struct {
+	Currency string `json:"currency"`
+	Amount   Money  `json:"amount"`
+}
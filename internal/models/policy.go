@@ -0,0 +1,94 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+)
+
+// DefaultRuleKey selects the rule used for priorities and breeds that have
+// no entry of their own.
+const DefaultRuleKey = "default"
+
+// Policy holds the business rules that departments may tune without a
+// release. The zero values of the maps mean "no restriction".
+type Policy struct {
+	TargetsPerMission       TargetRange            `json:"targets_per_mission"`
+	MaxActiveMissionsPerCat int                    `json:"max_active_missions_per_cat"`
+	MinExperienceByPriority map[string]uint        `json:"min_experience_by_priority"`
+	SalaryRangeByBreed      map[string]SalaryRange `json:"salary_range_by_breed"`
+}
+
+type TargetRange struct {
+	Min int `json:"min"`
+	Max int `json:"max"`
+}
+
+// SalaryRange bounds the monthly salary of a breed. It only applies to cats
+// paid in Currency.
+type SalaryRange struct {
+	Min      Money  `json:"min"`
+	Max      Money  `json:"max"`
+	Currency string `json:"currency"`
+}
+
+// DefaultPolicy is the set of rules the agency used before they became
+// configurable.
+func DefaultPolicy() Policy {
+	return Policy{
+		TargetsPerMission:       TargetRange{Min: 1, Max: 3},
+		MaxActiveMissionsPerCat: 1,
+		MinExperienceByPriority: map[string]uint{},
+		SalaryRangeByBreed:      map[string]SalaryRange{},
+	}
+}
+
+func (p Policy) Validate() error {
+	if p.TargetsPerMission.Min < 1 || p.TargetsPerMission.Max < p.TargetsPerMission.Min {
+		return errors.New("targets_per_mission needs 1 <= min <= max")
+	}
+
+	if p.MaxActiveMissionsPerCat < 1 {
+		return errors.New("max_active_missions_per_cat must be at least 1")
+	}
+
+	for breed, r := range p.SalaryRangeByBreed {
+		if r.Min < 0 || (r.Max != 0 && r.Max < r.Min) {
+			return fmt.Errorf("salary_range_by_breed[%s] needs 0 <= min <= max", breed)
+		}
+		if r.Currency == "" {
+			return fmt.Errorf("salary_range_by_breed[%s] has no currency", breed)
+		}
+	}
+
+	return nil
+}
+
+// MinExperienceFor returns the years of experience a cat needs for a mission
+// of the given priority.
+func (p Policy) MinExperienceFor(priority string) uint {
+	if years, ok := p.MinExperienceByPriority[priority]; ok {
+		return years
+	}
+
+	return p.MinExperienceByPriority[DefaultRuleKey]
+}
+
+// SalaryRangeFor returns the salary range of the breed, if one is configured.
+func (p Policy) SalaryRangeFor(breed string) (SalaryRange, bool) {
+	if r, ok := p.SalaryRangeByBreed[breed]; ok {
+		return r, true
+	}
+
+	r, ok := p.SalaryRangeByBreed[DefaultRuleKey]
+	return r, ok
+}
+
+// Contains reports whether amount in currency is allowed. A zero Max means
+// there is no upper bound.
+func (r SalaryRange) Contains(amount Money, currency string) bool {
+	if currency != r.Currency {
+		return true
+	}
+
+	return amount >= r.Min && (r.Max == 0 || amount <= r.Max)
+}
@@ -0,0 +1,35 @@
+package models
+
+// Page is one slice of a cursor-paginated list. NextCursor is empty on the
+// last page.
+type Page[T any] struct {
+	List       []T    `json:"list"`
+	NextCursor string `json:"next_cursor"`
+	Total      int    `json:"total"`
+}
+
+type CatFilter struct {
+	Breed         string `form:"breed"`
+	MinSalary     Money  `form:"min_salary" binding:"omitempty,gte=0"`
+	MaxSalary     Money  `form:"max_salary" binding:"omitempty,gte=0"`
+	MinExperience *uint  `form:"min_experience"`
+	MaxExperience *uint  `form:"max_experience"`
+	IncludeFired  bool   `form:"include_fired"`
+	Sort          string `form:"sort" binding:"omitempty,oneof=id -id name -name salary -salary years_of_experience -years_of_experience created_at -created_at"`
+	Limit         int    `form:"limit" binding:"omitempty,gte=1,lte=100"`
+	After         string `form:"after"`
+}
+
+// MissionFilter selects missions. Overdue keeps the open missions whose
+// deadline has passed, whether or not the scheduler has marked them yet.
+type MissionFilter struct {
+	Status        string   `form:"status" binding:"omitempty,oneof=draft assigned in_progress completed aborted failed"`
+	Assigned      *bool    `form:"assigned"`
+	CatID         *uint    `form:"cat_id"`
+	TargetCountry string   `form:"target_country"`
+	Priority      Priority `form:"priority" binding:"omitempty,oneof=low normal high critical"`
+	Overdue       bool     `form:"overdue"`
+	Sort          string   `form:"sort" binding:"omitempty,oneof=id -id name -name created_at -created_at priority -priority deadline -deadline"`
+	Limit         int      `form:"limit" binding:"omitempty,gte=1,lte=100"`
+	After         string   `form:"after"`
+}
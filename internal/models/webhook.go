@@ -0,0 +1,57 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Webhook is an endpoint that receives events by POST. An empty Events list
+// subscribes to every event type. Secret signs the payloads; it is only
+// returned when the webhook is registered.
+type Webhook struct {
+	ID        uint      `json:"id"`
+	URL       string    `json:"url" binding:"required,url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliveryDelivered DeliveryStatus = "delivered"
+	// DeliveryDead is a delivery that failed too many times. It is not retried
+	// unless asked to.
+	DeliveryDead DeliveryStatus = "dead"
+)
+
+// WebhookDelivery is one event sent, or to be sent, to one webhook.
+type WebhookDelivery struct {
+	ID             uint            `json:"id"`
+	WebhookID      uint            `json:"webhook_id"`
+	EventID        uint            `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Status         DeliveryStatus  `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	LastError      *string         `json:"last_error"`
+	ResponseStatus *int            `json:"response_status"`
+	Payload        json.RawMessage `json:"payload,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at"`
+}
+
+type DeliveryFilter struct {
+	Status string `form:"status" binding:"omitempty,oneof=pending delivered dead"`
+	Limit  int    `form:"limit" binding:"omitempty,gte=1,lte=100"`
+	After  string `form:"after"`
+}
+
+// DueDelivery is a pending delivery claimed by the delivery worker, with the
+// address and secret of its webhook.
+type DueDelivery struct {
+	WebhookDelivery
+	URL    string
+	Secret string
+}
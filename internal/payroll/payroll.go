@@ -0,0 +1,202 @@
+// Package payroll computes what the agency pays its cats over a period.
+//
+// Salaries are monthly. A month in which a cat is paid only for some days
+// costs salary * paid days / days in the month, and when the salary changes
+// within a month each part is weighted by its days. Amounts are rounded to the
+// cent once per cat and month.
+package payroll
+
+import (
+	"sort"
+	"spy_cat_agency/internal/models"
+	"time"
+)
+
+// Employee is the history of one cat the calculation needs. Salary must be
+// ordered by EffectiveFrom, then as recorded, and Employment by CreatedAt.
+type Employee struct {
+	Cat        models.Cat
+	Salary     []models.SalaryChange
+	Employment []models.EmploymentEvent
+}
+
+// Compute builds the report for the days from..to, both included. The times
+// are truncated to UTC days.
+func Compute(from, to time.Time, employees []Employee) models.PayrollReport {
+	from, to = day(from), day(to)
+
+	report := models.PayrollReport{
+		From:   from,
+		To:     to,
+		Cats:   []models.CatPayroll{},
+		Agency: []models.PayrollMonth{},
+		Totals: []models.PayrollTotal{},
+	}
+
+	type monthKey struct {
+		month    string
+		currency string
+	}
+	agency := map[monthKey]models.Money{}
+	totals := map[string]models.Money{}
+
+	for _, e := range employees {
+		cat := models.CatPayroll{
+			CatID:    e.Cat.ID,
+			Name:     e.Cat.Name,
+			Currency: e.Cat.Currency,
+			Months:   []models.PayrollMonth{},
+		}
+
+		employed := employmentPeriods(e.Employment, to)
+		salary := salaryPeriods(e.Salary, to)
+
+		for start := from; !start.After(to); start = firstOfMonth(start).AddDate(0, 1, 0) {
+			end := firstOfMonth(start).AddDate(0, 1, -1)
+			if end.After(to) {
+				end = to
+			}
+			daysInMonth := int64(firstOfMonth(start).AddDate(0, 1, -1).Day())
+
+			var paidDays int
+			var weighted int64
+			for _, work := range employed {
+				for _, pay := range salary {
+					days := overlap(period{start, end}, work, pay.period)
+					paidDays += days
+					weighted += int64(pay.amount) * int64(days)
+				}
+			}
+
+			if paidDays == 0 {
+				continue
+			}
+
+			amount := models.Money(weighted).Prorate(1, daysInMonth)
+			month := start.Format("2006-01")
+
+			cat.Months = append(cat.Months, models.PayrollMonth{
+				Month:    month,
+				Days:     paidDays,
+				Amount:   amount,
+				Currency: cat.Currency,
+			})
+			cat.Total += amount
+			agency[monthKey{month, cat.Currency}] += amount
+			totals[cat.Currency] += amount
+		}
+
+		if len(cat.Months) > 0 {
+			report.Cats = append(report.Cats, cat)
+		}
+	}
+
+	for key, amount := range agency {
+		report.Agency = append(report.Agency, models.PayrollMonth{Month: key.month, Amount: amount, Currency: key.currency})
+	}
+	sort.Slice(report.Agency, func(i, j int) bool {
+		a, b := report.Agency[i], report.Agency[j]
+		if a.Month != b.Month {
+			return a.Month < b.Month
+		}
+		return a.Currency < b.Currency
+	})
+
+	for currency, amount := range totals {
+		report.Totals = append(report.Totals, models.PayrollTotal{Currency: currency, Amount: amount})
+	}
+	sort.Slice(report.Totals, func(i, j int) bool {
+		return report.Totals[i].Currency < report.Totals[j].Currency
+	})
+
+	return report
+}
+
+// period is a range of days, both ends included.
+type period struct {
+	start time.Time
+	end   time.Time
+}
+
+type salaryPeriod struct {
+	period
+	amount models.Money
+}
+
+// employmentPeriods turns hire, fire and rehire events into the periods the
+// cat was employed. The day of firing is still paid.
+func employmentPeriods(events []models.EmploymentEvent, until time.Time) []period {
+	var res []period
+	var open *time.Time
+
+	for _, event := range events {
+		at := day(event.CreatedAt)
+
+		switch event.Event {
+		case models.EmploymentHire, models.EmploymentRehire:
+			if open == nil {
+				// A cat rehired on the day it was fired is not paid twice.
+				if n := len(res); n > 0 && !at.After(res[n-1].end) {
+					at = res[n-1].end.AddDate(0, 0, 1)
+				}
+				open = &at
+			}
+		case models.EmploymentFire:
+			if open != nil {
+				res = append(res, period{*open, at})
+				open = nil
+			}
+		}
+	}
+
+	if open != nil {
+		res = append(res, period{*open, until})
+	}
+
+	return res
+}
+
+// salaryPeriods returns the periods in which each salary change was in
+// effect. The last one lasts until the end of the report.
+func salaryPeriods(changes []models.SalaryChange, until time.Time) []salaryPeriod {
+	res := make([]salaryPeriod, 0, len(changes))
+
+	for i, change := range changes {
+		end := until
+		if i+1 < len(changes) {
+			end = day(changes[i+1].EffectiveFrom).AddDate(0, 0, -1)
+		}
+
+		res = append(res, salaryPeriod{period{day(change.EffectiveFrom), end}, change.Amount})
+	}
+
+	return res
+}
+
+// overlap counts the days that belong to all of periods.
+func overlap(periods ...period) int {
+	start, end := periods[0].start, periods[0].end
+	for _, p := range periods[1:] {
+		if p.start.After(start) {
+			start = p.start
+		}
+		if p.end.Before(end) {
+			end = p.end
+		}
+	}
+
+	if start.After(end) {
+		return 0
+	}
+
+	return int(end.Sub(start).Hours()/24) + 1
+}
+
+func day(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func firstOfMonth(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,191 @@
+package payroll
+
+import (
+	"fmt"
+	"spy_cat_agency/internal/models"
+	"testing"
+	"time"
+)
+
+// date reads "2006-01-02" or "2006-01-02 15:04" in UTC.
+func date(s string) time.Time {
+	layout := "2006-01-02 15:04"
+	if len(s) == len("2006-01-02") {
+		layout = "2006-01-02"
+	}
+
+	t, err := time.Parse(layout, s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func salary(amount models.Money, from string) models.SalaryChange {
+	return models.SalaryChange{Amount: amount, Currency: models.DefaultCurrency, EffectiveFrom: date(from)}
+}
+
+func employment(event models.EmploymentEventType, at string) models.EmploymentEvent {
+	return models.EmploymentEvent{Event: event, CreatedAt: date(at)}
+}
+
+func employee(id uint, salary []models.SalaryChange, employment ...models.EmploymentEvent) Employee {
+	return Employee{
+		Cat:        models.Cat{ID: id, Name: fmt.Sprintf("cat %d", id), Currency: models.DefaultCurrency},
+		Salary:     salary,
+		Employment: employment,
+	}
+}
+
+func TestCompute(t *testing.T) {
+	tests := []struct {
+		name      string
+		from, to  string
+		employees []Employee
+		// want has the months of each cat, as "month days amount".
+		want       map[uint][]string
+		wantTotal  models.Money
+		wantAgency []string
+	}{
+		{
+			name: "hired and fired in the middle of a month",
+			from: "2024-03-01", to: "2024-05-31",
+			employees: []Employee{employee(1,
+				[]models.SalaryChange{salary(310000, "2024-03-16")},
+				employment(models.EmploymentHire, "2024-03-16 09:30"),
+				employment(models.EmploymentFire, "2024-04-10 17:00"),
+			)},
+			want:       map[uint][]string{1: {"2024-03 16 1600.00", "2024-04 10 1033.33"}},
+			wantTotal:  263333,
+			wantAgency: []string{"2024-03 1600.00", "2024-04 1033.33"},
+		},
+		{
+			name: "two salary changes within a month",
+			from: "2024-01-01", to: "2024-01-31",
+			employees: []Employee{employee(1,
+				[]models.SalaryChange{salary(310000, "2024-01-01"), salary(620000, "2024-01-11"), salary(930000, "2024-01-21")},
+				employment(models.EmploymentHire, "2024-01-01"),
+			)},
+			// 10 days at 3100, 10 at 6200 and 11 at 9300 of 31.
+			want:       map[uint][]string{1: {"2024-01 31 6300.00"}},
+			wantTotal:  630000,
+			wantAgency: []string{"2024-01 6300.00"},
+		},
+		{
+			name: "raise on the hire day",
+			from: "2024-01-01", to: "2024-01-31",
+			employees: []Employee{employee(1,
+				[]models.SalaryChange{salary(310000, "2024-01-01"), salary(620000, "2024-01-01")},
+				employment(models.EmploymentHire, "2024-01-01"),
+			)},
+			// The change recorded last is in effect from the first day.
+			want:       map[uint][]string{1: {"2024-01 31 6200.00"}},
+			wantTotal:  620000,
+			wantAgency: []string{"2024-01 6200.00"},
+		},
+		{
+			name: "fired and rehired on the same day",
+			from: "2024-02-01", to: "2024-02-29",
+			employees: []Employee{employee(1,
+				[]models.SalaryChange{salary(290000, "2024-01-01")},
+				employment(models.EmploymentHire, "2024-01-01"),
+				employment(models.EmploymentFire, "2024-02-10 10:00"),
+				employment(models.EmploymentRehire, "2024-02-10 15:00"),
+			)},
+			want:       map[uint][]string{1: {"2024-02 29 2900.00"}},
+			wantTotal:  290000,
+			wantAgency: []string{"2024-02 2900.00"},
+		},
+		{
+			name: "fired and rehired later",
+			from: "2024-04-01", to: "2024-04-30",
+			employees: []Employee{employee(1,
+				[]models.SalaryChange{salary(300000, "2024-01-01")},
+				employment(models.EmploymentHire, "2024-01-01"),
+				employment(models.EmploymentFire, "2024-04-05"),
+				employment(models.EmploymentRehire, "2024-04-26"),
+			)},
+			want:       map[uint][]string{1: {"2024-04 10 1000.00"}},
+			wantTotal:  100000,
+			wantAgency: []string{"2024-04 1000.00"},
+		},
+		{
+			name: "no employment in the period",
+			from: "2024-03-01", to: "2024-03-31",
+			employees: []Employee{
+				employee(1,
+					[]models.SalaryChange{salary(300000, "2024-01-01")},
+					employment(models.EmploymentHire, "2024-01-01"),
+					employment(models.EmploymentFire, "2024-02-29"),
+				),
+				employee(2,
+					[]models.SalaryChange{salary(300000, "2024-04-01")},
+					employment(models.EmploymentHire, "2024-04-01"),
+				),
+			},
+			want: map[uint][]string{},
+		},
+		{
+			name: "agency sums the cats of a month",
+			from: "2024-04-01", to: "2024-04-30",
+			employees: []Employee{
+				employee(1, []models.SalaryChange{salary(100000, "2024-01-01")}, employment(models.EmploymentHire, "2024-01-01")),
+				employee(2, []models.SalaryChange{salary(100001, "2024-04-16")}, employment(models.EmploymentHire, "2024-04-16")),
+			},
+			// 1000.01 * 15/30 rounds up to 500.01.
+			want:       map[uint][]string{1: {"2024-04 30 1000.00"}, 2: {"2024-04 15 500.01"}},
+			wantTotal:  150001,
+			wantAgency: []string{"2024-04 1500.01"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := Compute(date(tt.from), date(tt.to), tt.employees)
+
+			if len(report.Cats) != len(tt.want) {
+				t.Fatalf("report has %d cats, want %d: %+v", len(report.Cats), len(tt.want), report.Cats)
+			}
+			for _, cat := range report.Cats {
+				var got []string
+				var total models.Money
+				for _, month := range cat.Months {
+					got = append(got, fmt.Sprintf("%s %d %s", month.Month, month.Days, month.Amount))
+					total += month.Amount
+				}
+				assertStrings(t, fmt.Sprintf("cat %d", cat.CatID), got, tt.want[cat.CatID])
+				if cat.Total != total {
+					t.Errorf("cat %d total = %s, want the sum of its months %s", cat.CatID, cat.Total, total)
+				}
+			}
+
+			var agency []string
+			for _, month := range report.Agency {
+				agency = append(agency, fmt.Sprintf("%s %s", month.Month, month.Amount))
+			}
+			assertStrings(t, "agency", agency, tt.wantAgency)
+
+			switch {
+			case tt.wantTotal == 0 && len(report.Totals) != 0:
+				t.Errorf("totals = %+v, want none", report.Totals)
+			case tt.wantTotal != 0 && (len(report.Totals) != 1 || report.Totals[0].Amount != tt.wantTotal):
+				t.Errorf("totals = %+v, want %s", report.Totals, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func assertStrings(t *testing.T, name string, got, want []string) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Errorf("%s = %q, want %q", name, got, want)
+		return
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("%s = %q, want %q", name, got, want)
+			return
+		}
+	}
+}
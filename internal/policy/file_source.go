@@ -0,0 +1,42 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"spy_cat_agency/internal/models"
+)
+
+// FileSource reads the policy from a JSON file. Rules missing from the file
+// keep their default value.
+type FileSource struct {
+	path string
+}
+
+func NewFileSource(path string) *FileSource {
+	return &FileSource{
+		path: path,
+	}
+}
+
+func (s *FileSource) FetchPolicy() (models.Policy, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return models.Policy{}, fmt.Errorf("read policy file: %w", err)
+	}
+
+	p := models.DefaultPolicy()
+	if err := json.Unmarshal(data, &p); err != nil {
+		return models.Policy{}, fmt.Errorf("decode policy file %s: %w", s.path, err)
+	}
+
+	return p, nil
+}
+
+// DefaultSource serves the built-in policy, for deployments that do not
+// configure one.
+type DefaultSource struct{}
+
+func (DefaultSource) FetchPolicy() (models.Policy, error) {
+	return models.DefaultPolicy(), nil
+}
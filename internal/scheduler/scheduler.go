@@ -0,0 +1,80 @@
+// Package scheduler runs periodic background jobs of the server.
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"spy_cat_agency/internal/logging"
+	"sync"
+	"time"
+)
+
+// Job does one round of work. A failed round is logged and the job runs
+// again at the next tick. ctx carries a logger that names the job.
+type Job func(ctx context.Context) error
+
+type job struct {
+	name     string
+	interval time.Duration
+	run      Job
+}
+
+type Scheduler struct {
+	jobs   []job
+	logger *slog.Logger
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func New(logger *slog.Logger) *Scheduler {
+	return &Scheduler{
+		logger: logger,
+	}
+}
+
+// Every registers run to be called every interval once the scheduler is
+// started. Jobs must be registered before Start.
+func (s *Scheduler) Every(name string, interval time.Duration, run Job) {
+	s.jobs = append(s.jobs, job{name: name, interval: interval, run: run})
+}
+
+// Start runs each job in its own goroutine until Stop is called.
+func (s *Scheduler) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+
+	for _, j := range s.jobs {
+		s.wg.Add(1)
+		go s.loop(ctx, j)
+	}
+}
+
+// Stop cancels the context of running jobs and waits for them to return.
+func (s *Scheduler) Stop() {
+	if s.cancel == nil {
+		return
+	}
+	s.cancel()
+	s.wg.Wait()
+}
+
+func (s *Scheduler) loop(ctx context.Context, j job) {
+	defer s.wg.Done()
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	logger := s.logger.With("job", j.name)
+	ctx = logging.WithLogger(ctx, logger)
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := j.run(ctx); err != nil && ctx.Err() == nil {
+				logger.Error("scheduled job failed", logging.Err(err))
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
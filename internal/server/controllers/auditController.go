@@ -0,0 +1,39 @@
+package controllers
+
+import (
+	"net/http"
+	"spy_cat_agency/internal/models"
+	"spy_cat_agency/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AuditController struct {
+	AuditService services.AuditService
+}
+
+func NewAuditController(auditService services.AuditService) *AuditController {
+	return &AuditController{
+		AuditService: auditService,
+	}
+}
+
+// ListEvents returns audit events newest first. from and to are RFC 3339
+// timestamps bounding created_at.
+func (c *AuditController) ListEvents(ctx *gin.Context) {
+	var filter models.AuditFilter
+
+	if err := ctx.ShouldBindQuery(&filter); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	page, err := c.AuditService.ListEvents(ctx.Request.Context(), filter)
+
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, page)
+}
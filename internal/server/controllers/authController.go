@@ -0,0 +1,120 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	appErrors "spy_cat_agency/internal/appErorrs"
+	"spy_cat_agency/internal/auth"
+	"spy_cat_agency/internal/logging"
+	"spy_cat_agency/internal/models"
+	"spy_cat_agency/internal/services"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AuthController struct {
+	AuthService services.AuthService
+}
+
+func NewAuthController(authService services.AuthService) *AuthController {
+	return &AuthController{
+		AuthService: authService,
+	}
+}
+
+// Authenticate resolves the API key sent as "Authorization: Bearer <key>" or
+// "X-API-Key: <key>" and stores it in the request context. The request
+// logger records the key from then on.
+func (c *AuthController) Authenticate(ctx *gin.Context) {
+	rawKey := ctx.GetHeader("X-API-Key")
+	if header := ctx.GetHeader("Authorization"); rawKey == "" && strings.HasPrefix(header, "Bearer ") {
+		rawKey = strings.TrimPrefix(header, "Bearer ")
+	}
+
+	key, err := c.AuthService.Authenticate(ctx.Request.Context(), rawKey)
+	if err != nil {
+		ctx.Header("WWW-Authenticate", `Bearer realm="spy-cat-agency"`)
+		respondError(ctx, err)
+		ctx.Abort()
+		return
+	}
+
+	reqCtx := auth.WithPrincipal(ctx.Request.Context(), key)
+	reqCtx = logging.WithLogger(reqCtx, logging.FromContext(reqCtx).With("api_key_id", key.ID, "role", key.Role))
+	ctx.Request = ctx.Request.WithContext(reqCtx)
+	ctx.Next()
+}
+
+// RequireRole lets the request through only for keys with one of roles.
+// Authenticate must run first.
+func (c *AuthController) RequireRole(roles ...models.Role) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		key, ok := auth.FromContext(ctx.Request.Context())
+		if !ok {
+			respondError(ctx, appErrors.ErrUnauthorized)
+			ctx.Abort()
+			return
+		}
+
+		for _, role := range roles {
+			if key.Role == role {
+				ctx.Next()
+				return
+			}
+		}
+
+		respondError(ctx, appErrors.ErrForbidden.WithDetail(fmt.Sprintf("role %q cannot access this endpoint", key.Role)))
+		ctx.Abort()
+	}
+}
+
+type IssueKeyResponse struct {
+	Key    string         `json:"key"`
+	APIKey *models.APIKey `json:"api_key"`
+}
+
+func (c *AuthController) IssueKey(ctx *gin.Context) {
+	var keyInfo models.APIKey
+
+	if err := ctx.ShouldBindJSON(&keyInfo); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	rawKey, key, err := c.AuthService.IssueKey(ctx.Request.Context(), keyInfo)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.Header("Location", fmt.Sprintf("/auth/keys/%d", key.ID))
+	ctx.JSON(http.StatusCreated, IssueKeyResponse{
+		Key:    rawKey,
+		APIKey: key,
+	})
+}
+
+func (c *AuthController) ListKeys(ctx *gin.Context) {
+	list, err := c.AuthService.ListKeys(ctx.Request.Context())
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, list)
+}
+
+func (c *AuthController) RevokeKey(ctx *gin.Context) {
+	id, ok := bindPathID(ctx)
+	if !ok {
+		return
+	}
+
+	if err := c.AuthService.RevokeKey(ctx.Request.Context(), id); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
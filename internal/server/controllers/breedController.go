@@ -0,0 +1,30 @@
+package controllers
+
+import (
+	"net/http"
+	appErrors "spy_cat_agency/internal/appErorrs"
+	"spy_cat_agency/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type BreedController struct {
+	BreedCatalog *services.BreedCatalog
+}
+
+func NewBreedController(breedCatalog *services.BreedCatalog) *BreedController {
+	return &BreedController{
+		BreedCatalog: breedCatalog,
+	}
+}
+
+func (c *BreedController) ListBreeds(ctx *gin.Context) {
+	list, err := c.BreedCatalog.List()
+
+	if err != nil {
+		respondError(ctx, appErrors.ErrBreedCatalogUnavailable.WithDetail(err.Error()))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, list)
+}
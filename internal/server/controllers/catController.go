@@ -1,25 +1,22 @@
 package controllers
 
 import (
-	"errors"
-	"log"
+	"fmt"
 	"net/http"
-	appErrors "spy_cat_agency/internal/appErorrs"
 	"spy_cat_agency/internal/models"
 	"spy_cat_agency/internal/services"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 type CatController struct {
-	CatService services.CatService
-	errorLog   *log.Logger
+	CatService services.CatService
 }
 
-func NewCatController(catService services.CatService, errorLog *log.Logger) *CatController {
+func NewCatController(catService services.CatService) *CatController {
 	return &CatController{
-		CatService: catService,
-		errorLog:   errorLog,
+		CatService: catService,
 	}
 }
 
@@ -27,116 +24,119 @@ func (c *CatController) HireCat(ctx *gin.Context) {
 	var catInfo models.Cat
 
 	if err := ctx.ShouldBindJSON(&catInfo); err != nil {
-		errMsg := "Couldn't bind request:" + err.Error()
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": errMsg,
-		})
-		c.errorLog.Println("Couldn't bind request")
+		respondBindError(ctx, err)
 		return
 	}
 
-	err := c.CatService.HireCat(catInfo)
+	cat, err := c.CatService.HireCat(ctx.Request.Context(), catInfo)
 	if err != nil {
-		var httpErr *appErrors.HttpError
-		if errors.As(err, &httpErr) {
-			ctx.JSON(httpErr.StatusCode, httpErr.JSONResponse)
-			c.errorLog.Println(httpErr.Message)
-			return
-		}
-		ctx.JSON(appErrors.ErrInternalServer.StatusCode, appErrors.ErrInternalServer.JSONResponse)
-		c.errorLog.Println(appErrors.ErrInternalServer.Message)
+		respondError(ctx, err)
 		return
 	}
 
-	ctx.Status(http.StatusOK)
+	ctx.Header("Location", fmt.Sprintf("/cats/%d", cat.ID))
+	ctx.JSON(http.StatusCreated, cat)
 }
 
 type FireCatRequest struct {
-	CatID uint `json:"cat_id" binding:"required,numeric,gt=0"`
+	CatID  uint   `json:"cat_id" binding:"required,numeric,gt=0"`
+	Reason string `json:"reason"`
 }
 
 func (c *CatController) FireCat(ctx *gin.Context) {
 	var req FireCatRequest
 
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		errMsg := "Couldn't bind request:" + err.Error()
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": errMsg,
-		})
-		c.errorLog.Println("Couldn't bind request")
+		respondBindError(ctx, err)
 		return
 	}
 
-	err := c.CatService.FireCat(req.CatID)
+	c.fireCat(ctx, req.CatID, req.Reason)
+}
+
+func (c *CatController) fireCat(ctx *gin.Context, id uint, reason string) {
+	err := c.CatService.FireCat(ctx.Request.Context(), id, reason)
 	if err != nil {
-		var httpErr *appErrors.HttpError
-		if errors.As(err, &httpErr) {
-			ctx.JSON(httpErr.StatusCode, httpErr.JSONResponse)
-			c.errorLog.Println(httpErr.Message)
-			return
-		}
-		ctx.JSON(appErrors.ErrInternalServer.StatusCode, appErrors.ErrInternalServer.JSONResponse)
-		c.errorLog.Println(appErrors.ErrInternalServer.Message)
+		respondError(ctx, err)
 		return
 	}
 	ctx.Status(http.StatusOK)
 }
 
+type RehireCatRequest struct {
+	CatID  uint   `json:"cat_id" binding:"required,numeric,gt=0"`
+	Reason string `json:"reason"`
+}
+
+func (c *CatController) RehireCat(ctx *gin.Context) {
+	var req RehireCatRequest
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	c.rehireCat(ctx, req.CatID, req.Reason)
+}
+
+func (c *CatController) rehireCat(ctx *gin.Context, id uint, reason string) {
+	cat, err := c.CatService.RehireCat(ctx.Request.Context(), id, reason)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, cat)
+}
+
 type UpdateSalaryRequest struct {
-	Salary float64 `json:"salary" binding:"required,numeric,gt=0"`
-	CatId  uint    `json:"cat_id" binding:"required,numeric,gt=0"`
+	Salary        models.Money `json:"salary" binding:"required,gt=0"`
+	CatId         uint         `json:"cat_id" binding:"required,numeric,gt=0"`
+	EffectiveFrom string       `json:"effective_from" binding:"omitempty,datetime=2006-01-02"`
 }
 
 func (c *CatController) UpdateSalary(ctx *gin.Context) {
 	var req UpdateSalaryRequest
 
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		errMsg := "Couldn't bind request:" + err.Error()
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": errMsg,
-		})
-		c.errorLog.Println("Couldn't bind request")
+		respondBindError(ctx, err)
 		return
 	}
 
-	err := c.CatService.UpdateSalary(req.CatId, req.Salary)
+	c.updateSalary(ctx, req.CatId, req.Salary, req.EffectiveFrom)
+}
+
+func (c *CatController) updateSalary(ctx *gin.Context, id uint, salary models.Money, effectiveFrom string) {
+	var from time.Time
+	if effectiveFrom != "" {
+		// The binding has already checked the format.
+		from, _ = time.Parse(time.DateOnly, effectiveFrom)
+	}
+
+	err := c.CatService.UpdateSalary(ctx.Request.Context(), id, salary, from)
 	if err != nil {
-		var httpErr *appErrors.HttpError
-		if errors.As(err, &httpErr) {
-			ctx.JSON(httpErr.StatusCode, httpErr.JSONResponse)
-			c.errorLog.Println(httpErr.Message)
-			return
-		}
-		ctx.JSON(appErrors.ErrInternalServer.StatusCode, appErrors.ErrInternalServer.JSONResponse)
-		c.errorLog.Println(appErrors.ErrInternalServer.Message)
+		respondError(ctx, err)
 		return
 	}
 	ctx.Status(http.StatusOK)
 }
 
-type ListCatsResponse struct {
-	List []models.Cat `json:"list"`
-}
-
 func (c *CatController) ListCats(ctx *gin.Context) {
-	var resp ListCatsResponse
+	var filter models.CatFilter
 
-	list, err := c.CatService.ListCats()
+	if err := ctx.ShouldBindQuery(&filter); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	page, err := c.CatService.ListCats(ctx.Request.Context(), filter)
 
 	if err != nil {
-		var httpErr *appErrors.HttpError
-		if errors.As(err, &httpErr) {
-			ctx.JSON(httpErr.StatusCode, httpErr.JSONResponse)
-			c.errorLog.Println(httpErr.Message)
-			return
-		}
-		ctx.JSON(appErrors.ErrInternalServer.StatusCode, appErrors.ErrInternalServer.JSONResponse)
-		c.errorLog.Println(appErrors.ErrInternalServer.Message)
+		respondError(ctx, err)
 		return
 	}
 
-	resp.List = list
-	ctx.JSON(http.StatusOK, list)
+	ctx.JSON(http.StatusOK, page)
 }
 
 type GetCatRequest struct {
@@ -147,27 +147,108 @@ func (c *CatController) GetCat(ctx *gin.Context) {
 	var req GetCatRequest
 
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		errMsg := "Couldn't bind request:" + err.Error()
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": errMsg,
-		})
-		c.errorLog.Println("Couldn't bind request")
+		respondBindError(ctx, err)
 		return
 	}
 
-	cat, err := c.CatService.GetCat(req.CatID)
+	c.getCat(ctx, req.CatID)
+}
+
+type includeFiredQuery struct {
+	IncludeFired bool `form:"include_fired"`
+}
+
+func (c *CatController) getCat(ctx *gin.Context, id uint) {
+	var query includeFiredQuery
+
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	cat, err := c.CatService.GetCat(ctx.Request.Context(), id, query.IncludeFired)
 
 	if err != nil {
-		var httpErr *appErrors.HttpError
-		if errors.As(err, &httpErr) {
-			ctx.JSON(httpErr.StatusCode, httpErr.JSONResponse)
-			c.errorLog.Println(httpErr.Message)
-			return
-		}
-		ctx.JSON(appErrors.ErrInternalServer.StatusCode, appErrors.ErrInternalServer.JSONResponse)
-		c.errorLog.Println(appErrors.ErrInternalServer.Message)
+		respondError(ctx, err)
 		return
 	}
 
 	ctx.JSON(http.StatusOK, cat)
 }
+
+func (c *CatController) GetCatByID(ctx *gin.Context) {
+	id, ok := bindPathID(ctx)
+	if !ok {
+		return
+	}
+
+	c.getCat(ctx, id)
+}
+
+func (c *CatController) FireCatByID(ctx *gin.Context) {
+	id, ok := bindPathID(ctx)
+	if !ok {
+		return
+	}
+
+	c.fireCat(ctx, id, ctx.Query("reason"))
+}
+
+func (c *CatController) RehireCatByID(ctx *gin.Context) {
+	id, ok := bindPathID(ctx)
+	if !ok {
+		return
+	}
+
+	c.rehireCat(ctx, id, ctx.Query("reason"))
+}
+
+func (c *CatController) EmploymentHistory(ctx *gin.Context) {
+	id, ok := bindPathID(ctx)
+	if !ok {
+		return
+	}
+
+	list, err := c.CatService.EmploymentHistory(ctx.Request.Context(), id)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, list)
+}
+
+type UpdateSalaryBody struct {
+	Salary        models.Money `json:"salary" binding:"required,gt=0"`
+	EffectiveFrom string       `json:"effective_from" binding:"omitempty,datetime=2006-01-02"`
+}
+
+func (c *CatController) UpdateSalaryByID(ctx *gin.Context) {
+	id, ok := bindPathID(ctx)
+	if !ok {
+		return
+	}
+
+	var req UpdateSalaryBody
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	c.updateSalary(ctx, id, req.Salary, req.EffectiveFrom)
+}
+
+func (c *CatController) SalaryHistory(ctx *gin.Context) {
+	id, ok := bindPathID(ctx)
+	if !ok {
+		return
+	}
+
+	list, err := c.CatService.SalaryHistory(ctx.Request.Context(), id)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, list)
+}
@@ -0,0 +1,30 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	appErrors "spy_cat_agency/internal/appErorrs"
+
+	"github.com/gin-gonic/gin"
+)
+
+const problemContentType = "application/problem+json"
+
+// respondError writes err as an application/problem+json response. Errors
+// that are not HttpErrors are reported as internal server errors. The full
+// error is attached to the request, and logged with it.
+func respondError(ctx *gin.Context, err error) {
+	var httpErr *appErrors.HttpError
+	if !errors.As(err, &httpErr) {
+		httpErr = appErrors.ErrInternalServer
+	}
+
+	ctx.Error(err)
+
+	body, _ := json.Marshal(httpErr.Problem(ctx.Request.URL.Path))
+	ctx.Data(httpErr.StatusCode, problemContentType, body)
+}
+
+func respondBindError(ctx *gin.Context, err error) {
+	respondError(ctx, appErrors.ErrInvalidRequest.WithDetail(err.Error()))
+}
@@ -0,0 +1,134 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	appErrors "spy_cat_agency/internal/appErorrs"
+	"spy_cat_agency/internal/auth"
+	"spy_cat_agency/internal/events"
+	"spy_cat_agency/internal/logging"
+	"spy_cat_agency/internal/models"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// heartbeatInterval keeps idle streams from being closed by proxies.
+const heartbeatInterval = 15 * time.Second
+
+type EventController struct {
+	Bus *events.Bus
+}
+
+func NewEventController(bus *events.Bus) *EventController {
+	return &EventController{
+		Bus: bus,
+	}
+}
+
+type StreamQuery struct {
+	MissionID   *uint  `form:"mission_id" binding:"omitempty,gt=0"`
+	CatID       *uint  `form:"cat_id" binding:"omitempty,gt=0"`
+	LastEventID string `form:"last_event_id"`
+}
+
+// Stream sends events as Server-Sent Events until the client goes away. A
+// client resuming with the Last-Event-ID header, or the last_event_id query
+// parameter, first gets the events it missed that are still buffered.
+// Agents only receive the events of their own cat. The stream is exempt from
+// the server's read and write timeouts, and ends when the bus is closed.
+func (c *EventController) Stream(ctx *gin.Context) {
+	var query StreamQuery
+
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	filter := events.Filter{MissionID: query.MissionID, CatID: query.CatID}
+	if key, ok := auth.FromContext(ctx.Request.Context()); ok && key.Role == models.RoleAgent {
+		if key.CatID == nil || (query.CatID != nil && *query.CatID != *key.CatID) {
+			respondError(ctx, appErrors.ErrForbidden.WithDetail("agents can only follow the events of their own cat"))
+			return
+		}
+		filter.CatID = key.CatID
+	}
+
+	lastEventID := ctx.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = query.LastEventID
+	}
+
+	var lastID uint64
+	if lastEventID != "" {
+		var err error
+		if lastID, err = strconv.ParseUint(lastEventID, 10, 64); err != nil {
+			respondError(ctx, appErrors.ErrInvalidRequest.WithDetail("Last-Event-ID must be an event id"))
+			return
+		}
+	}
+
+	sub, replay := c.Bus.Subscribe(filter, lastID)
+	defer sub.Close()
+
+	logger := logging.FromContext(ctx.Request.Context())
+	rc := http.NewResponseController(ctx.Writer)
+	if err := rc.SetReadDeadline(time.Time{}); err != nil {
+		logger.Warn("cannot clear the read deadline of the event stream", logging.Err(err))
+	}
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		logger.Warn("cannot clear the write deadline of the event stream", logging.Err(err))
+	}
+
+	ctx.Header("Content-Type", "text/event-stream")
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("Connection", "keep-alive")
+	ctx.Header("X-Accel-Buffering", "no")
+	ctx.Status(http.StatusOK)
+
+	w := ctx.Writer
+	fmt.Fprint(w, "retry: 3000\n\n")
+	for _, record := range replay {
+		if err := writeEvent(w, record); err != nil {
+			return
+		}
+	}
+	w.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case record, ok := <-sub.C():
+			if !ok {
+				// Too far behind, or shutting down; the client
+				// reconnects and replays.
+				return
+			}
+			if err := writeEvent(w, record); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		case <-ctx.Request.Context().Done():
+			return
+		}
+		w.Flush()
+	}
+}
+
+func writeEvent(w io.Writer, record events.Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", record.ID, record.Type, data)
+	return err
+}
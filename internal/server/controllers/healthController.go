@@ -0,0 +1,36 @@
+package controllers
+
+import (
+	"net/http"
+	"spy_cat_agency/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HealthController struct {
+	HealthService services.HealthService
+}
+
+func NewHealthController(healthService services.HealthService) *HealthController {
+	return &HealthController{
+		HealthService: healthService,
+	}
+}
+
+// Live answers GET /healthz: the process is up and serving requests.
+func (c *HealthController) Live(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Ready answers GET /readyz with the result of every check, with status 503
+// when one of them fails.
+func (c *HealthController) Ready(ctx *gin.Context) {
+	readiness := c.HealthService.Readiness(ctx.Request.Context())
+
+	status := http.StatusOK
+	if !readiness.Ready {
+		status = http.StatusServiceUnavailable
+	}
+
+	ctx.JSON(status, readiness)
+}
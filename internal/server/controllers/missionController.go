@@ -1,25 +1,24 @@
 package controllers
 
 import (
-	"errors"
-	"log"
+	"fmt"
 	"net/http"
 	appErrors "spy_cat_agency/internal/appErorrs"
 	"spy_cat_agency/internal/models"
 	"spy_cat_agency/internal/services"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
 type MissionController struct {
-	MissionService services.MissionService
-	errorLog       *log.Logger
+	MissionService services.MissionService
 }
 
-func NewMissionController(missionService services.MissionService, errorLog *log.Logger) *MissionController {
+func NewMissionController(missionService services.MissionService) *MissionController {
 	return &MissionController{
-		MissionService: missionService,
-		errorLog:       errorLog,
+		MissionService: missionService,
 	}
 }
 
@@ -27,29 +26,19 @@ func (c *MissionController) AddMission(ctx *gin.Context) {
 	var missionInfo models.Mission
 
 	if err := ctx.ShouldBindJSON(&missionInfo); err != nil {
-		errMsg := "Couldn't bind request:" + err.Error()
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": errMsg,
-		})
-		c.errorLog.Println("Couldn't bind request")
+		respondBindError(ctx, err)
 		return
 	}
 
-	err := c.MissionService.AddMission(missionInfo)
+	mission, err := c.MissionService.AddMission(ctx.Request.Context(), missionInfo)
 
 	if err != nil {
-		var httpErr *appErrors.HttpError
-		if errors.As(err, &httpErr) {
-			ctx.JSON(httpErr.StatusCode, httpErr.JSONResponse)
-			c.errorLog.Println(httpErr.Message)
-			return
-		}
-		ctx.JSON(appErrors.ErrInternalServer.StatusCode, appErrors.ErrInternalServer.JSONResponse)
-		c.errorLog.Println(appErrors.ErrInternalServer.Message)
+		respondError(ctx, err)
 		return
 	}
 
-	ctx.Status(http.StatusOK)
+	ctx.Header("Location", fmt.Sprintf("/missions/%d", mission.ID))
+	ctx.JSON(http.StatusCreated, mission)
 
 }
 
@@ -61,25 +50,18 @@ type AssignRequest struct {
 func (c *MissionController) Assign(ctx *gin.Context) {
 	var req AssignRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		errMsg := "Couldn't bind request:" + err.Error()
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": errMsg,
-		})
-		c.errorLog.Println("Couldn't bind request")
+		respondBindError(ctx, err)
 		return
 	}
 
-	err := c.MissionService.Assign(req.MissionID, req.CatID)
+	c.assign(ctx, req.MissionID, req.CatID)
+}
+
+func (c *MissionController) assign(ctx *gin.Context, missionID, catID uint) {
+	err := c.MissionService.Assign(ctx.Request.Context(), missionID, catID)
 
 	if err != nil {
-		var httpErr *appErrors.HttpError
-		if errors.As(err, &httpErr) {
-			ctx.JSON(httpErr.StatusCode, httpErr.JSONResponse)
-			c.errorLog.Println(httpErr.Message)
-			return
-		}
-		ctx.JSON(appErrors.ErrInternalServer.StatusCode, appErrors.ErrInternalServer.JSONResponse)
-		c.errorLog.Println(appErrors.ErrInternalServer.Message)
+		respondError(ctx, err)
 		return
 	}
 
@@ -94,25 +76,18 @@ type GetMissionRequest struct {
 func (c *MissionController) GetMission(ctx *gin.Context) {
 	var req GetMissionRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		errMsg := "Couldn't bind request:" + err.Error()
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": errMsg,
-		})
-		c.errorLog.Println("Couldn't bind request")
+		respondBindError(ctx, err)
 		return
 	}
 
-	mission, err := c.MissionService.GetMission(req.MissionID)
+	c.getMission(ctx, req.MissionID)
+}
+
+func (c *MissionController) getMission(ctx *gin.Context, id uint) {
+	mission, err := c.MissionService.GetMission(ctx.Request.Context(), id)
 
 	if err != nil {
-		var httpErr *appErrors.HttpError
-		if errors.As(err, &httpErr) {
-			ctx.JSON(httpErr.StatusCode, httpErr.JSONResponse)
-			c.errorLog.Println(httpErr.Message)
-			return
-		}
-		ctx.JSON(appErrors.ErrInternalServer.StatusCode, appErrors.ErrInternalServer.JSONResponse)
-		c.errorLog.Println(appErrors.ErrInternalServer.Message)
+		respondError(ctx, err)
 		return
 	}
 
@@ -126,56 +101,64 @@ type DeleteMissionRequest struct {
 func (c *MissionController) DeleteMission(ctx *gin.Context) {
 	var req DeleteMissionRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		errMsg := "Couldn't bind request:" + err.Error()
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": errMsg,
-		})
-		c.errorLog.Println("Couldn't bind request")
+		respondBindError(ctx, err)
 		return
 	}
 
-	err := c.MissionService.DeleteMission(req.MissionID)
+	c.deleteMission(ctx, req.MissionID)
+}
+
+func (c *MissionController) deleteMission(ctx *gin.Context, id uint) {
+	err := c.MissionService.DeleteMission(ctx.Request.Context(), id)
 
 	if err != nil {
-		var httpErr *appErrors.HttpError
-		if errors.As(err, &httpErr) {
-			ctx.JSON(httpErr.StatusCode, httpErr.JSONResponse)
-			c.errorLog.Println(httpErr.Message)
-			return
-		}
-		ctx.JSON(appErrors.ErrInternalServer.StatusCode, appErrors.ErrInternalServer.JSONResponse)
-		c.errorLog.Println(appErrors.ErrInternalServer.Message)
+		respondError(ctx, err)
 		return
 	}
 
 	ctx.Status(http.StatusOK)
 }
 
-type ListMissionsResponse struct {
-	List []models.Mission `json:"list"`
+func (c *MissionController) ListMissions(ctx *gin.Context) {
+	var filter models.MissionFilter
+
+	if err := ctx.ShouldBindQuery(&filter); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	page, err := c.MissionService.ListMissions(ctx.Request.Context(), filter)
+
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, page)
 }
 
-func (c *MissionController) ListMissions(ctx *gin.Context) {
-	var resp ListMissionsResponse
+// ListOverdueMissions lists the open missions past their deadline. It takes
+// the same query parameters as ListMissions.
+func (c *MissionController) ListOverdueMissions(ctx *gin.Context) {
+	var filter models.MissionFilter
+
+	if err := ctx.ShouldBindQuery(&filter); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
 
-	list, err := c.MissionService.ListMissions()
+	page, err := c.MissionService.ListOverdueMissions(ctx.Request.Context(), filter)
 
 	if err != nil {
-		var httpErr *appErrors.HttpError
-		if errors.As(err, &httpErr) {
-			ctx.JSON(httpErr.StatusCode, httpErr.JSONResponse)
-			c.errorLog.Println(httpErr.Message)
-			return
-		}
-		ctx.JSON(appErrors.ErrInternalServer.StatusCode, appErrors.ErrInternalServer.JSONResponse)
-		c.errorLog.Println(appErrors.ErrInternalServer.Message)
+		respondError(ctx, err)
 		return
 	}
 
-	resp.List = list
-	ctx.JSON(http.StatusOK, list)
+	ctx.JSON(http.StatusOK, page)
 }
 
+// UpdateMissionRequest is the body of the deprecated /mission/update route,
+// which can only complete a mission.
 type UpdateMissionRequest struct {
 	MissionID   uint `json:"mission_id" binding:"required,numeric,gt=0"`
 	IsCompleted bool `json:"is_completed" binding:"required"`
@@ -184,29 +167,22 @@ type UpdateMissionRequest struct {
 func (c *MissionController) UpdateMission(ctx *gin.Context) {
 	var req UpdateMissionRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		errMsg := "Couldn't bind request:" + err.Error()
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": errMsg,
-		})
-		c.errorLog.Println("Couldn't bind request")
+		respondBindError(ctx, err)
 		return
 	}
 
-	err := c.MissionService.UpdateMission(req.MissionID, req.IsCompleted)
+	c.transition(ctx, req.MissionID, models.MissionCompleted)
+}
+
+func (c *MissionController) transition(ctx *gin.Context, id uint, to models.MissionStatus) {
+	mission, err := c.MissionService.Transition(ctx.Request.Context(), id, to)
 
 	if err != nil {
-		var httpErr *appErrors.HttpError
-		if errors.As(err, &httpErr) {
-			ctx.JSON(httpErr.StatusCode, httpErr.JSONResponse)
-			c.errorLog.Println(httpErr.Message)
-			return
-		}
-		ctx.JSON(appErrors.ErrInternalServer.StatusCode, appErrors.ErrInternalServer.JSONResponse)
-		c.errorLog.Println(appErrors.ErrInternalServer.Message)
+		respondError(ctx, err)
 		return
 	}
 
-	ctx.Status(http.StatusOK)
+	ctx.JSON(http.StatusOK, mission)
 }
 
 type GetTargetRequest struct {
@@ -216,29 +192,22 @@ type GetTargetRequest struct {
 func (c *MissionController) GetTarget(ctx *gin.Context) {
 	var req GetTargetRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		errMsg := "Couldn't bind request:" + err.Error()
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": errMsg,
-		})
-		c.errorLog.Println("Couldn't bind request")
+		respondBindError(ctx, err)
 		return
 	}
 
-	target, err := c.MissionService.GetTarget(req.TargetID)
+	c.getTarget(ctx, req.TargetID)
+}
 
-	if err != nil {
-		var httpErr *appErrors.HttpError
-		if errors.As(err, &httpErr) {
-			ctx.JSON(httpErr.StatusCode, httpErr.JSONResponse)
-			c.errorLog.Println(httpErr.Message)
+func (c *MissionController) getTarget(ctx *gin.Context, id uint) {
+	target, err := c.MissionService.GetTarget(ctx.Request.Context(), id)
 
-			return
-		}
-		ctx.JSON(appErrors.ErrInternalServer.StatusCode, appErrors.ErrInternalServer.JSONResponse)
-		c.errorLog.Println(appErrors.ErrInternalServer.Message)
+	if err != nil {
+		respondError(ctx, err)
 		return
 	}
 
+	ctx.Header("ETag", notesETag(target.NotesRevision))
 	ctx.JSON(http.StatusOK, target)
 }
 
@@ -249,25 +218,18 @@ type DeleteTargetRequest struct {
 func (c *MissionController) DeleteTarget(ctx *gin.Context) {
 	var req DeleteTargetRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		errMsg := "Couldn't bind request:" + err.Error()
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": errMsg,
-		})
-		c.errorLog.Println("Couldn't bind request")
+		respondBindError(ctx, err)
 		return
 	}
 
-	err := c.MissionService.DeleteTarget(req.TargetID)
+	c.deleteTarget(ctx, req.TargetID)
+}
+
+func (c *MissionController) deleteTarget(ctx *gin.Context, id uint) {
+	err := c.MissionService.DeleteTarget(ctx.Request.Context(), id)
 
 	if err != nil {
-		var httpErr *appErrors.HttpError
-		if errors.As(err, &httpErr) {
-			ctx.JSON(httpErr.StatusCode, httpErr.JSONResponse)
-			c.errorLog.Println(httpErr.Message)
-			return
-		}
-		ctx.JSON(appErrors.ErrInternalServer.StatusCode, appErrors.ErrInternalServer.JSONResponse)
-		c.errorLog.Println(appErrors.ErrInternalServer.Message)
+		respondError(ctx, err)
 		return
 	}
 
@@ -282,29 +244,23 @@ type AddTargetRequest struct {
 func (c *MissionController) AddTarget(ctx *gin.Context) {
 	var req AddTargetRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		errMsg := "Couldn't bind request:" + err.Error()
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": errMsg,
-		})
-		c.errorLog.Println("Couldn't bind request")
+		respondBindError(ctx, err)
 		return
 	}
 
-	err := c.MissionService.AddTarget(req.MissionID, req.TargetObj)
+	c.addTarget(ctx, req.MissionID, req.TargetObj)
+}
+
+func (c *MissionController) addTarget(ctx *gin.Context, missionID uint, target models.Target) {
+	res, err := c.MissionService.AddTarget(ctx.Request.Context(), missionID, target)
 
 	if err != nil {
-		var httpErr *appErrors.HttpError
-		if errors.As(err, &httpErr) {
-			ctx.JSON(httpErr.StatusCode, httpErr.JSONResponse)
-			c.errorLog.Println(httpErr.Message)
-			return
-		}
-		ctx.JSON(appErrors.ErrInternalServer.StatusCode, appErrors.ErrInternalServer.JSONResponse)
-		c.errorLog.Println(appErrors.ErrInternalServer.Message)
+		respondError(ctx, err)
 		return
 	}
 
-	ctx.Status(http.StatusOK)
+	ctx.Header("Location", fmt.Sprintf("/targets/%d", res.ID))
+	ctx.JSON(http.StatusCreated, res)
 }
 
 type CompleteTargetRequest struct {
@@ -314,60 +270,389 @@ type CompleteTargetRequest struct {
 func (c *MissionController) CompleteTarget(ctx *gin.Context) {
 	var req CompleteTargetRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		errMsg := "Couldn't bind request:" + err.Error()
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": errMsg,
-		})
-		c.errorLog.Println("Couldn't bind request")
+		respondBindError(ctx, err)
 		return
 	}
 
-	err := c.MissionService.CompleteTarget(req.TargetID)
+	c.completeTarget(ctx, req.TargetID)
+}
+
+func (c *MissionController) completeTarget(ctx *gin.Context, id uint) {
+	err := c.MissionService.CompleteTarget(ctx.Request.Context(), id)
 
 	if err != nil {
-		var httpErr *appErrors.HttpError
-		if errors.As(err, &httpErr) {
-			ctx.JSON(httpErr.StatusCode, httpErr.JSONResponse)
-			c.errorLog.Println(httpErr.Message)
-			return
-		}
-		ctx.JSON(appErrors.ErrInternalServer.StatusCode, appErrors.ErrInternalServer.JSONResponse)
-		c.errorLog.Println(appErrors.ErrInternalServer.Message)
+		respondError(ctx, err)
 		return
 	}
 
 	ctx.Status(http.StatusOK)
 }
 
+// UpdateTargetNotesRequest is the body of the deprecated /target/updateNotes
+// route. Revision is optional there, for the clients written before notes
+// had revisions.
 type UpdateTargetNotesRequest struct {
 	TargetID uint   `json:"target_id" binding:"required,numeric,gt=0"`
 	Notes    string `json:"notes" binding:"required"`
+	Revision *int   `json:"revision" binding:"omitempty,gte=0"`
 }
 
 func (c *MissionController) UpdateTargetNotes(ctx *gin.Context) {
 	var req UpdateTargetNotesRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		errMsg := "Couldn't bind request:" + err.Error()
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": errMsg,
-		})
-		c.errorLog.Println("Couldn't bind request")
+		respondBindError(ctx, err)
 		return
 	}
 
-	err := c.MissionService.UpdateTargetNotes(req.TargetID, req.Notes)
+	c.updateTargetNotes(ctx, req.TargetID, req.Notes, req.Revision)
+}
+
+func (c *MissionController) updateTargetNotes(ctx *gin.Context, id uint, notes string, revision *int) {
+	target, err := c.MissionService.UpdateTargetNotes(ctx.Request.Context(), id, notes, revision)
 
 	if err != nil {
-		var httpErr *appErrors.HttpError
-		if errors.As(err, &httpErr) {
-			ctx.JSON(httpErr.StatusCode, httpErr.JSONResponse)
-			c.errorLog.Println(httpErr.Message)
-			return
-		}
-		ctx.JSON(appErrors.ErrInternalServer.StatusCode, appErrors.ErrInternalServer.JSONResponse)
-		c.errorLog.Println(appErrors.ErrInternalServer.Message)
+		respondError(ctx, err)
 		return
 	}
 
-	ctx.Status(http.StatusOK)
+	ctx.Header("ETag", notesETag(target.NotesRevision))
+	ctx.JSON(http.StatusOK, target)
+}
+
+// notesETag is the entity tag of a target, which changes with every revision
+// of its notes.
+func notesETag(revision int) string {
+	return strconv.Quote(strconv.Itoa(revision))
+}
+
+// parseIfMatch reads the notes revision from an If-Match header as sent back
+// from an ETag. "*" matches any revision and gives nil.
+func parseIfMatch(header string) (*int, error) {
+	header = strings.TrimSpace(header)
+	if header == "*" {
+		return nil, nil
+	}
+
+	revision, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(header, "W/"), `"`))
+	if err != nil || revision < 0 {
+		return nil, appErrors.ErrInvalidRequest.WithDetail("If-Match must be the ETag of the target")
+	}
+
+	return &revision, nil
+}
+
+func (c *MissionController) GetMissionByID(ctx *gin.Context) {
+	id, ok := bindPathID(ctx)
+	if !ok {
+		return
+	}
+
+	c.getMission(ctx, id)
+}
+
+func (c *MissionController) DeleteMissionByID(ctx *gin.Context) {
+	id, ok := bindPathID(ctx)
+	if !ok {
+		return
+	}
+
+	c.deleteMission(ctx, id)
+}
+
+type UpdateMissionBody struct {
+	Status models.MissionStatus `json:"status" binding:"required,oneof=in_progress completed aborted failed"`
+}
+
+func (c *MissionController) UpdateMissionByID(ctx *gin.Context) {
+	id, ok := bindPathID(ctx)
+	if !ok {
+		return
+	}
+
+	var req UpdateMissionBody
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	c.transition(ctx, id, req.Status)
+}
+
+// transitionByID serves POST /missions/:id/<action>.
+func (c *MissionController) transitionByID(ctx *gin.Context, to models.MissionStatus) {
+	id, ok := bindPathID(ctx)
+	if !ok {
+		return
+	}
+
+	c.transition(ctx, id, to)
+}
+
+func (c *MissionController) StartMission(ctx *gin.Context) {
+	c.transitionByID(ctx, models.MissionInProgress)
+}
+
+func (c *MissionController) CompleteMission(ctx *gin.Context) {
+	c.transitionByID(ctx, models.MissionCompleted)
+}
+
+func (c *MissionController) AbortMission(ctx *gin.Context) {
+	c.transitionByID(ctx, models.MissionAborted)
+}
+
+func (c *MissionController) FailMission(ctx *gin.Context) {
+	c.transitionByID(ctx, models.MissionFailed)
+}
+
+func (c *MissionController) ListTransitions(ctx *gin.Context) {
+	id, ok := bindPathID(ctx)
+	if !ok {
+		return
+	}
+
+	list, err := c.MissionService.ListTransitions(ctx.Request.Context(), id)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, list)
+}
+
+type AssignBody struct {
+	CatID uint `json:"cat_id" binding:"required,numeric,gt=0"`
+}
+
+func (c *MissionController) AssignByID(ctx *gin.Context) {
+	id, ok := bindPathID(ctx)
+	if !ok {
+		return
+	}
+
+	var req AssignBody
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	c.assign(ctx, id, req.CatID)
+}
+
+type UnassignRequest struct {
+	MissionID uint `json:"mission_id" binding:"required,numeric,gt=0"`
+}
+
+func (c *MissionController) Unassign(ctx *gin.Context) {
+	var req UnassignRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	c.unassign(ctx, req.MissionID)
+}
+
+func (c *MissionController) UnassignByID(ctx *gin.Context) {
+	id, ok := bindPathID(ctx)
+	if !ok {
+		return
+	}
+
+	c.unassign(ctx, id)
+}
+
+func (c *MissionController) unassign(ctx *gin.Context, id uint) {
+	mission, err := c.MissionService.Unassign(ctx.Request.Context(), id)
+
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, mission)
+}
+
+func (c *MissionController) Reassign(ctx *gin.Context) {
+	var req AssignRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	c.reassign(ctx, req.MissionID, req.CatID)
+}
+
+func (c *MissionController) ReassignByID(ctx *gin.Context) {
+	id, ok := bindPathID(ctx)
+	if !ok {
+		return
+	}
+
+	var req AssignBody
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	c.reassign(ctx, id, req.CatID)
+}
+
+func (c *MissionController) reassign(ctx *gin.Context, missionID, catID uint) {
+	mission, err := c.MissionService.Reassign(ctx.Request.Context(), missionID, catID)
+
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, mission)
+}
+
+func (c *MissionController) ListAssignments(ctx *gin.Context) {
+	id, ok := bindPathID(ctx)
+	if !ok {
+		return
+	}
+
+	list, err := c.MissionService.ListAssignments(ctx.Request.Context(), id)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, list)
+}
+
+// ListCatAssignments serves GET /cats/:id/assignments.
+func (c *MissionController) ListCatAssignments(ctx *gin.Context) {
+	id, ok := bindPathID(ctx)
+	if !ok {
+		return
+	}
+
+	list, err := c.MissionService.ListCatAssignments(ctx.Request.Context(), id)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, list)
+}
+
+func (c *MissionController) AddTargetToMission(ctx *gin.Context) {
+	id, ok := bindPathID(ctx)
+	if !ok {
+		return
+	}
+
+	var target models.Target
+	if err := ctx.ShouldBindJSON(&target); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	c.addTarget(ctx, id, target)
+}
+
+func (c *MissionController) GetTargetByID(ctx *gin.Context) {
+	id, ok := bindPathID(ctx)
+	if !ok {
+		return
+	}
+
+	c.getTarget(ctx, id)
+}
+
+func (c *MissionController) DeleteTargetByID(ctx *gin.Context) {
+	id, ok := bindPathID(ctx)
+	if !ok {
+		return
+	}
+
+	c.deleteTarget(ctx, id)
+}
+
+func (c *MissionController) CompleteTargetByID(ctx *gin.Context) {
+	id, ok := bindPathID(ctx)
+	if !ok {
+		return
+	}
+
+	c.completeTarget(ctx, id)
+}
+
+type UpdateTargetNotesBody struct {
+	Notes string `json:"notes" binding:"required"`
+}
+
+func (c *MissionController) UpdateTargetNotesByID(ctx *gin.Context) {
+	id, ok := bindPathID(ctx)
+	if !ok {
+		return
+	}
+
+	ifMatch := ctx.GetHeader("If-Match")
+	if ifMatch == "" {
+		respondError(ctx, appErrors.ErrPreconditionRequired.WithDetail("send the ETag of the target you edited in If-Match, or * to overwrite"))
+		return
+	}
+
+	revision, err := parseIfMatch(ifMatch)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	var req UpdateTargetNotesBody
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	c.updateTargetNotes(ctx, id, req.Notes, revision)
+}
+
+func (c *MissionController) NotesHistory(ctx *gin.Context) {
+	id, ok := bindPathID(ctx)
+	if !ok {
+		return
+	}
+
+	list, err := c.MissionService.NotesHistory(ctx.Request.Context(), id)
+
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, list)
+}
+
+type NotesDiffQuery struct {
+	From *int `form:"from" binding:"omitempty,gte=0"`
+	To   *int `form:"to" binding:"omitempty,gte=0"`
+}
+
+// NotesDiff compares two revisions of the notes, by default the latest one
+// with the one before.
+func (c *MissionController) NotesDiff(ctx *gin.Context) {
+	id, ok := bindPathID(ctx)
+	if !ok {
+		return
+	}
+
+	var query NotesDiffQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	diff, err := c.MissionService.NotesDiff(ctx.Request.Context(), id, query.From, query.To)
+
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, diff)
 }
@@ -0,0 +1,22 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+type pathID struct {
+	ID uint `uri:"id" binding:"required,gt=0"`
+}
+
+// bindPathID reads the :id path parameter. On failure it writes the 400
+// response itself and returns false.
+func bindPathID(ctx *gin.Context) (uint, bool) {
+	var uri pathID
+
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		respondBindError(ctx, err)
+		return 0, false
+	}
+
+	return uri.ID, true
+}
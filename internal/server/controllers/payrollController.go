@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"spy_cat_agency/internal/models"
+	"spy_cat_agency/internal/services"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PayrollController struct {
+	PayrollService services.PayrollService
+}
+
+func NewPayrollController(payrollService services.PayrollService) *PayrollController {
+	return &PayrollController{
+		PayrollService: payrollService,
+	}
+}
+
+// Report answers GET /reports/payroll?from=2024-01-01&to=2024-03-31. With
+// format=csv the report is sent as one row per cat and month followed by the
+// agency rows.
+func (c *PayrollController) Report(ctx *gin.Context) {
+	var filter models.PayrollFilter
+
+	if err := ctx.ShouldBindQuery(&filter); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	report, err := c.PayrollService.Report(ctx.Request.Context(), filter)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	if filter.Format != "csv" {
+		ctx.JSON(http.StatusOK, report)
+		return
+	}
+
+	filename := fmt.Sprintf("payroll_%s_%s.csv", report.From.Format("2006-01-02"), report.To.Format("2006-01-02"))
+	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	ctx.Header("Content-Type", "text/csv; charset=utf-8")
+	ctx.Status(http.StatusOK)
+
+	w := csv.NewWriter(ctx.Writer)
+	w.Write([]string{"scope", "cat_id", "cat_name", "month", "days", "amount", "currency"})
+	for _, cat := range report.Cats {
+		for _, month := range cat.Months {
+			w.Write([]string{"cat", strconv.FormatUint(uint64(cat.CatID), 10), cat.Name, month.Month, strconv.Itoa(month.Days), month.Amount.String(), month.Currency})
+		}
+	}
+	for _, month := range report.Agency {
+		w.Write([]string{"agency", "", "", month.Month, "", month.Amount.String(), month.Currency})
+	}
+	for _, total := range report.Totals {
+		w.Write([]string{"total", "", "", "", "", total.Amount.String(), total.Currency})
+	}
+	w.Flush()
+
+	if err := w.Error(); err != nil {
+		ctx.Error(err)
+	}
+}
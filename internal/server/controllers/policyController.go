@@ -0,0 +1,36 @@
+package controllers
+
+import (
+	"net/http"
+	appErrors "spy_cat_agency/internal/appErorrs"
+	"spy_cat_agency/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PolicyController struct {
+	PolicyStore *services.PolicyStore
+}
+
+func NewPolicyController(policyStore *services.PolicyStore) *PolicyController {
+	return &PolicyController{
+		PolicyStore: policyStore,
+	}
+}
+
+// GetPolicy returns the rules in force, when they were loaded and why the last
+// reload failed, if it did.
+func (c *PolicyController) GetPolicy(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, c.PolicyStore.Status())
+}
+
+// ReloadPolicy reads the policy from its source again. On failure the previous
+// rules stay in force.
+func (c *PolicyController) ReloadPolicy(ctx *gin.Context) {
+	if err := c.PolicyStore.Reload(); err != nil {
+		respondError(ctx, appErrors.ErrInvalidPolicy.WithDetail(err.Error()))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, c.PolicyStore.Status())
+}
@@ -0,0 +1,131 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"spy_cat_agency/internal/models"
+	"spy_cat_agency/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type WebhookController struct {
+	WebhookService services.WebhookService
+}
+
+func NewWebhookController(webhookService services.WebhookService) *WebhookController {
+	return &WebhookController{
+		WebhookService: webhookService,
+	}
+}
+
+// RegisterWebhook answers with the webhook including its secret, which is
+// not shown again.
+func (c *WebhookController) RegisterWebhook(ctx *gin.Context) {
+	var hookInfo models.Webhook
+
+	if err := ctx.ShouldBindJSON(&hookInfo); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	hook, err := c.WebhookService.RegisterWebhook(ctx.Request.Context(), hookInfo)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.Header("Location", fmt.Sprintf("/webhooks/%d", hook.ID))
+	ctx.JSON(http.StatusCreated, hook)
+}
+
+func (c *WebhookController) ListWebhooks(ctx *gin.Context) {
+	list, err := c.WebhookService.ListWebhooks(ctx.Request.Context())
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, list)
+}
+
+func (c *WebhookController) GetWebhook(ctx *gin.Context) {
+	id, ok := bindPathID(ctx)
+	if !ok {
+		return
+	}
+
+	hook, err := c.WebhookService.GetWebhook(ctx.Request.Context(), id)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, hook)
+}
+
+func (c *WebhookController) DeleteWebhook(ctx *gin.Context) {
+	id, ok := bindPathID(ctx)
+	if !ok {
+		return
+	}
+
+	if err := c.WebhookService.DeleteWebhook(ctx.Request.Context(), id); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func (c *WebhookController) ListDeliveries(ctx *gin.Context) {
+	id, ok := bindPathID(ctx)
+	if !ok {
+		return
+	}
+
+	var filter models.DeliveryFilter
+	if err := ctx.ShouldBindQuery(&filter); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	page, err := c.WebhookService.ListDeliveries(ctx.Request.Context(), id, filter)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, page)
+}
+
+func (c *WebhookController) GetDelivery(ctx *gin.Context) {
+	id, ok := bindPathID(ctx)
+	if !ok {
+		return
+	}
+
+	delivery, err := c.WebhookService.GetDelivery(ctx.Request.Context(), id)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, delivery)
+}
+
+// RetryDelivery queues a dead delivery again.
+func (c *WebhookController) RetryDelivery(ctx *gin.Context) {
+	id, ok := bindPathID(ctx)
+	if !ok {
+		return
+	}
+
+	delivery, err := c.WebhookService.RetryDelivery(ctx.Request.Context(), id)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, delivery)
+}
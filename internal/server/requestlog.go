@@ -0,0 +1,113 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"spy_cat_agency/internal/logging"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader carries the id of a request. A usable id sent by the client
+// or a proxy is kept, otherwise one is generated; either way it is echoed in
+// the response.
+const RequestIDHeader = "X-Request-ID"
+
+const maxRequestIDLength = 128
+
+// probeRoutes are polled by the orchestrator; their successful requests are
+// only logged at debug level.
+var probeRoutes = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+}
+
+// requestLogger puts a logger that carries the request id, method and route
+// in the request context, and logs one line per request with its status,
+// latency and the errors attached to it with ctx.Error.
+func requestLogger(logger *slog.Logger) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+
+		requestID := ctx.GetHeader(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+		ctx.Header(RequestIDHeader, requestID)
+
+		route := ctx.FullPath()
+		reqLogger := logger.With("request_id", requestID, "method", ctx.Request.Method, "route", route)
+		ctx.Request = ctx.Request.WithContext(logging.WithLogger(ctx.Request.Context(), reqLogger))
+
+		ctx.Next()
+
+		status := ctx.Writer.Status()
+		attrs := []any{
+			"path", ctx.Request.URL.Path,
+			"status", status,
+			"latency_ms", float64(time.Since(start).Microseconds()) / 1000,
+			"bytes", ctx.Writer.Size(),
+		}
+
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		case probeRoutes[route]:
+			level = slog.LevelDebug
+		}
+
+		if len(ctx.Errors) > 0 {
+			errs := make([]error, len(ctx.Errors))
+			for i, err := range ctx.Errors {
+				errs[i] = err.Err
+			}
+			attrs = append(attrs, logging.Err(errors.Join(errs...)))
+		}
+
+		// Handlers may have added to the logger, with the API key for one.
+		reqCtx := ctx.Request.Context()
+		logging.FromContext(reqCtx).Log(reqCtx, level, "request", attrs...)
+	}
+}
+
+// recovery turns a panic in a handler into a 500 response. The panic is
+// logged with its stack and attached to the request.
+func recovery() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(io.Discard, func(ctx *gin.Context, recovered any) {
+		logging.FromContext(ctx.Request.Context()).Error("handler panicked", "panic", fmt.Sprint(recovered), "stack", string(debug.Stack()))
+		ctx.Error(fmt.Errorf("panic: %v", recovered))
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+	})
+}
+
+// validRequestID accepts ids of printable ASCII without spaces, so a client
+// cannot forge log fields or response headers with one.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,138 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"spy_cat_agency/internal/logging"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newTestRouter(logs *bytes.Buffer) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(requestLogger(logging.New(logs, "debug")), recovery())
+
+	router.GET("/cats/:id", func(ctx *gin.Context) {
+		logging.FromContext(ctx.Request.Context()).Info("looking for cat")
+		ctx.Error(fmt.Errorf("get cat %s: %w", ctx.Param("id"), errors.New("connection reset")))
+		ctx.Status(http.StatusInternalServerError)
+	})
+	router.GET("/panic", func(ctx *gin.Context) {
+		panic("out of catnip")
+	})
+	router.GET("/healthz", func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+
+	return router
+}
+
+func logLines(t *testing.T, logs *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+
+	var lines []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("log line %q is not JSON: %v", line, err)
+		}
+		lines = append(lines, entry)
+	}
+	return lines
+}
+
+func TestRequestLogger(t *testing.T) {
+	var logs bytes.Buffer
+	router := newTestRouter(&logs)
+
+	req := httptest.NewRequest(http.MethodGet, "/cats/7", nil)
+	req.Header.Set(RequestIDHeader, "req-42")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get(RequestIDHeader); got != "req-42" {
+		t.Errorf("%s = %q, want the one sent", RequestIDHeader, got)
+	}
+
+	lines := logLines(t, &logs)
+	if len(lines) != 2 {
+		t.Fatalf("got %d log lines, want the handler's and the request's:\n%s", len(lines), logs.String())
+	}
+
+	handler, request := lines[0], lines[1]
+	if handler["msg"] != "looking for cat" || handler["request_id"] != "req-42" || handler["route"] != "/cats/:id" {
+		t.Errorf("handler line = %v, want it to carry the request id and route", handler)
+	}
+
+	want := map[string]interface{}{
+		"msg":        "request",
+		"level":      "ERROR",
+		"request_id": "req-42",
+		"method":     "GET",
+		"route":      "/cats/:id",
+		"path":       "/cats/7",
+		"status":     float64(500),
+		"error":      "get cat 7: connection reset",
+	}
+	for key, value := range want {
+		if request[key] != value {
+			t.Errorf("request line %s = %v, want %v", key, request[key], value)
+		}
+	}
+	if _, ok := request["latency_ms"].(float64); !ok {
+		t.Errorf("request line has no latency: %v", request)
+	}
+}
+
+func TestRequestLoggerGeneratesRequestID(t *testing.T) {
+	var logs bytes.Buffer
+	router := newTestRouter(&logs)
+
+	for _, sent := range []string{"", "forged\nid", strings.Repeat("x", maxRequestIDLength+1)} {
+		logs.Reset()
+		req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+		if sent != "" {
+			req.Header.Set(RequestIDHeader, sent)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		id := rec.Header().Get(RequestIDHeader)
+		if id == "" || id == sent {
+			t.Errorf("sent %q, got request id %q, want a generated one", sent, id)
+		}
+
+		line := logLines(t, &logs)[0]
+		if line["request_id"] != id || line["level"] != "DEBUG" {
+			t.Errorf("probe request line = %v, want request id %q at debug level", line, id)
+		}
+	}
+}
+
+func TestRecovery(t *testing.T) {
+	var logs bytes.Buffer
+	router := newTestRouter(&logs)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/panic", nil))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+
+	lines := logLines(t, &logs)
+	if len(lines) != 2 || lines[0]["panic"] != "out of catnip" || lines[0]["stack"] == nil {
+		t.Fatalf("log lines = %v, want the panic with its stack first", lines)
+	}
+	if lines[1]["error"] != "panic: out of catnip" || lines[1]["status"] != float64(500) {
+		t.Errorf("request line = %v, want the panic as its error", lines[1])
+	}
+}
@@ -1,152 +1,419 @@
 package server
 
 import (
-	"database/sql"
-	"encoding/json"
-	"io"
-	"log"
+	"context"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
-	"spy_cat_agency/internal/database"
+	"os/signal"
+	"spy_cat_agency/internal/breeds"
+	"spy_cat_agency/internal/config"
+	"spy_cat_agency/internal/events"
+	"spy_cat_agency/internal/logging"
+	"spy_cat_agency/internal/models"
+	"spy_cat_agency/internal/policy"
+	"spy_cat_agency/internal/scheduler"
 	"spy_cat_agency/internal/server/controllers"
 	"spy_cat_agency/internal/services"
+	"spy_cat_agency/internal/storage"
+	"spy_cat_agency/internal/webhooks"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
 	"github.com/go-playground/validator/v10"
-	"github.com/golang-migrate/migrate/v4"
-	_ "github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
 )
 
+// eventReplaySize is how many recent events the live stream keeps for
+// clients resuming with Last-Event-ID.
+const eventReplaySize = 1000
+
 type Server struct {
+	config            config.Config
 	router            *gin.Engine
+	storage           *storage.Storage
 	catController     controllers.CatController
 	missionController controllers.MissionController
-	infoLog           *log.Logger
-	errorLog          *log.Logger
+	breedController   controllers.BreedController
+	authController    controllers.AuthController
+	auditController   controllers.AuditController
+	payrollController controllers.PayrollController
+	policyController  controllers.PolicyController
+	webhookController controllers.WebhookController
+	eventController   controllers.EventController
+	healthController  controllers.HealthController
+	authService       *services.AuthService
+	breedCatalog      *services.BreedCatalog
+	policyStore       *services.PolicyStore
+	missionService    *services.MissionService
+	webhookWorker     *webhooks.Worker
+	bus               *events.Bus
+	scheduler         *scheduler.Scheduler
+	stopPolicyReload  func()
+	logger            *slog.Logger
 }
 
-func NewServer() *Server {
+// NewServer opens and migrates the storage and starts the background work.
+// Requests are logged to logger, with a logger of their own in their context.
+func NewServer(cfg config.Config, logger *slog.Logger) (*Server, error) {
+	store, err := storage.Open(cfg.Storage())
+	if err != nil {
+		return nil, fmt.Errorf("open storage: %w", err)
+	}
+
+	bus := events.NewBus(eventReplaySize)
+	eventController := controllers.NewEventController(bus)
+	publisher := events.Multi(store.Outbox, store.AfterCommit(bus))
+
+	auditService := services.NewAuditService(store.Audit)
+	auditController := controllers.NewAuditController(*auditService)
+
+	policyStore := services.NewPolicyStore(newPolicySource(cfg, store))
+	policyController := controllers.NewPolicyController(policyStore)
+
+	catService := services.NewCatService(store.Cats, store.Audit, policyStore, publisher, store.Transactor)
+	catController := controllers.NewCatController(*catService)
+
+	payrollService := services.NewPayrollService(store.Payroll)
+	payrollController := controllers.NewPayrollController(*payrollService)
 
-	infoLog := log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
-	errorLog := log.New(os.Stdout, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
+	missionService := services.NewMissionService(store.Missions, store.Audit, policyStore, publisher, store.Transactor)
+	missinController := controllers.NewMissionController(*missionService)
 
-	db := initDB(errorLog)
+	breedCatalog := services.NewBreedCatalog(newBreedSource(cfg, store, logger), time.Duration(cfg.Breeds.CacheTTL), logger.With("component", "breed catalog"))
+	breedController := controllers.NewBreedController(breedCatalog)
 
-	catRepo := database.NewCatRepository(db)
-	catService := services.NewCatService(catRepo)
-	catController := controllers.NewCatController(*catService, errorLog)
+	webhookService := services.NewWebhookService(store.Webhooks)
+	webhookController := controllers.NewWebhookController(*webhookService)
+	webhookWorker := webhooks.NewWorker(store.Webhooks, &http.Client{Timeout: time.Duration(cfg.Webhooks.Timeout)})
 
-	missionRepo := database.NewMissionRepository(db)
-	missionService := services.NewMissionService(missionRepo)
-	missinController := controllers.NewMissionController(*missionService, errorLog)
+	authService := services.NewAuthService(store.ApiKeys)
+	authController := controllers.NewAuthController(*authService)
+
+	healthService := services.NewHealthService(store, breedCatalog)
+	healthController := controllers.NewHealthController(*healthService)
+
+	router := gin.New()
+	router.Use(requestLogger(logger), recovery())
 
 	server := &Server{
-		router:            gin.Default(),
+		config:            cfg,
+		router:            router,
+		storage:           store,
 		catController:     *catController,
 		missionController: *missinController,
-		infoLog:           infoLog,
-		errorLog:          errorLog,
+		breedController:   *breedController,
+		authController:    *authController,
+		auditController:   *auditController,
+		payrollController: *payrollController,
+		policyController:  *policyController,
+		webhookController: *webhookController,
+		eventController:   *eventController,
+		healthController:  *healthController,
+		authService:       authService,
+		breedCatalog:      breedCatalog,
+		policyStore:       policyStore,
+		missionService:    missionService,
+		webhookWorker:     webhookWorker,
+		bus:               bus,
+		scheduler:         scheduler.New(logger),
+		logger:            logger,
 	}
 
-	server.runDBMigration()
+	if err := server.runDBMigration(); err != nil {
+		store.Close()
+		return nil, err
+	}
+	if err := server.bootstrapAPIKey(); err != nil {
+		store.Close()
+		return nil, err
+	}
+	server.stopPolicyReload = server.loadPolicy()
 	server.setupRoutes()
+	server.breedCatalog.StartRefresh()
+	server.startScheduler()
 	server.AddBreedValidator()
 
-	return server
+	return server, nil
 }
 
 func (s *Server) setupRoutes() {
-	catRoutes := s.router.Group("/cat")
-	catRoutes.POST("/add", s.catController.HireCat)
-	catRoutes.DELETE("/delete", s.catController.FireCat)
-	catRoutes.GET("/list", s.catController.ListCats)
-	catRoutes.GET("/get", s.catController.GetCat)
-	catRoutes.PATCH("/updateSalary", s.catController.UpdateSalary)
-
-	missionRoutes := s.router.Group("/mission")
-	missionRoutes.POST("/add", s.missionController.AddMission)
-	missionRoutes.PATCH("/assign", s.missionController.Assign)
-	missionRoutes.GET("/get", s.missionController.GetMission)
-	missionRoutes.DELETE("/delete", s.missionController.DeleteMission)
-	missionRoutes.GET("/list", s.missionController.ListMissions)
-	missionRoutes.PATCH("/update", s.missionController.UpdateMission)
-
-	targetRoutes := s.router.Group("target")
-	targetRoutes.GET("/get", s.missionController.GetTarget)
-	targetRoutes.DELETE("/delete", s.missionController.DeleteTarget)
-	targetRoutes.POST("/add", s.missionController.AddTarget)
-	targetRoutes.PATCH("/complete", s.missionController.CompleteTarget)
-	targetRoutes.PATCH("/updateNotes", s.missionController.UpdateTargetNotes)
+	s.router.GET("/healthz", s.healthController.Live)
+	s.router.GET("/readyz", s.healthController.Ready)
+
+	api := s.router.Group("", s.authController.Authenticate)
+	handlerOnly := s.authController.RequireRole(models.RoleHandler)
+	anyRole := s.authController.RequireRole(models.RoleHandler, models.RoleAgent)
+
+	catRoutes := api.Group("/cats", handlerOnly)
+	catRoutes.POST("", s.catController.HireCat)
+	catRoutes.GET("", s.catController.ListCats)
+	catRoutes.GET("/:id", s.catController.GetCatByID)
+	catRoutes.PATCH("/:id", s.catController.UpdateSalaryByID)
+	catRoutes.DELETE("/:id", s.catController.FireCatByID)
+	catRoutes.POST("/:id/rehire", s.catController.RehireCatByID)
+	catRoutes.GET("/:id/employment", s.catController.EmploymentHistory)
+	catRoutes.GET("/:id/salary", s.catController.SalaryHistory)
+	catRoutes.GET("/:id/assignments", s.missionController.ListCatAssignments)
+
+	missionRoutes := api.Group("/missions")
+	missionRoutes.POST("", handlerOnly, s.missionController.AddMission)
+	missionRoutes.GET("", handlerOnly, s.missionController.ListMissions)
+	missionRoutes.GET("/overdue", handlerOnly, s.missionController.ListOverdueMissions)
+	missionRoutes.GET("/:id", anyRole, s.missionController.GetMissionByID)
+	missionRoutes.PATCH("/:id", handlerOnly, s.missionController.UpdateMissionByID)
+	missionRoutes.DELETE("/:id", handlerOnly, s.missionController.DeleteMissionByID)
+	missionRoutes.POST("/:id/assign", handlerOnly, s.missionController.AssignByID)
+	missionRoutes.POST("/:id/unassign", handlerOnly, s.missionController.UnassignByID)
+	missionRoutes.POST("/:id/reassign", handlerOnly, s.missionController.ReassignByID)
+	missionRoutes.GET("/:id/assignments", anyRole, s.missionController.ListAssignments)
+	missionRoutes.POST("/:id/start", anyRole, s.missionController.StartMission)
+	missionRoutes.POST("/:id/complete", handlerOnly, s.missionController.CompleteMission)
+	missionRoutes.POST("/:id/abort", handlerOnly, s.missionController.AbortMission)
+	missionRoutes.POST("/:id/fail", handlerOnly, s.missionController.FailMission)
+	missionRoutes.GET("/:id/transitions", anyRole, s.missionController.ListTransitions)
+	missionRoutes.POST("/:id/targets", handlerOnly, s.missionController.AddTargetToMission)
 
+	targetRoutes := api.Group("/targets")
+	targetRoutes.GET("/:id", anyRole, s.missionController.GetTargetByID)
+	targetRoutes.PATCH("/:id", anyRole, s.missionController.UpdateTargetNotesByID)
+	targetRoutes.DELETE("/:id", handlerOnly, s.missionController.DeleteTargetByID)
+	targetRoutes.POST("/:id/complete", anyRole, s.missionController.CompleteTargetByID)
+	targetRoutes.GET("/:id/notes/history", anyRole, s.missionController.NotesHistory)
+	targetRoutes.GET("/:id/notes/diff", anyRole, s.missionController.NotesDiff)
+	// The history was first published under the singular path, which is
+	// kept as a current route alongside the legacy target endpoints.
+	api.GET("/target/:id/notes/history", anyRole, s.missionController.NotesHistory)
+
+	api.GET("/breeds", anyRole, s.breedController.ListBreeds)
+
+	authRoutes := api.Group("/auth/keys", handlerOnly)
+	authRoutes.POST("", s.authController.IssueKey)
+	authRoutes.GET("", s.authController.ListKeys)
+	authRoutes.DELETE("/:id", s.authController.RevokeKey)
+
+	api.GET("/events/stream", anyRole, s.eventController.Stream)
+	api.GET("/audit", handlerOnly, s.auditController.ListEvents)
+	api.GET("/reports/payroll", handlerOnly, s.payrollController.Report)
+	webhookRoutes := api.Group("/webhooks", handlerOnly)
+	webhookRoutes.POST("", s.webhookController.RegisterWebhook)
+	webhookRoutes.GET("", s.webhookController.ListWebhooks)
+	webhookRoutes.GET("/:id", s.webhookController.GetWebhook)
+	webhookRoutes.DELETE("/:id", s.webhookController.DeleteWebhook)
+	webhookRoutes.GET("/:id/deliveries", s.webhookController.ListDeliveries)
+	webhookRoutes.GET("/deliveries/:id", s.webhookController.GetDelivery)
+	webhookRoutes.POST("/deliveries/:id/retry", s.webhookController.RetryDelivery)
+
+	api.GET("/policy", handlerOnly, s.policyController.GetPolicy)
+	api.POST("/policy/reload", handlerOnly, s.policyController.ReloadPolicy)
+
+	s.setupDeprecatedRoutes(api, handlerOnly, anyRole)
 }
 
-func initDB(errorLog *log.Logger) *sql.DB {
-	conn, err := sql.Open("postgres", os.Getenv("DB_SOURCE"))
-	if err != nil {
-		errorLog.Fatal(err)
+// setupDeprecatedRoutes keeps the old verb-style endpoints, which take ids in
+// the JSON body, working as aliases of the resource routes.
+func (s *Server) setupDeprecatedRoutes(api *gin.RouterGroup, handlerOnly, anyRole gin.HandlerFunc) {
+	catRoutes := api.Group("/cat", handlerOnly)
+	catRoutes.POST("/add", deprecated("/cats"), s.catController.HireCat)
+	catRoutes.DELETE("/delete", deprecated("/cats/{id}"), s.catController.FireCat)
+	catRoutes.POST("/rehire", deprecated("/cats/{id}/rehire"), s.catController.RehireCat)
+	catRoutes.GET("/list", deprecated("/cats"), s.catController.ListCats)
+	catRoutes.GET("/get", deprecated("/cats/{id}"), s.catController.GetCat)
+	catRoutes.PATCH("/updateSalary", deprecated("/cats/{id}"), s.catController.UpdateSalary)
+
+	missionRoutes := api.Group("/mission")
+	missionRoutes.POST("/add", handlerOnly, deprecated("/missions"), s.missionController.AddMission)
+	missionRoutes.PATCH("/assign", handlerOnly, deprecated("/missions/{id}/assign"), s.missionController.Assign)
+	missionRoutes.POST("/unassign", handlerOnly, deprecated("/missions/{id}/unassign"), s.missionController.Unassign)
+	missionRoutes.POST("/reassign", handlerOnly, deprecated("/missions/{id}/reassign"), s.missionController.Reassign)
+	missionRoutes.GET("/get", anyRole, deprecated("/missions/{id}"), s.missionController.GetMission)
+	missionRoutes.DELETE("/delete", handlerOnly, deprecated("/missions/{id}"), s.missionController.DeleteMission)
+	missionRoutes.GET("/list", handlerOnly, deprecated("/missions"), s.missionController.ListMissions)
+	missionRoutes.PATCH("/update", handlerOnly, deprecated("/missions/{id}"), s.missionController.UpdateMission)
+
+	targetRoutes := api.Group("target")
+	targetRoutes.GET("/get", anyRole, deprecated("/targets/{id}"), s.missionController.GetTarget)
+	targetRoutes.DELETE("/delete", handlerOnly, deprecated("/targets/{id}"), s.missionController.DeleteTarget)
+	targetRoutes.POST("/add", handlerOnly, deprecated("/missions/{id}/targets"), s.missionController.AddTarget)
+	targetRoutes.PATCH("/complete", anyRole, deprecated("/targets/{id}/complete"), s.missionController.CompleteTarget)
+	targetRoutes.PATCH("/updateNotes", anyRole, deprecated("/targets/{id}"), s.missionController.UpdateTargetNotes)
 
+	breedRoutes := api.Group("/breed")
+	breedRoutes.GET("/list", anyRole, deprecated("/breeds"), s.breedController.ListBreeds)
+}
+
+// deprecated marks a response as coming from a deprecated endpoint and points
+// to the route that replaces it.
+func deprecated(successor string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Header("Deprecation", "true")
+		ctx.Header("Link", "<"+successor+">; rel=\"successor-version\"")
+		ctx.Next()
 	}
-	return conn
 }
 
-func (s *Server) runDBMigration() {
-	migration, err := migrate.New(os.Getenv("MIGRATION_PATH"), os.Getenv("DB_SOURCE"))
+func (s *Server) runDBMigration() error {
+	if err := s.storage.Migrate(); err != nil {
+		return fmt.Errorf("migrate %s storage: %w", s.storage.Driver, err)
+	}
+
+	s.logger.Info("storage migrated", "driver", s.storage.Driver, "version", s.storage.RequiredVersion())
+	return nil
+}
+
+// bootstrapAPIKey registers the configured bootstrap key as a handler key on
+// a database without keys, so the first real keys can be issued through
+// /auth/keys.
+func (s *Server) bootstrapAPIKey() error {
+	rawKey := s.config.Auth.BootstrapAPIKey
+	if rawKey == "" {
+		return nil
+	}
+
+	created, err := s.authService.Bootstrap(logging.WithLogger(context.Background(), s.logger), rawKey)
 	if err != nil {
-		s.errorLog.Fatal("cannot create migration:", err)
+		return fmt.Errorf("bootstrap api key: %w", err)
+	}
+
+	if created {
+		s.logger.Info("bootstrap API key registered")
+	}
+	return nil
+}
+
+// Run serves requests until SIGTERM or SIGINT, then stops taking new ones,
+// waits for those in flight, at most the configured shutdown timeout, and
+// stops the background work before closing the storage. It returns an error
+// when the server cannot listen.
+func (s *Server) Run() error {
+	httpServer := &http.Server{
+		Addr:         fmt.Sprintf(":%d", s.config.Server.Port),
+		Handler:      s.router,
+		ReadTimeout:  time.Duration(s.config.Server.ReadTimeout),
+		WriteTimeout: time.Duration(s.config.Server.WriteTimeout),
+		IdleTimeout:  time.Duration(s.config.Server.IdleTimeout),
+		ErrorLog:     slog.NewLogLogger(s.logger.Handler(), slog.LevelError),
 	}
+	// Event streams never finish on their own; Shutdown would wait for them
+	// until the timeout.
+	httpServer.RegisterOnShutdown(s.bus.Close)
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT)
+	defer signal.Stop(stop)
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- httpServer.ListenAndServe()
+	}()
+	s.logger.Info("listening", "addr", httpServer.Addr)
 
-	if err = migration.Up(); err != nil && err != migrate.ErrNoChange {
-		log.Fatal("failed to run migrate up:", err)
+	select {
+	case err := <-serveErr:
+		s.stopBackground()
+		return fmt.Errorf("listen: %w", err)
+	case sig := <-stop:
+		s.logger.Info("shutting down", "signal", sig.String())
 	}
 
-	s.infoLog.Println("DB migrated successfully")
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(s.config.Server.ShutdownTimeout))
+	defer cancel()
 
+	if err := httpServer.Shutdown(ctx); err != nil {
+		s.logger.Error("failed to wait for in-flight requests", logging.Err(err))
+	}
+
+	s.stopBackground()
+	s.logger.Info("server stopped")
+	return nil
 }
 
-func (s *Server) Run() {
-	port := os.Getenv("SERVER_PORT")
-	if err := s.router.Run(":" + port); err != nil {
-		s.errorLog.Fatalf("Failed to run server %v", err.Error())
+// stopBackground stops the policy reloads, the scheduled jobs and the breed
+// catalog refresh, then closes the storage they use.
+func (s *Server) stopBackground() {
+	s.stopPolicyReload()
+	s.scheduler.Stop()
+	s.breedCatalog.Stop()
+	if err := s.storage.Close(); err != nil {
+		s.logger.Error("failed to close storage", logging.Err(err))
 	}
 }
 
-type BreedName struct {
-	Name string `json:"name"`
+// newBreedSource picks the configured breed source: "remote", "file" or "db",
+// the breeds table of the storage. The remote source falls back to the
+// bundled breeds until the cat API has been reached once.
+func newBreedSource(cfg config.Config, store *storage.Storage, logger *slog.Logger) services.IBreedSource {
+	switch cfg.Breeds.Source {
+	case "file":
+		return breeds.NewFileSource(cfg.Breeds.File)
+	case "db":
+		return store.Breeds
+	default:
+		remote := breeds.NewRemoteSource(cfg.Breeds.APIURL, time.Duration(cfg.Breeds.FetchTimeout))
+		return breeds.NewFallbackSource(remote, logger.With("component", "breed source"))
+	}
 }
 
-func (s *Server) AddBreedValidator() {
-	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
-		// registering validation for nontoneof
-		v.RegisterValidation("breed", func(fl validator.FieldLevel) bool {
-			var breedList []BreedName
-			url := "https://api.thecatapi.com/v1/breeds"
+// newPolicySource picks the configured policy source: "file", "db" or
+// "default", which serves the built-in rules.
+func newPolicySource(cfg config.Config, store *storage.Storage) services.IPolicySource {
+	switch cfg.Policy.Source {
+	case "file":
+		return policy.NewFileSource(cfg.Policy.File)
+	case "db":
+		return store.Policies
+	default:
+		return policy.DefaultSource{}
+	}
+}
 
-			queryRes, err := http.Get(url)
-			if err != nil {
-				s.errorLog.Println("Failed to fetch breed info.")
-				return false
-			}
+// loadPolicy loads the policy at startup and reloads it on SIGHUP. A policy
+// that fails to load is logged and the previous rules, at first the defaults,
+// stay in force. The returned func stops listening for SIGHUP.
+func (s *Server) loadPolicy() func() {
+	if err := s.policyStore.Reload(); err != nil {
+		s.logger.Error("failed to load policy, using defaults", logging.Err(err))
+	}
 
-			body, err := io.ReadAll(queryRes.Body)
-			if err != nil {
-				s.errorLog.Println("Failed to fetch breed info.")
-				return false
-			}
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
 
-			err = json.Unmarshal(body, &breedList)
-			if err != nil {
-				s.errorLog.Println("Failed to fetch breed info.")
-				return false
+	go func() {
+		for range hup {
+			if err := s.policyStore.Reload(); err != nil {
+				s.logger.Error("failed to reload policy", logging.Err(err))
+				continue
 			}
+			s.logger.Info("policy reloaded")
+		}
+	}()
+
+	return func() {
+		signal.Stop(hup)
+		close(hup)
+	}
+}
 
+// startScheduler starts the background jobs: looking for missions past their
+// deadline and sending due webhook deliveries, at the configured intervals.
+func (s *Server) startScheduler() {
+	s.scheduler.Every("mark overdue missions", time.Duration(s.config.Scheduler.OverdueCheckInterval), func(ctx context.Context) error {
+		_, err := s.missionService.MarkOverdue(ctx)
+		return err
+	})
+
+	s.scheduler.Every("deliver webhooks", time.Duration(s.config.Webhooks.PollInterval), s.webhookWorker.DeliverDue)
+
+	s.scheduler.Start()
+}
+
+func (s *Server) AddBreedValidator() {
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterValidation("breed", func(fl validator.FieldLevel) bool {
 			if breed, ok := fl.Field().Interface().(string); ok {
-				for _, v := range breedList {
-					if v.Name == breed {
-						return true
-					}
-				}
+				return s.breedCatalog.Contains(breed)
 			}
 
 			return false
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"spy_cat_agency/internal/auth"
+	"spy_cat_agency/internal/models"
+)
+
+const (
+	EntityCat     = "cat"
+	EntityMission = "mission"
+	EntityTarget  = "target"
+
+	systemActor = "system"
+)
+
+type IAuditDao interface {
+	Record(ctx context.Context, event models.AuditEvent) error
+	QueryEvents(ctx context.Context, filter models.AuditFilter) (models.Page[models.AuditEvent], error)
+}
+
+type AuditService struct {
+	AuditDao IAuditDao
+}
+
+func NewAuditService(auditDao IAuditDao) *AuditService {
+	return &AuditService{
+		AuditDao: auditDao,
+	}
+}
+
+func (s *AuditService) ListEvents(ctx context.Context, filter models.AuditFilter) (models.Page[models.AuditEvent], error) {
+	page, err := s.AuditDao.QueryEvents(ctx, filter)
+
+	return page, err
+}
+
+// recordAudit writes an audit event for the caller found in ctx. before and
+// after are snapshots of the entity, nil when it did not or no longer exists.
+// It must be called with the context of the transaction making the change.
+func recordAudit(ctx context.Context, auditDao IAuditDao, action, entityType string, entityID uint, before, after interface{}) error {
+	event := models.AuditEvent{
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+	}
+	event.Actor, event.ActorKeyID = actor(ctx)
+
+	var err error
+	if event.Before, err = snapshot(before); err != nil {
+		return err
+	}
+	if event.After, err = snapshot(after); err != nil {
+		return err
+	}
+
+	return auditDao.Record(ctx, event)
+}
+
+// actor names the API key making the request, or "system" for work that is
+// not done on behalf of a request.
+func actor(ctx context.Context) (string, *uint) {
+	if key, ok := auth.FromContext(ctx); ok {
+		return key.Name, &key.ID
+	}
+	return systemActor, nil
+}
+
+func snapshot(v interface{}) (json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return json.Marshal(v)
+}
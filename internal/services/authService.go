@@ -0,0 +1,109 @@
+package services
+
+import (
+	"context"
+	"errors"
+	appErrors "spy_cat_agency/internal/appErorrs"
+	"spy_cat_agency/internal/auth"
+	"spy_cat_agency/internal/models"
+)
+
+type IApiKeyDao interface {
+	AddKey(ctx context.Context, key models.APIKey, keyHash string) (*models.APIKey, error)
+	GetActiveKey(ctx context.Context, keyHash string) (*models.APIKey, error)
+	ListKeys(ctx context.Context) ([]models.APIKey, error)
+	CountKeys(ctx context.Context) (int, error)
+	RevokeKey(ctx context.Context, id uint) error
+}
+
+type AuthService struct {
+	ApiKeyDao IApiKeyDao
+}
+
+func NewAuthService(apiKeyDao IApiKeyDao) *AuthService {
+	return &AuthService{
+		ApiKeyDao: apiKeyDao,
+	}
+}
+
+func (s *AuthService) Authenticate(ctx context.Context, rawKey string) (*models.APIKey, error) {
+	if rawKey == "" {
+		return nil, appErrors.ErrUnauthorized
+	}
+
+	key, err := s.ApiKeyDao.GetActiveKey(ctx, auth.HashKey(rawKey))
+	if errors.Is(err, appErrors.ErrNotFound) {
+		return nil, appErrors.ErrUnauthorized
+	}
+
+	return key, err
+}
+
+// IssueKey creates a key and returns its plain text value. The value cannot
+// be recovered later, only its hash is stored.
+func (s *AuthService) IssueKey(ctx context.Context, key models.APIKey) (string, *models.APIKey, error) {
+	if key.Role == models.RoleAgent && key.CatID == nil {
+		return "", nil, appErrors.ErrInvalidRequest.WithDetail("agent key must be bound to a cat_id")
+	}
+	if key.Role == models.RoleHandler {
+		key.CatID = nil
+	}
+
+	rawKey, err := auth.GenerateKey()
+	if err != nil {
+		return "", nil, err
+	}
+
+	res, err := s.ApiKeyDao.AddKey(ctx, key, auth.HashKey(rawKey))
+	if err != nil {
+		return "", nil, err
+	}
+
+	return rawKey, res, nil
+}
+
+func (s *AuthService) ListKeys(ctx context.Context) ([]models.APIKey, error) {
+	list, err := s.ApiKeyDao.ListKeys(ctx)
+
+	return list, err
+}
+
+func (s *AuthService) RevokeKey(ctx context.Context, id uint) error {
+	err := s.ApiKeyDao.RevokeKey(ctx, id)
+	if errors.Is(err, appErrors.ErrNotFound) {
+		return appErrors.ErrAPIKeyNotFound
+	}
+
+	return err
+}
+
+// Bootstrap stores rawKey as a handler key when no key exists yet, so a fresh
+// deployment can issue its first keys.
+func (s *AuthService) Bootstrap(ctx context.Context, rawKey string) (bool, error) {
+	count, err := s.ApiKeyDao.CountKeys(ctx)
+	if err != nil || count > 0 {
+		return false, err
+	}
+
+	key := models.APIKey{Name: "bootstrap", Role: models.RoleHandler}
+	if _, err := s.ApiKeyDao.AddKey(ctx, key, auth.HashKey(rawKey)); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// authorizeMission lets handlers and internal callers access any mission, and
+// agents only the mission assigned to their own cat.
+func authorizeMission(ctx context.Context, mission *models.Mission) error {
+	key, ok := auth.FromContext(ctx)
+	if !ok || key.Role == models.RoleHandler {
+		return nil
+	}
+
+	if key.CatID == nil || mission.CatId == nil || *key.CatID != *mission.CatId {
+		return appErrors.ErrForbidden
+	}
+
+	return nil
+}
@@ -0,0 +1,209 @@
+package services
+
+import (
+	"errors"
+	"log/slog"
+	"spy_cat_agency/internal/logging"
+	"spy_cat_agency/internal/models"
+	"sync"
+	"time"
+)
+
+type IBreedSource interface {
+	FetchBreeds() ([]models.Breed, error)
+}
+
+var ErrBreedCatalogEmpty = errors.New("breed catalog has not been loaded yet")
+
+// breedRetryInterval limits how often a request can trigger a synchronous
+// refresh, so an unavailable source does not slow down every validation.
+const breedRetryInterval = 30 * time.Second
+
+type BreedCatalogStatus struct {
+	Loaded    bool      `json:"loaded"`
+	Size      int       `json:"size"`
+	FetchedAt time.Time `json:"fetched_at"`
+	Stale     bool      `json:"stale"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+// BreedCatalog keeps an in-memory snapshot of the breed list. The snapshot is
+// only replaced after a successful fetch, so a failing source leaves the last
+// good list in place.
+type BreedCatalog struct {
+	source IBreedSource
+	ttl    time.Duration
+	logger *slog.Logger
+
+	mu        sync.RWMutex
+	breeds    []models.Breed
+	names     map[string]struct{}
+	fetchedAt time.Time
+	attempted time.Time
+	lastErr   error
+
+	refreshMu sync.Mutex
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+func NewBreedCatalog(source IBreedSource, ttl time.Duration, logger *slog.Logger) *BreedCatalog {
+	return &BreedCatalog{
+		source: source,
+		ttl:    ttl,
+		logger: logger,
+		names:  make(map[string]struct{}),
+	}
+}
+
+func (c *BreedCatalog) Refresh() error {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
+	return c.fetch()
+}
+
+// refreshIfStale refreshes a stale catalog on behalf of a request. Requests
+// that waited for a refresh in progress find the catalog fresh, or the
+// attempt recorded, and do not fetch again.
+func (c *BreedCatalog) refreshIfStale() {
+	if !c.needsRefresh() {
+		return
+	}
+
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
+	if !c.needsRefresh() {
+		return
+	}
+
+	if err := c.fetch(); err != nil {
+		c.logger.Warn("failed to refresh breed catalog, keeping last snapshot", logging.Err(err))
+	}
+}
+
+// fetch replaces the snapshot with the list of the source. The attempt is
+// recorded before fetching, so requests arriving meanwhile keep using the
+// snapshot. refreshMu must be held.
+func (c *BreedCatalog) fetch() error {
+	c.mu.Lock()
+	c.attempted = time.Now()
+	c.mu.Unlock()
+
+	breeds, err := c.source.FetchBreeds()
+	if err == nil && len(breeds) == 0 {
+		err = errors.New("breed source returned an empty list")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err != nil {
+		c.lastErr = err
+		return err
+	}
+
+	names := make(map[string]struct{}, len(breeds))
+	for _, v := range breeds {
+		names[v.Name] = struct{}{}
+	}
+
+	c.breeds = breeds
+	c.names = names
+	c.fetchedAt = time.Now()
+	c.lastErr = nil
+
+	return nil
+}
+
+// StartRefresh loads the catalog once and then refreshes it every ttl in the
+// background until Stop is called.
+func (c *BreedCatalog) StartRefresh() {
+	if err := c.Refresh(); err != nil {
+		c.logger.Error("failed to load breed catalog", logging.Err(err))
+	}
+
+	c.stop = make(chan struct{})
+	c.done = make(chan struct{})
+
+	go func() {
+		defer close(c.done)
+		ticker := time.NewTicker(c.ttl)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := c.Refresh(); err != nil {
+					c.logger.Warn("failed to refresh breed catalog, keeping last snapshot", logging.Err(err))
+				}
+			case <-c.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (c *BreedCatalog) Stop() {
+	if c.stop == nil {
+		return
+	}
+	close(c.stop)
+	<-c.done
+	c.stop = nil
+}
+
+func (c *BreedCatalog) List() ([]models.Breed, error) {
+	c.refreshIfStale()
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.breeds == nil {
+		return nil, ErrBreedCatalogEmpty
+	}
+
+	list := make([]models.Breed, len(c.breeds))
+	copy(list, c.breeds)
+
+	return list, nil
+}
+
+func (c *BreedCatalog) Contains(name string) bool {
+	c.refreshIfStale()
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	_, ok := c.names[name]
+	return ok
+}
+
+func (c *BreedCatalog) Status() BreedCatalogStatus {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	status := BreedCatalogStatus{
+		Loaded:    c.breeds != nil,
+		Size:      len(c.breeds),
+		FetchedAt: c.fetchedAt,
+		Stale:     c.staleLocked(),
+	}
+	if c.lastErr != nil {
+		status.LastError = c.lastErr.Error()
+	}
+
+	return status
+}
+
+func (c *BreedCatalog) needsRefresh() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.staleLocked() && time.Since(c.attempted) > breedRetryInterval
+}
+
+func (c *BreedCatalog) staleLocked() bool {
+	return c.breeds == nil || time.Since(c.fetchedAt) > c.ttl
+}
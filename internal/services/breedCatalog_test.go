@@ -0,0 +1,48 @@
+package services_test
+
+import (
+	"log/slog"
+	"spy_cat_agency/internal/models"
+	"spy_cat_agency/internal/services"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// slowSource counts its fetches, each of which takes a while.
+type slowSource struct {
+	fetches atomic.Int32
+}
+
+func (s *slowSource) FetchBreeds() ([]models.Breed, error) {
+	s.fetches.Add(1)
+	time.Sleep(20 * time.Millisecond)
+	return []models.Breed{{Name: "Siamese"}}, nil
+}
+
+func TestBreedCatalogFetchesOncePerStalePeriod(t *testing.T) {
+	source := &slowSource{}
+	catalog := services.NewBreedCatalog(source, time.Hour, slog.Default())
+
+	// The catalog starts stale: concurrent lookups share one fetch.
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			catalog.Contains("Siamese")
+		}()
+	}
+	wg.Wait()
+
+	if n := source.fetches.Load(); n != 1 {
+		t.Errorf("%d fetches for concurrent lookups of a stale catalog, want 1", n)
+	}
+	if !catalog.Contains("Siamese") {
+		t.Error("Siamese is not in the refreshed catalog")
+	}
+	if n := source.fetches.Load(); n != 1 {
+		t.Errorf("%d fetches after a lookup of a fresh catalog, want 1", n)
+	}
+}
@@ -1,86 +1,301 @@
 package services
 
 import (
-	"net/http"
+	"context"
+	"errors"
+	"fmt"
 	appErrors "spy_cat_agency/internal/appErorrs"
+	"spy_cat_agency/internal/events"
 	"spy_cat_agency/internal/models"
-
-	"github.com/lib/pq"
+	"time"
 )
 
 type ICatDao interface {
-	Add(cat models.Cat) error
-	Delete(id uint) error
-	Update(id uint, salary float64) error
-	List() ([]models.Cat, error)
-	Get(id uint) (*models.Cat, error)
+	Add(ctx context.Context, cat models.Cat) (*models.Cat, error)
+	Fire(ctx context.Context, id uint, reason *string) error
+	Rehire(ctx context.Context, id uint) error
+	AddSalaryChange(ctx context.Context, change models.SalaryChange) (models.Money, error)
+	ListSalaryChanges(ctx context.Context, catID uint) ([]models.SalaryChange, error)
+	List(ctx context.Context) ([]models.Cat, error)
+	QueryCats(ctx context.Context, filter models.CatFilter) (models.Page[models.Cat], error)
+	Get(ctx context.Context, id uint) (*models.Cat, error)
+	Lock(ctx context.Context, id uint) (*models.Cat, error)
+	HasActiveMission(ctx context.Context, id uint) (bool, error)
+	AddEmploymentEvent(ctx context.Context, event models.EmploymentEvent) error
+	ListEmploymentEvents(ctx context.Context, catID uint) ([]models.EmploymentEvent, error)
 }
 
 type CatService struct {
-	CatDao ICatDao
+	CatDao     ICatDao
+	AuditDao   IAuditDao
+	Policy     IPolicy
+	Events     IEventPublisher
+	Transactor ITransactor
 }
 
-func NewCatService(catDao ICatDao) *CatService {
+func NewCatService(catDao ICatDao, auditDao IAuditDao, policy IPolicy, publisher IEventPublisher, transactor ITransactor) *CatService {
 	return &CatService{
-		CatDao: catDao,
+		CatDao:     catDao,
+		AuditDao:   auditDao,
+		Policy:     policy,
+		Events:     publisher,
+		Transactor: transactor,
 	}
 }
 
-func (s *CatService) HireCat(cat models.Cat) error {
-	err := s.CatDao.Add(cat)
+// publish emits an event about the cat with the context of the transaction
+// making the change.
+func (s *CatService) publish(ctx context.Context, eventType events.Type, cat *models.Cat) error {
+	catID := cat.ID
+	return s.Events.Publish(ctx, events.Event{
+		Type:       eventType,
+		CatID:      &catID,
+		Data:       cat,
+		OccurredAt: time.Now(),
+	})
+}
+
+// checkSalary makes sure the salary fits the range the policy sets for the
+// breed. Ranges only apply to salaries in their own currency.
+func (s *CatService) checkSalary(breed string, salary models.Money, currency string) error {
+	r, ok := s.Policy.Current().SalaryRangeFor(breed)
+	if !ok || r.Contains(salary, currency) {
+		return nil
+	}
+
+	detail := fmt.Sprintf("salary for %s must be at least %s %s", breed, r.Min, r.Currency)
+	if r.Max != 0 {
+		detail = fmt.Sprintf("salary for %s must be from %s to %s %s", breed, r.Min, r.Max, r.Currency)
+	}
 
-	return err
+	return appErrors.ErrSalaryOutOfRange.WithDetail(detail).
+		WithExtension("min", r.Min).
+		WithExtension("max", r.Max).
+		WithExtension("currency", r.Currency)
 }
 
-func (s *CatService) FireCat(id uint) error {
+func (s *CatService) HireCat(ctx context.Context, cat models.Cat) (*models.Cat, error) {
+	var res *models.Cat
 
-	cat, _ := s.CatDao.Get(id)
+	if cat.Currency == "" {
+		cat.Currency = models.DefaultCurrency
+	}
 
-	if cat == nil {
-		return appErrors.NewHttpError("There is no cat with such id", http.StatusBadRequest, map[string]interface{}{"error": "there is no cat with such id"})
+	if err := s.checkSalary(cat.Breed, cat.Salary, cat.Currency); err != nil {
+		return nil, err
 	}
 
-	err := s.CatDao.Delete(id)
-	if err != nil {
-		if pqErr, ok := err.(*pq.Error); ok {
-			switch pqErr.Code.Name() {
-			case "foreign_key_violation":
+	err := s.Transactor.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		res, err = s.CatDao.Add(ctx, cat)
+		if err != nil {
+			return err
+		}
 
-				return appErrors.NewHttpError("You cannot fire cat, while it is on mission", http.StatusBadRequest, map[string]interface{}{"error": "you cannot fire cat, while it is on mission"})
-			}
+		change := models.SalaryChange{
+			CatID:         res.ID,
+			Amount:        res.Salary,
+			Currency:      res.Currency,
+			EffectiveFrom: res.CreatedAt.UTC().Truncate(24 * time.Hour),
+		}
+		if _, err := s.CatDao.AddSalaryChange(ctx, change); err != nil {
+			return err
 		}
 
-		return appErrors.ErrDatabase
-	}
+		if err := s.CatDao.AddEmploymentEvent(ctx, models.EmploymentEvent{CatID: res.ID, Event: models.EmploymentHire}); err != nil {
+			return err
+		}
+
+		if err := recordAudit(ctx, s.AuditDao, "cat.hire", EntityCat, res.ID, nil, res); err != nil {
+			return err
+		}
+
+		return s.publish(ctx, events.CatHired, res)
+	})
+
+	return res, err
+}
+
+// FireCat soft-deletes the cat. Its row stays, so the missions it worked on
+// keep their reference, and it can be rehired later.
+func (s *CatService) FireCat(ctx context.Context, id uint, reason string) error {
+	return s.Transactor.WithinTx(ctx, func(ctx context.Context) error {
+		cat, err := s.CatDao.Lock(ctx, id)
+
+		if errors.Is(err, appErrors.ErrNotFound) {
+			return appErrors.ErrCatNotFound
+		} else if err != nil {
+			return err
+		}
 
-	return nil
+		if cat.IsFired() {
+			return appErrors.ErrCatFired
+		}
+
+		onMission, err := s.CatDao.HasActiveMission(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		if onMission {
+			return appErrors.ErrCatOnMission
+		}
 
+		if err := s.CatDao.Fire(ctx, id, optionalString(reason)); err != nil {
+			return err
+		}
+
+		if err := s.CatDao.AddEmploymentEvent(ctx, models.EmploymentEvent{CatID: id, Event: models.EmploymentFire, Reason: optionalString(reason)}); err != nil {
+			return err
+		}
+
+		after, err := s.CatDao.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		if err := recordAudit(ctx, s.AuditDao, "cat.fire", EntityCat, id, cat, after); err != nil {
+			return err
+		}
+
+		return s.publish(ctx, events.CatFired, after)
+	})
 }
 
-func (s *CatService) UpdateSalary(id uint, salary float64) error {
-	cat, _ := s.CatDao.Get(id)
+func (s *CatService) RehireCat(ctx context.Context, id uint, reason string) (*models.Cat, error) {
+	var res *models.Cat
 
-	if cat == nil {
-		return appErrors.NewHttpError("There is no cat with such id", http.StatusBadRequest, map[string]interface{}{"error": "there is no cat with such id"})
+	err := s.Transactor.WithinTx(ctx, func(ctx context.Context) error {
+		cat, err := s.CatDao.Lock(ctx, id)
+
+		if errors.Is(err, appErrors.ErrNotFound) {
+			return appErrors.ErrCatNotFound
+		} else if err != nil {
+			return err
+		}
+
+		if !cat.IsFired() {
+			return appErrors.ErrCatEmployed
+		}
+
+		if err := s.CatDao.Rehire(ctx, id); err != nil {
+			return err
+		}
+
+		if err := s.CatDao.AddEmploymentEvent(ctx, models.EmploymentEvent{CatID: id, Event: models.EmploymentRehire, Reason: optionalString(reason)}); err != nil {
+			return err
+		}
+
+		res, err = s.CatDao.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		if err := recordAudit(ctx, s.AuditDao, "cat.rehire", EntityCat, id, cat, res); err != nil {
+			return err
+		}
+
+		return s.publish(ctx, events.CatRehired, res)
+	})
+
+	return res, err
+}
+
+// UpdateSalary records a new monthly salary effective from the given day, or
+// from today when effectiveFrom is zero. Changes may be backdated but not
+// scheduled for the future.
+func (s *CatService) UpdateSalary(ctx context.Context, id uint, salary models.Money, effectiveFrom time.Time) error {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	if effectiveFrom.IsZero() {
+		effectiveFrom = today
 	}
+	if effectiveFrom.After(today) {
+		return appErrors.ErrInvalidRequest.WithDetail("effective_from cannot be in the future")
+	}
+
+	return s.Transactor.WithinTx(ctx, func(ctx context.Context) error {
+		// The lock keeps the cat from being fired before the change is
+		// recorded.
+		cat, err := s.CatDao.Lock(ctx, id)
 
-	err := s.CatDao.Update(id, salary)
+		if errors.Is(err, appErrors.ErrNotFound) {
+			return appErrors.ErrCatNotFound
+		} else if err != nil {
+			return err
+		}
+
+		if cat.IsFired() {
+			return appErrors.ErrCatFired.WithDetail("salary of a fired cat cannot be updated")
+		}
 
-	return err
+		if err := s.checkSalary(cat.Breed, salary, cat.Currency); err != nil {
+			return err
+		}
+
+		change := models.SalaryChange{
+			CatID:         id,
+			Amount:        salary,
+			Currency:      cat.Currency,
+			EffectiveFrom: effectiveFrom,
+		}
+		current, err := s.CatDao.AddSalaryChange(ctx, change)
+		if err != nil {
+			return err
+		}
 
+		after := *cat
+		after.Salary = current
+
+		return recordAudit(ctx, s.AuditDao, "cat.update_salary", EntityCat, id, cat, after)
+	})
+}
+
+func (s *CatService) ListCats(ctx context.Context, filter models.CatFilter) (models.Page[models.Cat], error) {
+	page, err := s.CatDao.QueryCats(ctx, filter)
+
+	return page, err
+}
+
+// GetCat hides fired cats unless includeFired is set.
+func (s *CatService) GetCat(ctx context.Context, id uint, includeFired bool) (*models.Cat, error) {
+	cat, err := s.CatDao.Get(ctx, id)
+
+	if errors.Is(err, appErrors.ErrNotFound) {
+		return nil, appErrors.ErrCatNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	if cat.IsFired() && !includeFired {
+		return nil, appErrors.ErrCatNotFound.WithDetail("the cat has been fired, pass include_fired=true to see it")
+	}
+
+	return cat, nil
 }
 
-func (s *CatService) ListCats() ([]models.Cat, error) {
-	list, err := s.CatDao.List()
+func (s *CatService) EmploymentHistory(ctx context.Context, id uint) ([]models.EmploymentEvent, error) {
+	if _, err := s.GetCat(ctx, id, true); err != nil {
+		return nil, err
+	}
+
+	list, err := s.CatDao.ListEmploymentEvents(ctx, id)
 
 	return list, err
 }
-func (s *CatService) GetCat(id uint) (*models.Cat, error) {
-	cat, err := s.CatDao.Get(id)
 
-	if cat == nil {
-		return nil, appErrors.NewHttpError("There is no cat with such id", http.StatusBadRequest, map[string]interface{}{"error": "there is no cat with such id"})
+func (s *CatService) SalaryHistory(ctx context.Context, id uint) ([]models.SalaryChange, error) {
+	if _, err := s.GetCat(ctx, id, true); err != nil {
+		return nil, err
 	}
 
-	return cat, err
+	list, err := s.CatDao.ListSalaryChanges(ctx, id)
+
+	return list, err
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
 }
@@ -0,0 +1,443 @@
+package services_test
+
+import (
+	"errors"
+	appErrors "spy_cat_agency/internal/appErorrs"
+	"spy_cat_agency/internal/events"
+	"spy_cat_agency/internal/models"
+	"testing"
+	"time"
+)
+
+func TestHireCat(t *testing.T) {
+	siameseRange := func(p *models.Policy) {
+		p.SalaryRangeByBreed = map[string]models.SalaryRange{"Siamese": {Min: 50000, Max: 200000, Currency: "USD"}}
+	}
+
+	tests := []struct {
+		name         string
+		policy       func(*models.Policy)
+		cat          models.Cat
+		wantErr      error
+		wantCurrency string
+	}{
+		{
+			name:         "defaults the currency",
+			cat:          models.Cat{Name: "Tom", Breed: "Siamese", Salary: 100000},
+			wantCurrency: models.DefaultCurrency,
+		},
+		{
+			name:         "keeps the currency",
+			cat:          models.Cat{Name: "Tom", Breed: "Siamese", Salary: 100000, Currency: "EUR"},
+			wantCurrency: "EUR",
+		},
+		{
+			name:         "salary within the breed range",
+			policy:       siameseRange,
+			cat:          models.Cat{Name: "Tom", Breed: "Siamese", Salary: 50000},
+			wantCurrency: "USD",
+		},
+		{
+			name:    "salary below the breed range",
+			policy:  siameseRange,
+			cat:     models.Cat{Name: "Tom", Breed: "Siamese", Salary: 49999},
+			wantErr: appErrors.ErrSalaryOutOfRange,
+		},
+		{
+			name:    "salary above the breed range",
+			policy:  siameseRange,
+			cat:     models.Cat{Name: "Tom", Breed: "Siamese", Salary: 200001},
+			wantErr: appErrors.ErrSalaryOutOfRange,
+		},
+		{
+			name:         "range in another currency does not apply",
+			policy:       siameseRange,
+			cat:          models.Cat{Name: "Tom", Breed: "Siamese", Salary: 100, Currency: "EUR"},
+			wantCurrency: "EUR",
+		},
+		{
+			name: "default range applies to other breeds",
+			policy: func(p *models.Policy) {
+				p.SalaryRangeByBreed = map[string]models.SalaryRange{models.DefaultRuleKey: {Min: 50000, Currency: "USD"}}
+			},
+			cat:     models.Cat{Name: "Tom", Breed: "Bengal", Salary: 100},
+			wantErr: appErrors.ErrSalaryOutOfRange,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forEachBackend(t, func(t *testing.T, f *fixture) {
+				if tt.policy != nil {
+					tt.policy(&f.policy.Policy)
+				}
+
+				cat, err := f.cats.HireCat(ctx, tt.cat)
+				if !checkErr(t, err, tt.wantErr) {
+					page, err := f.cats.ListCats(ctx, models.CatFilter{IncludeFired: true})
+					checkErr(t, err, nil)
+					if page.Total != 0 {
+						t.Errorf("rejected cat was stored, %d cats", page.Total)
+					}
+					return
+				}
+
+				if cat.Currency != tt.wantCurrency {
+					t.Errorf("currency = %q, want %q", cat.Currency, tt.wantCurrency)
+				}
+
+				salaries, err := f.cats.SalaryHistory(ctx, cat.ID)
+				checkErr(t, err, nil)
+				if len(salaries) != 1 || salaries[0].Amount != tt.cat.Salary {
+					t.Fatalf("salary history = %+v, want one change to %s", salaries, tt.cat.Salary)
+				}
+				if today := time.Now().UTC().Truncate(24 * time.Hour); !salaries[0].EffectiveFrom.Equal(today) {
+					t.Errorf("salary effective from %s, want the UTC date %s", salaries[0].EffectiveFrom, today)
+				}
+
+				employment, err := f.cats.EmploymentHistory(ctx, cat.ID)
+				checkErr(t, err, nil)
+				if len(employment) != 1 || employment[0].Event != models.EmploymentHire {
+					t.Errorf("employment history = %+v, want a hire", employment)
+				}
+
+				assertEvents(t, f, events.CatHired)
+			})
+		})
+	}
+}
+
+func TestFireCat(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(t *testing.T, f *fixture) uint
+		wantErr error
+	}{
+		{
+			name:  "employed cat",
+			setup: func(t *testing.T, f *fixture) uint { return f.hire(t, "Tom", 3) },
+		},
+		{
+			name:    "unknown cat",
+			setup:   func(t *testing.T, f *fixture) uint { return 42 },
+			wantErr: appErrors.ErrCatNotFound,
+		},
+		{
+			name: "fired cat",
+			setup: func(t *testing.T, f *fixture) uint {
+				id := f.hire(t, "Tom", 3)
+				checkErr(t, f.cats.FireCat(ctx, id, ""), nil)
+				return id
+			},
+			wantErr: appErrors.ErrCatFired,
+		},
+		{
+			name: "cat on an assigned mission",
+			setup: func(t *testing.T, f *fixture) uint {
+				id := f.hire(t, "Tom", 3)
+				f.missionIn(t, models.MissionAssigned, 1, id)
+				return id
+			},
+			wantErr: appErrors.ErrCatOnMission,
+		},
+		{
+			name: "cat on a mission in progress",
+			setup: func(t *testing.T, f *fixture) uint {
+				id := f.hire(t, "Tom", 3)
+				f.missionIn(t, models.MissionInProgress, 1, id)
+				return id
+			},
+			wantErr: appErrors.ErrCatOnMission,
+		},
+		{
+			name: "cat whose mission is completed",
+			setup: func(t *testing.T, f *fixture) uint {
+				id := f.hire(t, "Tom", 3)
+				f.missionIn(t, models.MissionCompleted, 1, id)
+				return id
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forEachBackend(t, func(t *testing.T, f *fixture) {
+				id := tt.setup(t, f)
+
+				if !checkErr(t, f.cats.FireCat(ctx, id, "retired"), tt.wantErr) {
+					return
+				}
+
+				if _, err := f.cats.GetCat(ctx, id, false); !errors.Is(err, appErrors.ErrCatNotFound) {
+					t.Errorf("fired cat is visible, error = %v", err)
+				}
+
+				cat, err := f.cats.GetCat(ctx, id, true)
+				checkErr(t, err, nil)
+				if !cat.IsFired() || cat.FiredReason == nil || *cat.FiredReason != "retired" {
+					t.Errorf("cat = %+v, want fired for retiring", cat)
+				}
+
+				assertEmployment(t, f, id, models.EmploymentHire, models.EmploymentFire)
+			})
+		})
+	}
+}
+
+func TestRehireCat(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(t *testing.T, f *fixture) uint
+		wantErr error
+	}{
+		{
+			name: "fired cat",
+			setup: func(t *testing.T, f *fixture) uint {
+				id := f.hire(t, "Tom", 3)
+				checkErr(t, f.cats.FireCat(ctx, id, ""), nil)
+				return id
+			},
+		},
+		{
+			name:    "unknown cat",
+			setup:   func(t *testing.T, f *fixture) uint { return 42 },
+			wantErr: appErrors.ErrCatNotFound,
+		},
+		{
+			name:    "employed cat",
+			setup:   func(t *testing.T, f *fixture) uint { return f.hire(t, "Tom", 3) },
+			wantErr: appErrors.ErrCatEmployed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forEachBackend(t, func(t *testing.T, f *fixture) {
+				id := tt.setup(t, f)
+
+				cat, err := f.cats.RehireCat(ctx, id, "")
+				if !checkErr(t, err, tt.wantErr) {
+					return
+				}
+
+				if cat.IsFired() || cat.FiredReason != nil {
+					t.Errorf("cat = %+v, want employed", cat)
+				}
+
+				assertEmployment(t, f, id, models.EmploymentHire, models.EmploymentFire, models.EmploymentRehire)
+				assertEvents(t, f, events.CatHired, events.CatFired, events.CatRehired)
+			})
+		})
+	}
+}
+
+func TestUpdateSalary(t *testing.T) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+
+	tests := []struct {
+		name          string
+		setup         func(t *testing.T, f *fixture) uint
+		salary        models.Money
+		effectiveFrom time.Time
+		wantErr       error
+		wantSalary    models.Money
+		// wantHistory are the amounts of the salary history, in order.
+		wantHistory []models.Money
+	}{
+		{
+			name:        "today keeps the change made at hiring",
+			setup:       func(t *testing.T, f *fixture) uint { return f.hire(t, "Tom", 3) },
+			salary:      120000,
+			wantSalary:  120000,
+			wantHistory: []models.Money{100000, 120000},
+		},
+		{
+			name:          "backdated change is kept in the history only",
+			setup:         func(t *testing.T, f *fixture) uint { return f.hire(t, "Tom", 3) },
+			salary:        90000,
+			effectiveFrom: today.AddDate(0, 0, -10),
+			wantSalary:    100000,
+			wantHistory:   []models.Money{90000, 100000},
+		},
+		{
+			name:          "future change",
+			setup:         func(t *testing.T, f *fixture) uint { return f.hire(t, "Tom", 3) },
+			salary:        120000,
+			effectiveFrom: today.AddDate(0, 0, 1),
+			wantErr:       appErrors.ErrInvalidRequest,
+		},
+		{
+			name:    "unknown cat",
+			setup:   func(t *testing.T, f *fixture) uint { return 42 },
+			salary:  120000,
+			wantErr: appErrors.ErrCatNotFound,
+		},
+		{
+			name: "fired cat",
+			setup: func(t *testing.T, f *fixture) uint {
+				id := f.hire(t, "Tom", 3)
+				checkErr(t, f.cats.FireCat(ctx, id, ""), nil)
+				return id
+			},
+			salary:  120000,
+			wantErr: appErrors.ErrCatFired,
+		},
+		{
+			name: "outside the breed range",
+			setup: func(t *testing.T, f *fixture) uint {
+				id := f.hire(t, "Tom", 3)
+				f.policy.SalaryRangeByBreed = map[string]models.SalaryRange{"Siamese": {Min: 50000, Max: 150000, Currency: "USD"}}
+				return id
+			},
+			salary:  150001,
+			wantErr: appErrors.ErrSalaryOutOfRange,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forEachBackend(t, func(t *testing.T, f *fixture) {
+				id := tt.setup(t, f)
+
+				if !checkErr(t, f.cats.UpdateSalary(ctx, id, tt.salary, tt.effectiveFrom), tt.wantErr) {
+					return
+				}
+
+				cat, err := f.cats.GetCat(ctx, id, false)
+				checkErr(t, err, nil)
+				if cat.Salary != tt.wantSalary {
+					t.Errorf("salary = %s, want %s", cat.Salary, tt.wantSalary)
+				}
+
+				changes, err := f.cats.SalaryHistory(ctx, id)
+				checkErr(t, err, nil)
+				var got []string
+				for _, change := range changes {
+					got = append(got, change.Amount.String())
+				}
+				var want []string
+				for _, amount := range tt.wantHistory {
+					want = append(want, amount.String())
+				}
+				assertStrings(t, got, want)
+			})
+		})
+	}
+}
+
+func TestListCats(t *testing.T) {
+	minExperience := uint(5)
+
+	tests := []struct {
+		name   string
+		filter models.CatFilter
+		want   []string
+	}{
+		{
+			name:   "employed cats by id",
+			filter: models.CatFilter{},
+			want:   []string{"Tom", "Kitty", "Garfield"},
+		},
+		{
+			name:   "with fired cats",
+			filter: models.CatFilter{IncludeFired: true},
+			want:   []string{"Tom", "Kitty", "Felix", "Garfield"},
+		},
+		{
+			name:   "by breed",
+			filter: models.CatFilter{Breed: "Bengal"},
+			want:   []string{"Kitty"},
+		},
+		{
+			name:   "by salary",
+			filter: models.CatFilter{MinSalary: 100000, MaxSalary: 200000},
+			want:   []string{"Tom", "Garfield"},
+		},
+		{
+			name:   "by experience",
+			filter: models.CatFilter{MinExperience: &minExperience},
+			want:   []string{"Kitty", "Garfield"},
+		},
+		{
+			name:   "sorted by name",
+			filter: models.CatFilter{Sort: "name"},
+			want:   []string{"Garfield", "Kitty", "Tom"},
+		},
+		{
+			name:   "sorted by salary descending",
+			filter: models.CatFilter{Sort: "-salary"},
+			want:   []string{"Kitty", "Garfield", "Tom"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forEachBackend(t, func(t *testing.T, f *fixture) {
+				for _, cat := range []models.Cat{
+					{Name: "Tom", YearsOfExperience: 1, Breed: "Siamese", Salary: 100000},
+					{Name: "Kitty", YearsOfExperience: 7, Breed: "Bengal", Salary: 300000},
+					{Name: "Felix", YearsOfExperience: 2, Breed: "Siamese", Salary: 150000},
+					{Name: "Garfield", YearsOfExperience: 5, Breed: "Persian", Salary: 200000},
+				} {
+					_, err := f.cats.HireCat(ctx, cat)
+					checkErr(t, err, nil)
+				}
+				checkErr(t, f.cats.FireCat(ctx, 3, ""), nil)
+
+				page, err := f.cats.ListCats(ctx, tt.filter)
+				checkErr(t, err, nil)
+
+				var names []string
+				for _, cat := range page.List {
+					names = append(names, cat.Name)
+				}
+				assertStrings(t, names, tt.want)
+				if page.Total != len(tt.want) {
+					t.Errorf("total = %d, want %d", page.Total, len(tt.want))
+				}
+			})
+		})
+	}
+}
+
+func TestListCatsPaginates(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, f *fixture) {
+		want := []string{"Bella", "Luna", "Max", "Milo", "Oliver"}
+		for _, name := range []string{"Milo", "Bella", "Oliver", "Luna", "Max"} {
+			f.hire(t, name, 1)
+		}
+
+		var names []string
+		filter := models.CatFilter{Sort: "name", Limit: 2}
+		for pages := 1; ; pages++ {
+			page, err := f.cats.ListCats(ctx, filter)
+			checkErr(t, err, nil)
+			if page.Total != len(want) {
+				t.Errorf("total = %d, want %d", page.Total, len(want))
+			}
+
+			for _, cat := range page.List {
+				names = append(names, cat.Name)
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			if pages > len(want) {
+				t.Fatal("pagination does not end")
+			}
+			filter.After = page.NextCursor
+		}
+
+		assertStrings(t, names, want)
+
+		_, err := f.cats.ListCats(ctx, models.CatFilter{After: "not a cursor"})
+		checkErr(t, err, appErrors.ErrInvalidCursor)
+
+		page, err := f.cats.ListCats(ctx, models.CatFilter{Sort: "name", Limit: 2})
+		checkErr(t, err, nil)
+		for _, sort := range []string{"-name", "salary"} {
+			_, err := f.cats.ListCats(ctx, models.CatFilter{Sort: sort, After: page.NextCursor})
+			checkErr(t, err, appErrors.ErrInvalidCursor)
+		}
+	})
+}
@@ -0,0 +1,12 @@
+package services
+
+import (
+	"context"
+	"spy_cat_agency/internal/events"
+)
+
+// IEventPublisher receives the events services emit after a change is
+// committed.
+type IEventPublisher interface {
+	Publish(ctx context.Context, event events.Event) error
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+	"spy_cat_agency/internal/logging"
+	"time"
+)
+
+// readinessTimeout bounds the storage checks of one readiness probe.
+const readinessTimeout = 2 * time.Second
+
+type IStorageProbe interface {
+	Ping(ctx context.Context) error
+	SchemaVersion(ctx context.Context) (version uint, dirty bool, err error)
+	RequiredVersion() uint
+}
+
+// CheckStatus reports a failed check with a fixed reason. The probes are
+// public, so the underlying error is only logged.
+type CheckStatus struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+type MigrationStatus struct {
+	CheckStatus
+	Version  uint `json:"version"`
+	Expected uint `json:"expected"`
+	Dirty    bool `json:"dirty"`
+}
+
+type BreedReadiness struct {
+	OK bool `json:"ok"`
+	BreedCatalogStatus
+}
+
+type Readiness struct {
+	Ready      bool            `json:"ready"`
+	Database   CheckStatus     `json:"database"`
+	Migrations MigrationStatus `json:"migrations"`
+	Breeds     BreedReadiness  `json:"breeds"`
+}
+
+type HealthService struct {
+	Storage      IStorageProbe
+	BreedCatalog *BreedCatalog
+}
+
+func NewHealthService(storage IStorageProbe, breedCatalog *BreedCatalog) *HealthService {
+	return &HealthService{
+		Storage:      storage,
+		BreedCatalog: breedCatalog,
+	}
+}
+
+// Readiness checks the database, its schema version and the breed catalog.
+func (s *HealthService) Readiness(ctx context.Context) Readiness {
+	ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+	defer cancel()
+
+	var r Readiness
+
+	logger := logging.FromContext(ctx)
+
+	if err := s.Storage.Ping(ctx); err != nil {
+		logger.Warn("readiness: database unreachable", logging.Err(err))
+		r.Database.Error = "unreachable"
+	} else {
+		r.Database.OK = true
+	}
+
+	r.Migrations.Expected = s.Storage.RequiredVersion()
+	if version, dirty, err := s.Storage.SchemaVersion(ctx); err != nil {
+		logger.Warn("readiness: failed to read schema version", logging.Err(err))
+		r.Migrations.Error = "query failed"
+	} else {
+		r.Migrations.Version = version
+		r.Migrations.Dirty = dirty
+		r.Migrations.OK = !dirty && version != 0 && version >= r.Migrations.Expected
+	}
+
+	r.Breeds.BreedCatalogStatus = s.BreedCatalog.Status()
+	r.Breeds.OK = r.Breeds.Loaded
+
+	r.Ready = r.Database.OK && r.Migrations.OK && r.Breeds.OK
+	return r
+}
@@ -0,0 +1,76 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"spy_cat_agency/internal/models"
+	"spy_cat_agency/internal/services"
+	"testing"
+	"time"
+)
+
+type probe struct {
+	pingErr    error
+	versionErr error
+	version    uint
+	dirty      bool
+	required   uint
+}
+
+func (p probe) Ping(context.Context) error { return p.pingErr }
+
+func (p probe) SchemaVersion(context.Context) (uint, bool, error) {
+	return p.version, p.dirty, p.versionErr
+}
+
+func (p probe) RequiredVersion() uint { return p.required }
+
+type breedList []models.Breed
+
+func (l breedList) FetchBreeds() ([]models.Breed, error) { return l, nil }
+
+func TestReadiness(t *testing.T) {
+	healthy := probe{version: 3, required: 3}
+
+	tests := []struct {
+		name   string
+		probe  probe
+		loaded bool
+		check  func(r services.Readiness) bool
+	}{
+		{"ready", healthy, true, func(r services.Readiness) bool { return r.Ready }},
+		{"database down", probe{pingErr: errors.New("connection refused"), version: 3, required: 3}, true, func(r services.Readiness) bool {
+			return !r.Ready && !r.Database.OK && r.Database.Error == "unreachable" && r.Migrations.OK
+		}},
+		{"schema version unreadable", probe{versionErr: errors.New(`pq: password authentication failed for user "root"`), required: 3}, true, func(r services.Readiness) bool {
+			return !r.Ready && !r.Migrations.OK && r.Migrations.Error == "query failed"
+		}},
+		{"schema ahead of this build", probe{version: 4, required: 3}, true, func(r services.Readiness) bool {
+			return r.Ready && r.Migrations.Version == 4 && r.Migrations.Expected == 3
+		}},
+		{"schema behind this build", probe{version: 2, required: 3}, true, func(r services.Readiness) bool {
+			return !r.Ready && !r.Migrations.OK && r.Migrations.Version == 2 && r.Migrations.Expected == 3
+		}},
+		{"dirty schema", probe{version: 3, dirty: true, required: 3}, true, func(r services.Readiness) bool {
+			return !r.Ready && !r.Migrations.OK && r.Migrations.Dirty
+		}},
+		{"not migrated", probe{required: 3}, true, func(r services.Readiness) bool { return !r.Ready && !r.Migrations.OK }},
+		{"breeds not loaded", healthy, false, func(r services.Readiness) bool { return !r.Ready && !r.Breeds.OK && r.Database.OK }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			catalog := services.NewBreedCatalog(breedList{{Name: "Siamese"}}, time.Hour, nil)
+			if tt.loaded {
+				if err := catalog.Refresh(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			r := services.NewHealthService(tt.probe, catalog).Readiness(context.Background())
+			if !tt.check(r) {
+				t.Errorf("readiness = %+v", r)
+			}
+		})
+	}
+}
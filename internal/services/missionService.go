@@ -1,246 +1,926 @@
 package services
 
 import (
-	"net/http"
+	"context"
+	"errors"
+	"fmt"
 	appErrors "spy_cat_agency/internal/appErorrs"
+	"spy_cat_agency/internal/auth"
+	"spy_cat_agency/internal/events"
+	"spy_cat_agency/internal/logging"
 	"spy_cat_agency/internal/models"
+	"spy_cat_agency/internal/textdiff"
+	"strings"
+	"time"
 )
 
 type IMissionDao interface {
-	AddMission(mission models.Mission) error
-	Assign(missionId, catId uint) error
-	GetMissionByID(id uint) (*models.Mission, error)
-	GetMissionByCatID(catID uint) (*models.Mission, error)
-	DeleteMission(id uint) error
-	ListMissions() ([]models.Mission, error)
-	UpdateMission(id uint, completed bool) error
-	GetTarget(id uint) (*models.Target, error)
-	DeleteTarget(id uint) error
-	AddTarget(missionId uint, target models.Target) error
-	CompleteTarget(id uint) error
-	UpdateTargetNotes(id uint, notes string) error
+	AddMission(ctx context.Context, mission models.Mission) (*models.Mission, error)
+	Assign(ctx context.Context, missionId, catId uint) error
+	Unassign(ctx context.Context, missionId uint) error
+	Reassign(ctx context.Context, missionId, catId uint) error
+	OpenAssignment(ctx context.Context, missionId, catId uint) error
+	CloseAssignment(ctx context.Context, missionId uint) error
+	ListAssignments(ctx context.Context, missionId, catId uint) ([]models.MissionAssignment, error)
+	LockMission(ctx context.Context, id uint) (*models.Mission, error)
+	LockCat(ctx context.Context, catID uint) (*models.Cat, error)
+	CountActiveMissions(ctx context.Context, catID uint) (int, error)
+	GetMissionByID(ctx context.Context, id uint) (*models.Mission, error)
+	DeleteMission(ctx context.Context, id uint) error
+	ListMissions(ctx context.Context) ([]models.Mission, error)
+	QueryMissions(ctx context.Context, filter models.MissionFilter) (models.Page[models.Mission], error)
+	MarkOverdue(ctx context.Context, now time.Time) ([]models.Mission, error)
+	UpdateStatus(ctx context.Context, id uint, from, to models.MissionStatus) error
+	AddTransition(ctx context.Context, transition models.MissionTransition) error
+	ListTransitions(ctx context.Context, missionID uint) ([]models.MissionTransition, error)
+	GetTarget(ctx context.Context, id uint) (*models.Target, error)
+	DeleteTarget(ctx context.Context, id uint) error
+	AddTarget(ctx context.Context, missionId uint, target models.Target) (*models.Target, error)
+	CompleteTarget(ctx context.Context, id uint) error
+	UpdateTargetNotes(ctx context.Context, id uint, notes string, revision int) error
+	AddNoteRevision(ctx context.Context, revision models.NoteRevision) (*models.NoteRevision, error)
+	GetNoteRevision(ctx context.Context, targetID uint, revision int) (*models.NoteRevision, error)
+	ListNoteRevisions(ctx context.Context, targetID uint) ([]models.NoteRevision, error)
 }
 
 type MissionService struct {
 	MissionDao IMissionDao
+	AuditDao   IAuditDao
+	Policy     IPolicy
+	Events     IEventPublisher
+	Transactor ITransactor
 }
 
-func NewMissionService(missionDao IMissionDao) *MissionService {
+func NewMissionService(missionDao IMissionDao, auditDao IAuditDao, policy IPolicy, publisher IEventPublisher, transactor ITransactor) *MissionService {
 	return &MissionService{
 		MissionDao: missionDao,
+		AuditDao:   auditDao,
+		Policy:     policy,
+		Events:     publisher,
+		Transactor: transactor,
 	}
 }
 
-func (s *MissionService) AddMission(mission models.Mission) error {
+// transitionActions names the audit action recorded for each target status.
+var transitionActions = map[models.MissionStatus]string{
+	models.MissionDraft:      "mission.unassign",
+	models.MissionAssigned:   "mission.assign",
+	models.MissionInProgress: "mission.start",
+	models.MissionCompleted:  "mission.complete",
+	models.MissionAborted:    "mission.abort",
+	models.MissionFailed:     "mission.fail",
+}
+
+// transitionEvents names the event published for each target status.
+var transitionEvents = map[models.MissionStatus]events.Type{
+	models.MissionDraft:      events.MissionUnassigned,
+	models.MissionAssigned:   events.MissionAssigned,
+	models.MissionInProgress: events.MissionStarted,
+	models.MissionCompleted:  events.MissionCompleted,
+	models.MissionAborted:    events.MissionAborted,
+	models.MissionFailed:     events.MissionFailed,
+}
 
-	if len(mission.TargetList) > 3 || len(mission.TargetList) < 1 {
-		return appErrors.NewHttpError("Target limit exceeded", http.StatusBadRequest, map[string]interface{}{"error": "mission can only have from 1 to 3 targets!"})
+// AddMission creates a draft mission. When CatId is set the mission is
+// assigned right away, in the same transaction.
+func (s *MissionService) AddMission(ctx context.Context, mission models.Mission) (*models.Mission, error) {
+
+	limits := s.Policy.Current().TargetsPerMission
+	if len(mission.TargetList) > limits.Max || len(mission.TargetList) < limits.Min {
+		return nil, appErrors.ErrTargetLimitExceeded.WithDetail(fmt.Sprintf("a mission needs from %d to %d targets", limits.Min, limits.Max))
 
 	}
-	err := s.MissionDao.AddMission(mission)
-	return err
+
+	if err := prepareMission(&mission, time.Now()); err != nil {
+		return nil, err
+	}
+
+	var res *models.Mission
+	err := s.Transactor.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		res, err = s.MissionDao.AddMission(ctx, mission)
+		if err != nil {
+			return err
+		}
+
+		name, _ := actor(ctx)
+		if err := s.MissionDao.AddTransition(ctx, models.MissionTransition{MissionID: res.ID, To: res.Status, Actor: name}); err != nil {
+			return err
+		}
+
+		for _, target := range res.TargetList {
+			if err := s.journalNotes(ctx, &target); err != nil {
+				return err
+			}
+		}
+
+		if err := recordAudit(ctx, s.AuditDao, "mission.create", EntityMission, res.ID, nil, res); err != nil {
+			return err
+		}
+
+		if err := s.publish(ctx, events.MissionCreated, res, res); err != nil {
+			return err
+		}
+
+		if mission.CatId == nil {
+			return nil
+		}
+
+		return s.assign(ctx, res, *mission.CatId)
+	})
+
+	return res, err
 }
 
-func (s *MissionService) Assign(missionId, catId uint) error {
-	mission, err := s.MissionDao.GetMissionByID(missionId)
-	if err != nil && mission == nil {
-		return appErrors.NewHttpError("There is no mission with such id", http.StatusBadRequest, map[string]interface{}{"error": "there is no mission with such id"})
+func (s *MissionService) Assign(ctx context.Context, missionId, catId uint) error {
+	return s.Transactor.WithinTx(ctx, func(ctx context.Context) error {
+		mission, err := s.MissionDao.LockMission(ctx, missionId)
+		if errors.Is(err, appErrors.ErrNotFound) {
+			return appErrors.ErrMissionNotFound
+		} else if err != nil {
+			return err
+		}
+
+		return s.assign(ctx, mission, catId)
+	})
+}
+
+// assign locks the cat row before checking it, so two concurrent requests
+// cannot both pass the checks. The mission must already be locked. On success
+// mission is updated in place.
+func (s *MissionService) assign(ctx context.Context, mission *models.Mission, catId uint) error {
+	if mission.CatId != nil {
+		return appErrors.ErrMissionAlreadyAssigned
+	}
+
+	if !mission.Status.CanTransitionTo(models.MissionAssigned) {
+		return invalidTransition(mission, models.MissionAssigned)
+	}
+
+	if err := s.checkCatCanTake(ctx, catId, mission); err != nil {
+		return err
+	}
+
+	if err := s.MissionDao.Assign(ctx, mission.ID, catId); err != nil {
+		return err
+	}
+
+	if err := s.MissionDao.OpenAssignment(ctx, mission.ID, catId); err != nil {
+		return err
+	}
+
+	before := *mission
+	mission.CatId = &catId
+	mission.Status = models.MissionAssigned
+
+	return s.recordTransition(ctx, &before, mission)
+}
+
+// checkCatCanTake locks the cat and checks it against the policy: it must be
+// employed, experienced enough for the mission and below its limit of active
+// missions. The lock keeps concurrent assignments of the cat from both
+// passing the limit.
+func (s *MissionService) checkCatCanTake(ctx context.Context, catId uint, mission *models.Mission) error {
+	policy := s.Policy.Current()
+
+	cat, err := s.MissionDao.LockCat(ctx, catId)
+	if errors.Is(err, appErrors.ErrNotFound) {
+		return appErrors.ErrCatNotFound
 	} else if err != nil {
 		return err
 	}
 
-	catMission, err := s.MissionDao.GetMissionByCatID(catId)
-	if err != nil {
+	if required := policy.MinExperienceFor(string(mission.Priority)); cat.YearsOfExperience < required {
+		return appErrors.ErrCatNotExperienced.WithDetail(fmt.Sprintf("a %s priority mission needs %d years of experience, the cat has %d", mission.Priority, required, cat.YearsOfExperience))
+	}
 
+	active, err := s.MissionDao.CountActiveMissions(ctx, catId)
+	if err != nil {
 		return err
 	}
 
-	if catMission.ID != 0 {
-		return appErrors.NewHttpError("This cat has already been assigned a mission", http.StatusBadRequest, map[string]interface{}{"error": "this cat has already been assigned a mission"})
+	if active >= policy.MaxActiveMissionsPerCat {
+		return appErrors.ErrCatAlreadyOnMission.WithDetail(fmt.Sprintf("a cat can work on at most %d missions at a time", policy.MaxActiveMissionsPerCat))
 	}
 
-	if mission.CatId != nil {
-		return appErrors.NewHttpError("Already assigned", http.StatusBadRequest, map[string]interface{}{"error": "this mission is already assigned to a cat"})
+	return nil
+}
+
+// Unassign takes the cat off an active mission, which goes back to draft.
+// Target notes and completed targets are kept.
+func (s *MissionService) Unassign(ctx context.Context, missionId uint) (*models.Mission, error) {
+	var res *models.Mission
+
+	err := s.Transactor.WithinTx(ctx, func(ctx context.Context) error {
+		mission, err := s.MissionDao.LockMission(ctx, missionId)
+		if errors.Is(err, appErrors.ErrNotFound) {
+			return appErrors.ErrMissionNotFound
+		} else if err != nil {
+			return err
+		}
+
+		if mission.Status.IsFinal() {
+			return appErrors.ErrMissionCompleted.WithDetail(fmt.Sprintf("%s mission cannot be unassigned", mission.Status))
+		}
+
+		if mission.CatId == nil {
+			return appErrors.ErrMissionNotAssigned
+		}
+
+		if err := s.MissionDao.Unassign(ctx, missionId); err != nil {
+			return err
+		}
+
+		if err := s.MissionDao.CloseAssignment(ctx, missionId); err != nil {
+			return err
+		}
+
+		before := *mission
+		mission.CatId = nil
+		mission.Status = models.MissionDraft
+
+		if err := s.recordTransition(ctx, &before, mission); err != nil {
+			return err
+		}
+
+		res = mission
+		return nil
+	})
+
+	return res, err
+}
+
+// Reassign hands an active mission over to another cat. The status and the
+// targets, with their notes, stay as they are.
+func (s *MissionService) Reassign(ctx context.Context, missionId, catId uint) (*models.Mission, error) {
+	var res *models.Mission
+
+	err := s.Transactor.WithinTx(ctx, func(ctx context.Context) error {
+		mission, err := s.MissionDao.LockMission(ctx, missionId)
+		if errors.Is(err, appErrors.ErrNotFound) {
+			return appErrors.ErrMissionNotFound
+		} else if err != nil {
+			return err
+		}
+
+		if mission.Status.IsFinal() {
+			return appErrors.ErrMissionCompleted.WithDetail(fmt.Sprintf("%s mission cannot be reassigned", mission.Status))
+		}
+
+		if mission.CatId == nil {
+			return appErrors.ErrMissionNotAssigned.WithDetail("use assign for a mission without a cat")
+		}
+
+		if *mission.CatId == catId {
+			return appErrors.ErrMissionAlreadyAssigned.WithDetail("the mission is already assigned to this cat")
+		}
+
+		if err := s.checkCatCanTake(ctx, catId, mission); err != nil {
+			return err
+		}
+
+		if err := s.MissionDao.Reassign(ctx, missionId, catId); err != nil {
+			return err
+		}
+
+		if err := s.MissionDao.CloseAssignment(ctx, missionId); err != nil {
+			return err
+		}
+
+		if err := s.MissionDao.OpenAssignment(ctx, missionId, catId); err != nil {
+			return err
+		}
+
+		before := *mission
+		mission.CatId = &catId
+
+		if err := recordAudit(ctx, s.AuditDao, "mission.reassign", EntityMission, missionId, before, mission); err != nil {
+			return err
+		}
+
+		if err := s.publish(ctx, events.MissionReassigned, mission, mission); err != nil {
+			return err
+		}
+
+		res = mission
+		return nil
+	})
+
+	return res, err
+}
+
+// ListAssignments returns which cats worked on the mission and when.
+func (s *MissionService) ListAssignments(ctx context.Context, missionId uint) ([]models.MissionAssignment, error) {
+	if _, err := s.GetMission(ctx, missionId); err != nil {
+		return nil, err
 	}
 
-	err = s.MissionDao.Assign(missionId, catId)
+	list, err := s.MissionDao.ListAssignments(ctx, missionId, 0)
 
-	return err
+	return list, err
 }
 
-func (s *MissionService) GetMission(id uint) (*models.Mission, error) {
-	mission, err := s.MissionDao.GetMissionByID(id)
-	return mission, err
+// ListCatAssignments returns the missions the cat worked on and when.
+func (s *MissionService) ListCatAssignments(ctx context.Context, catId uint) ([]models.MissionAssignment, error) {
+	list, err := s.MissionDao.ListAssignments(ctx, 0, catId)
+
+	return list, err
 }
 
-func (s *MissionService) DeleteMission(id uint) error {
+// Transition moves the mission to status to. Assigning and unassigning a cat
+// go through Assign and Unassign instead.
+func (s *MissionService) Transition(ctx context.Context, id uint, to models.MissionStatus) (*models.Mission, error) {
+	if to == models.MissionAssigned || to == models.MissionDraft {
+		return nil, appErrors.ErrInvalidRequest.WithDetail("missions are assigned and unassigned with POST /missions/{id}/assign and /unassign")
+	}
 
-	mission, err := s.MissionDao.GetMissionByID(id)
-	if err != nil && mission == nil {
-		return appErrors.NewHttpError("There is no mission with such id", http.StatusBadRequest, map[string]interface{}{"error": "there is no mission with such id"})
-	} else if err != nil {
+	var res *models.Mission
+	err := s.Transactor.WithinTx(ctx, func(ctx context.Context) error {
+		mission, err := s.MissionDao.LockMission(ctx, id)
+		if errors.Is(err, appErrors.ErrNotFound) {
+			return appErrors.ErrMissionNotFound
+		} else if err != nil {
+			return err
+		}
+
+		if err := authorizeMission(ctx, mission); err != nil {
+			return err
+		}
+
+		if !mission.Status.CanTransitionTo(to) {
+			return invalidTransition(mission, to)
+		}
+
+		if to == models.MissionCompleted {
+			for _, target := range mission.TargetList {
+				if !target.IsCompleted {
+					return invalidTransition(mission, to).WithDetail("all targets must be completed before the mission")
+				}
+			}
+		}
+
+		if err := s.changeStatus(ctx, mission, to); err != nil {
+			return err
+		}
+
+		res = mission
+		return nil
+	})
+
+	return res, err
+}
+
+// changeStatus stores the new status of a locked mission and its history.
+// The cat stops working on a mission that reaches a final state.
+func (s *MissionService) changeStatus(ctx context.Context, mission *models.Mission, to models.MissionStatus) error {
+	if err := s.MissionDao.UpdateStatus(ctx, mission.ID, mission.Status, to); err != nil {
 		return err
 	}
 
-	if mission.CatId != nil {
-		return appErrors.NewHttpError("Assigned mission cannot be deleted", http.StatusBadRequest, map[string]interface{}{"error": "assigned mission cannot be deleted"})
+	if to.IsFinal() && mission.CatId != nil {
+		if err := s.MissionDao.CloseAssignment(ctx, mission.ID); err != nil {
+			return err
+		}
 	}
 
-	err = s.MissionDao.DeleteMission(id)
+	before := *mission
+	mission.Status = to
 
-	return err
+	return s.recordTransition(ctx, &before, mission)
 }
 
-func (s *MissionService) ListMissions() ([]models.Mission, error) {
-	list, err := s.MissionDao.ListMissions()
-	return list, err
-}
+func (s *MissionService) recordTransition(ctx context.Context, before, after *models.Mission) error {
+	name, _ := actor(ctx)
+	transition := models.MissionTransition{
+		MissionID: after.ID,
+		From:      &before.Status,
+		To:        after.Status,
+		Actor:     name,
+	}
 
-func (s *MissionService) UpdateMission(id uint, completed bool) error {
-	mission, err := s.MissionDao.GetMissionByID(id)
+	if err := s.MissionDao.AddTransition(ctx, transition); err != nil {
+		return err
+	}
 
-	if err != nil && mission == nil {
-		return appErrors.NewHttpError("There is no mission with such id", http.StatusBadRequest, map[string]interface{}{"error": "there is no mission with such id"})
-	} else if err != nil {
+	if err := recordAudit(ctx, s.AuditDao, transitionActions[after.Status], EntityMission, after.ID, before, after); err != nil {
 		return err
 	}
 
-	if mission.IsCompleted {
-		return appErrors.NewHttpError("Completed mission cannot be updated", http.StatusInternalServerError, map[string]interface{}{"error": "completed mission cannot be updated"})
+	return s.publish(ctx, transitionEvents[after.Status], after, after)
+}
+
+// publish emits an event about the mission. It must be called with the
+// context of the transaction making the change, so that the event is only
+// delivered if the change is committed.
+func (s *MissionService) publish(ctx context.Context, eventType events.Type, mission *models.Mission, data interface{}) error {
+	missionID := mission.ID
+	event := events.Event{
+		Type:       eventType,
+		MissionID:  &missionID,
+		Data:       data,
+		OccurredAt: time.Now(),
 	}
-	err = s.MissionDao.UpdateMission(id, completed)
-	return err
+	if mission.CatId != nil {
+		catID := *mission.CatId
+		event.CatID = &catID
+	}
+
+	return s.Events.Publish(ctx, event)
 }
 
-func (s *MissionService) GetTarget(id uint) (*models.Target, error) {
-	target, err := s.MissionDao.GetTarget(id)
+// invalidTransition reports the current status and the statuses the mission
+// may move to instead.
+func invalidTransition(mission *models.Mission, to models.MissionStatus) *appErrors.HttpError {
+	next := mission.Status.Next()
 
-	return target, err
+	allowed := make([]string, len(next))
+	for i, status := range next {
+		allowed[i] = string(status)
+	}
+
+	detail := fmt.Sprintf("mission is %s and cannot become %s", mission.Status, to)
+	if len(allowed) > 0 {
+		detail += ", allowed next states: " + strings.Join(allowed, ", ")
+	} else {
+		detail += ", it is in a final state"
+	}
+
+	return appErrors.ErrInvalidTransition.
+		WithDetail(detail).
+		WithExtension("mission_status", mission.Status).
+		WithExtension("allowed", next)
+}
+
+func (s *MissionService) ListTransitions(ctx context.Context, id uint) ([]models.MissionTransition, error) {
+	if _, err := s.GetMission(ctx, id); err != nil {
+		return nil, err
+	}
+
+	list, err := s.MissionDao.ListTransitions(ctx, id)
+
+	return list, err
 }
 
-func (s *MissionService) DeleteTarget(id uint) error {
-	target, err := s.MissionDao.GetTarget(id)
-	if err != nil && target == nil {
-		return appErrors.NewHttpError("There is no target with such id", http.StatusBadRequest, map[string]interface{}{"error": "there is no target with such id"})
+func (s *MissionService) GetMission(ctx context.Context, id uint) (*models.Mission, error) {
+	mission, err := s.MissionDao.GetMissionByID(ctx, id)
+	if errors.Is(err, appErrors.ErrNotFound) {
+		return nil, appErrors.ErrMissionNotFound
 	} else if err != nil {
-		return err
+		return nil, err
 	}
-	if target.IsCompleted {
-		return appErrors.NewHttpError("Completed target cannot be deleted", http.StatusInternalServerError, map[string]interface{}{"error": "completed target cannot be deleted"})
+
+	if err := authorizeMission(ctx, mission); err != nil {
+		return nil, err
 	}
-	mission, err := s.MissionDao.GetMissionByID(target.MissionID)
 
+	return mission, nil
+}
+
+func (s *MissionService) DeleteMission(ctx context.Context, id uint) error {
+	return s.Transactor.WithinTx(ctx, func(ctx context.Context) error {
+		mission, err := s.MissionDao.LockMission(ctx, id)
+		if errors.Is(err, appErrors.ErrNotFound) {
+			return appErrors.ErrMissionNotFound
+		} else if err != nil {
+			return err
+		}
+
+		if mission.CatId != nil {
+			return appErrors.ErrMissionAssigned
+		}
+
+		if err := s.MissionDao.DeleteMission(ctx, id); err != nil {
+			return err
+		}
+
+		return recordAudit(ctx, s.AuditDao, "mission.delete", EntityMission, id, mission, nil)
+	})
+}
+
+func (s *MissionService) ListMissions(ctx context.Context, filter models.MissionFilter) (models.Page[models.Mission], error) {
+	page, err := s.MissionDao.QueryMissions(ctx, filter)
+	return page, err
+}
+
+// ListOverdueMissions lists the open missions past their deadline, the most
+// overdue first unless another order is asked for.
+func (s *MissionService) ListOverdueMissions(ctx context.Context, filter models.MissionFilter) (models.Page[models.Mission], error) {
+	filter.Overdue = true
+	if filter.Sort == "" {
+		filter.Sort = "deadline"
+	}
+
+	page, err := s.MissionDao.QueryMissions(ctx, filter)
+	return page, err
+}
+
+// MarkOverdue marks the open missions whose deadline has passed and emits a
+// mission.overdue event for each. It is run periodically by the scheduler.
+func (s *MissionService) MarkOverdue(ctx context.Context) ([]models.Mission, error) {
+	var marked []models.Mission
+
+	err := s.Transactor.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		marked, err = s.MissionDao.MarkOverdue(ctx, time.Now())
+		if err != nil {
+			return err
+		}
+
+		for i := range marked {
+			mission := &marked[i]
+			before := *mission
+			before.OverdueAt = nil
+			if err := recordAudit(ctx, s.AuditDao, "mission.overdue", EntityMission, mission.ID, before, mission); err != nil {
+				return err
+			}
+
+			if err := s.publish(ctx, events.MissionOverdue, mission, mission); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if mission.IsCompleted {
-		return appErrors.NewHttpError("Targets cannot be deleted from completed missions", http.StatusInternalServerError, map[string]interface{}{"error": "targets cannot be deleted from completed missions"})
+	for _, mission := range marked {
+		logging.FromContext(ctx).Info("mission is overdue", "mission_id", mission.ID, "deadline", mission.Deadline)
 	}
 
-	if len(mission.TargetList) == 1 {
-		return appErrors.NewHttpError("Target limit exceeded", http.StatusBadRequest, map[string]interface{}{"error": "you cannot delete the last target of the mission!"})
+	return marked, nil
+}
+
+// prepareMission gives the mission and its targets the normal priority when
+// none is set and checks their deadlines.
+func prepareMission(mission *models.Mission, now time.Time) error {
+	if mission.Priority == "" {
+		mission.Priority = models.PriorityNormal
+	}
 
+	if mission.Deadline != nil && !mission.Deadline.After(now) {
+		return appErrors.ErrInvalidRequest.WithDetail("mission deadline must be in the future")
 	}
 
-	err = s.MissionDao.DeleteTarget(id)
+	for i := range mission.TargetList {
+		if err := prepareTarget(mission, &mission.TargetList[i], now); err != nil {
+			return err
+		}
+	}
 
-	return err
+	return nil
 }
-func (s *MissionService) AddTarget(missionId uint, target models.Target) error {
-	mission, err := s.MissionDao.GetMissionByID(missionId)
 
-	if err != nil && mission == nil {
-		return appErrors.NewHttpError("There is no mission with such id", http.StatusBadRequest, map[string]interface{}{"error": "there is no mission with such id"})
-	} else if err != nil {
-		return err
+// prepareTarget defaults the priority of the target and checks that it is
+// due in the future, but not after its mission.
+func prepareTarget(mission *models.Mission, target *models.Target, now time.Time) error {
+	target.NotesRevision = 0
+	if target.Notes != "" {
+		target.NotesRevision = 1
+	}
+
+	if target.Priority == "" {
+		target.Priority = models.PriorityNormal
 	}
 
-	if mission.IsCompleted {
-		return appErrors.NewHttpError("Completed mission cannot be updated with new targets", http.StatusInternalServerError, map[string]interface{}{"error": "completed mission cannot be updated with new targets"})
+	if target.Priority.Rank() == 0 {
+		return appErrors.ErrInvalidRequest.WithDetail(fmt.Sprintf("unknown target priority %q", target.Priority))
 	}
-	if len(mission.TargetList) == 3 {
-		return appErrors.NewHttpError("Target limit exceeded", http.StatusBadRequest, map[string]interface{}{"error": "mission can only have from 1 to 3 targets!"})
+
+	if target.Deadline == nil {
+		return nil
 	}
 
-	err = s.MissionDao.AddTarget(missionId, target)
+	if !target.Deadline.After(now) {
+		return appErrors.ErrInvalidRequest.WithDetail("target deadline must be in the future")
+	}
 
-	return err
-}
+	if mission.Deadline != nil && target.Deadline.After(*mission.Deadline) {
+		return appErrors.ErrInvalidRequest.WithDetail("target cannot be due after its mission")
+	}
 
-func (s *MissionService) CompleteTarget(id uint) error {
-	var allTargetsCompleted = true
+	return nil
+}
 
-	target, err := s.MissionDao.GetTarget(id)
-	if err != nil && target == nil {
-		return appErrors.NewHttpError("There is no target with such id", http.StatusBadRequest, map[string]interface{}{"error": "there is no target with such id"})
+func (s *MissionService) GetTarget(ctx context.Context, id uint) (*models.Target, error) {
+	target, err := s.MissionDao.GetTarget(ctx, id)
+	if errors.Is(err, appErrors.ErrNotFound) {
+		return nil, appErrors.ErrTargetNotFound
 	} else if err != nil {
-		return err
+		return nil, err
 	}
 
-	if target.IsCompleted {
-		return appErrors.NewHttpError("Completed target cannot be updated", http.StatusInternalServerError, map[string]interface{}{"error": "Completed target cannot be updated"})
+	if key, ok := auth.FromContext(ctx); ok && key.Role == models.RoleAgent {
+		mission, err := s.MissionDao.GetMissionByID(ctx, target.MissionID)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := authorizeMission(ctx, mission); err != nil {
+			return nil, err
+		}
 	}
 
-	mission, err := s.MissionDao.GetMissionByID(target.MissionID)
+	return target, nil
+}
+
+func (s *MissionService) DeleteTarget(ctx context.Context, id uint) error {
+	return s.Transactor.WithinTx(ctx, func(ctx context.Context) error {
+		// The lock keeps concurrent deletions from taking the mission below
+		// the minimum number of targets.
+		mission, target, err := s.lockTarget(ctx, id)
+		if err != nil {
+			return err
+		}
+		if target.IsCompleted {
+			return appErrors.ErrTargetCompleted.WithDetail("completed target cannot be deleted")
+		}
+
+		if mission.Status.IsFinal() {
+			return appErrors.ErrMissionCompleted.WithDetail(fmt.Sprintf("targets cannot be deleted from %s missions", mission.Status))
+		}
+
+		if minTargets := s.Policy.Current().TargetsPerMission.Min; len(mission.TargetList) <= minTargets {
+			return appErrors.ErrTargetLimitExceeded.WithDetail(fmt.Sprintf("a mission needs at least %d targets", minTargets))
+		}
+
+		if err := s.MissionDao.DeleteTarget(ctx, id); errors.Is(err, appErrors.ErrNotFound) {
+			return appErrors.ErrTargetNotFound
+		} else if err != nil {
+			return err
+		}
+
+		return recordAudit(ctx, s.AuditDao, "target.delete", EntityTarget, id, target, nil)
+	})
+}
+
+func (s *MissionService) AddTarget(ctx context.Context, missionId uint, target models.Target) (*models.Target, error) {
+	var res *models.Target
+
+	err := s.Transactor.WithinTx(ctx, func(ctx context.Context) error {
+		// The lock keeps concurrent additions from taking the mission over
+		// the maximum number of targets.
+		mission, err := s.MissionDao.LockMission(ctx, missionId)
+
+		if errors.Is(err, appErrors.ErrNotFound) {
+			return appErrors.ErrMissionNotFound
+		} else if err != nil {
+			return err
+		}
 
-	if err != nil && mission == nil {
-		return appErrors.NewHttpError("There is no mission with such id", http.StatusBadRequest, map[string]interface{}{"error": "there is no mission with such id"})
+		if mission.Status.IsFinal() {
+			return appErrors.ErrMissionCompleted.WithDetail(fmt.Sprintf("%s mission cannot be updated with new targets", mission.Status))
+		}
+		if maxTargets := s.Policy.Current().TargetsPerMission.Max; len(mission.TargetList) >= maxTargets {
+			return appErrors.ErrTargetLimitExceeded.WithDetail(fmt.Sprintf("a mission can have at most %d targets", maxTargets))
+		}
+
+		if err := prepareTarget(mission, &target, time.Now()); err != nil {
+			return err
+		}
+
+		res, err = s.MissionDao.AddTarget(ctx, missionId, target)
+		if err != nil {
+			return err
+		}
+
+		if err := s.journalNotes(ctx, res); err != nil {
+			return err
+		}
+
+		return recordAudit(ctx, s.AuditDao, "target.create", EntityTarget, res.ID, nil, res)
+	})
+
+	return res, err
+}
+
+// lockTarget locks the mission of the target, as every change to a target
+// does, and returns both as read under the lock. A target deleted before the
+// lock was taken is not found.
+func (s *MissionService) lockTarget(ctx context.Context, id uint) (*models.Mission, *models.Target, error) {
+	target, err := s.MissionDao.GetTarget(ctx, id)
+	if errors.Is(err, appErrors.ErrNotFound) {
+		return nil, nil, appErrors.ErrTargetNotFound
 	} else if err != nil {
-		return appErrors.NewHttpError("Internal server error", http.StatusInternalServerError, map[string]interface{}{"error": "internal server error"})
+		return nil, nil, err
 	}
 
-	if mission.IsCompleted {
-		return appErrors.NewHttpError("Target cannot be updated", http.StatusInternalServerError, map[string]interface{}{"error": "Target of completed mission cannot be updated"})
+	mission, err := s.MissionDao.LockMission(ctx, target.MissionID)
+	if errors.Is(err, appErrors.ErrNotFound) {
+		return nil, nil, appErrors.ErrTargetNotFound
+	} else if err != nil {
+		return nil, nil, err
 	}
-	err = s.MissionDao.CompleteTarget(id)
-	if err != nil {
-		return err
+
+	for i := range mission.TargetList {
+		if mission.TargetList[i].ID == id {
+			locked := mission.TargetList[i]
+			return mission, &locked, nil
+		}
 	}
 
-	for _, v := range mission.TargetList {
-		if v.ID == id {
-			continue
+	return nil, nil, appErrors.ErrTargetNotFound
+}
+
+// CompleteTarget marks the target as done. Targets can only be completed
+// while the mission is in progress; completing the last one completes the
+// mission.
+func (s *MissionService) CompleteTarget(ctx context.Context, id uint) error {
+	return s.Transactor.WithinTx(ctx, func(ctx context.Context) error {
+		var allTargetsCompleted = true
+
+		mission, target, err := s.lockTarget(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		if err := authorizeMission(ctx, mission); err != nil {
+			return err
+		}
+
+		if target.IsCompleted {
+			return appErrors.ErrTargetCompleted
+		}
+
+		if mission.Status != models.MissionInProgress {
+			return appErrors.ErrMissionNotInProgress.
+				WithDetail(fmt.Sprintf("targets of a %s mission cannot be completed", mission.Status)).
+				WithExtension("mission_status", mission.Status)
+		}
+
+		for _, v := range mission.TargetList {
+			if v.ID == id {
+				continue
+			}
+			if !v.IsCompleted {
+				allTargetsCompleted = false
+				break
+			}
+		}
+
+		if err := s.MissionDao.CompleteTarget(ctx, id); err != nil {
+			return err
+		}
+
+		after := *target
+		after.IsCompleted = true
+		if err := recordAudit(ctx, s.AuditDao, "target.complete", EntityTarget, id, target, after); err != nil {
+			return err
+		}
+
+		if err := s.publish(ctx, events.TargetCompleted, mission, after); err != nil {
+			return err
+		}
+
+		if !allTargetsCompleted {
+			return nil
+		}
+
+		for i := range mission.TargetList {
+			mission.TargetList[i].IsCompleted = true
+		}
+
+		return s.changeStatus(ctx, mission, models.MissionCompleted)
+	})
+}
+
+// UpdateTargetNotes writes a new revision of the target's notes. revision is
+// the revision the new text is based on; when it is no longer the latest the
+// update fails with ErrNotesConflict instead of overwriting someone else's
+// change. A nil revision updates whatever the latest revision is.
+func (s *MissionService) UpdateTargetNotes(ctx context.Context, id uint, notes string, revision *int) (*models.Target, error) {
+	var res *models.Target
+
+	err := s.Transactor.WithinTx(ctx, func(ctx context.Context) error {
+		mission, target, err := s.lockTarget(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		if err := authorizeMission(ctx, mission); err != nil {
+			return err
+		}
+
+		if target.IsCompleted {
+			return appErrors.ErrTargetCompleted
+		}
+
+		if mission.Status.IsFinal() {
+			return appErrors.ErrMissionCompleted.WithDetail(fmt.Sprintf("target of %s mission cannot be updated", mission.Status))
+		}
+
+		if revision != nil && *revision != target.NotesRevision {
+			return notesConflict(target)
+		}
+
+		if err := s.MissionDao.UpdateTargetNotes(ctx, id, notes, target.NotesRevision); err != nil {
+			return err
 		}
-		if !v.IsCompleted {
-			allTargetsCompleted = false
-			break
+
+		after := *target
+		after.Notes = notes
+		after.NotesRevision++
+
+		if err := s.journalNotes(ctx, &after); err != nil {
+			return err
 		}
+
+		if err := recordAudit(ctx, s.AuditDao, "target.update_notes", EntityTarget, id, target, after); err != nil {
+			return err
+		}
+
+		res = &after
+		return s.publish(ctx, events.TargetNotesUpdated, mission, after)
+	})
+
+	return res, err
+}
+
+// journalNotes appends the current notes of the target to its journal. A
+// target without notes has nothing to record.
+func (s *MissionService) journalNotes(ctx context.Context, target *models.Target) error {
+	if target.NotesRevision == 0 {
+		return nil
 	}
 
-	if allTargetsCompleted {
-		err = s.MissionDao.UpdateMission(mission.ID, true)
-		return err
+	entry := models.NoteRevision{
+		TargetID: target.ID,
+		Revision: target.NotesRevision,
+		Notes:    target.Notes,
 	}
+	entry.Author, entry.AuthorKeyID = actor(ctx)
 
-	return nil
+	_, err := s.MissionDao.AddNoteRevision(ctx, entry)
+	return err
 }
 
-func (s *MissionService) UpdateTargetNotes(id uint, notes string) error {
-	target, err := s.MissionDao.GetTarget(id)
-	if err != nil && target == nil {
-		return appErrors.NewHttpError("There is no target with such id", http.StatusBadRequest, map[string]interface{}{"error": "there is no target with such id"})
-	} else if err != nil {
-		return err
+func notesConflict(target *models.Target) *appErrors.HttpError {
+	return appErrors.ErrNotesConflict.
+		WithDetail(fmt.Sprintf("notes are at revision %d", target.NotesRevision)).
+		WithExtension("revision", target.NotesRevision)
+}
+
+// NotesHistory returns every revision of the target's notes, oldest first.
+func (s *MissionService) NotesHistory(ctx context.Context, id uint) ([]models.NoteRevision, error) {
+	if _, err := s.GetTarget(ctx, id); err != nil {
+		return nil, err
 	}
 
-	if target.IsCompleted {
-		return appErrors.NewHttpError("Completed target cannot be updated", http.StatusInternalServerError, map[string]interface{}{"error": "Completed target cannot be updated"})
+	list, err := s.MissionDao.ListNoteRevisions(ctx, id)
+
+	return list, err
+}
+
+// NotesDiff compares two revisions of the target's notes. to defaults to the
+// latest revision and from to the one before to.
+func (s *MissionService) NotesDiff(ctx context.Context, id uint, from, to *int) (*models.NotesDiff, error) {
+	target, err := s.GetTarget(ctx, id)
+	if err != nil {
+		return nil, err
 	}
 
-	mission, err := s.MissionDao.GetMissionByID(target.MissionID)
+	diff := models.NotesDiff{TargetID: id, To: target.NotesRevision}
+	if to != nil {
+		diff.To = *to
+	}
+	diff.From = max(diff.To-1, 0)
+	if from != nil {
+		diff.From = *from
+	}
 
-	if err != nil && mission == nil {
-		return appErrors.NewHttpError("There is no mission with such id", http.StatusBadRequest, map[string]interface{}{"error": "there is no mission with such id"})
-	} else if err != nil {
-		return appErrors.NewHttpError("Internal server error", http.StatusInternalServerError, map[string]interface{}{"error": "internal server error"})
+	for _, revision := range []int{diff.From, diff.To} {
+		if revision < 0 || revision > target.NotesRevision {
+			return nil, appErrors.ErrInvalidRequest.
+				WithDetail(fmt.Sprintf("revision %d does not exist, the notes are at revision %d", revision, target.NotesRevision)).
+				WithExtension("revision", target.NotesRevision)
+		}
 	}
 
-	if mission.IsCompleted {
-		return appErrors.NewHttpError("Target cannot be updated", http.StatusInternalServerError, map[string]interface{}{"error": "Target of completed mission cannot be updated"})
+	oldNotes, err := s.notesAt(ctx, id, diff.From)
+	if err != nil {
+		return nil, err
+	}
+	newNotes, err := s.notesAt(ctx, id, diff.To)
+	if err != nil {
+		return nil, err
 	}
-	err = s.MissionDao.UpdateTargetNotes(id, notes)
 
-	return err
+	diff.Lines = textdiff.Lines(oldNotes, newNotes)
+
+	return &diff, nil
+}
+
+// notesAt returns the text of the notes at revision, empty for revision 0.
+func (s *MissionService) notesAt(ctx context.Context, targetID uint, revision int) (string, error) {
+	if revision == 0 {
+		return "", nil
+	}
+
+	entry, err := s.MissionDao.GetNoteRevision(ctx, targetID, revision)
+	if err != nil {
+		return "", err
+	}
 
+	return entry.Notes, nil
 }
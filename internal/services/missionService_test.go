@@ -0,0 +1,1291 @@
+package services_test
+
+import (
+	"encoding/json"
+	"errors"
+	appErrors "spy_cat_agency/internal/appErorrs"
+	"spy_cat_agency/internal/events"
+	"spy_cat_agency/internal/memstore"
+	"spy_cat_agency/internal/models"
+	"spy_cat_agency/internal/services"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAddMission(t *testing.T) {
+	hour := time.Now().Add(time.Hour)
+	past := time.Now().Add(-time.Hour)
+	later := time.Now().Add(2 * time.Hour)
+
+	tests := []struct {
+		name    string
+		policy  func(*models.Policy)
+		targets int
+		edit    func(t *testing.T, f *fixture, m *models.Mission)
+		wantErr error
+		// wantStatus is checked for missions that were created.
+		wantStatus models.MissionStatus
+	}{
+		{
+			name:       "one target",
+			targets:    1,
+			wantStatus: models.MissionDraft,
+		},
+		{
+			name:       "as many targets as allowed",
+			targets:    3,
+			wantStatus: models.MissionDraft,
+		},
+		{
+			name:    "no targets",
+			targets: 0,
+			wantErr: appErrors.ErrTargetLimitExceeded,
+		},
+		{
+			name:    "too many targets",
+			targets: 4,
+			wantErr: appErrors.ErrTargetLimitExceeded,
+		},
+		{
+			name:       "limit raised by the policy",
+			policy:     func(p *models.Policy) { p.TargetsPerMission = models.TargetRange{Min: 1, Max: 5} },
+			targets:    5,
+			wantStatus: models.MissionDraft,
+		},
+		{
+			name:    "minimum raised by the policy",
+			policy:  func(p *models.Policy) { p.TargetsPerMission = models.TargetRange{Min: 2, Max: 5} },
+			targets: 1,
+			wantErr: appErrors.ErrTargetLimitExceeded,
+		},
+		{
+			name:    "deadline in the past",
+			targets: 1,
+			edit:    func(t *testing.T, f *fixture, m *models.Mission) { m.Deadline = &past },
+			wantErr: appErrors.ErrInvalidRequest,
+		},
+		{
+			name:    "target due after the mission",
+			targets: 1,
+			edit: func(t *testing.T, f *fixture, m *models.Mission) {
+				m.Deadline = &hour
+				m.TargetList[0].Deadline = &later
+			},
+			wantErr: appErrors.ErrInvalidRequest,
+		},
+		{
+			name:    "unknown target priority",
+			targets: 1,
+			edit:    func(t *testing.T, f *fixture, m *models.Mission) { m.TargetList[0].Priority = "urgent" },
+			wantErr: appErrors.ErrInvalidRequest,
+		},
+		{
+			name:    "with a cat",
+			targets: 1,
+			edit: func(t *testing.T, f *fixture, m *models.Mission) {
+				id := f.hire(t, "Tom", 3)
+				m.CatId = &id
+			},
+			wantStatus: models.MissionAssigned,
+		},
+		{
+			name:    "with an unknown cat",
+			targets: 1,
+			edit: func(t *testing.T, f *fixture, m *models.Mission) {
+				id := uint(42)
+				m.CatId = &id
+			},
+			wantErr: appErrors.ErrCatNotFound,
+		},
+		{
+			name:    "with a cat already on a mission",
+			targets: 1,
+			edit: func(t *testing.T, f *fixture, m *models.Mission) {
+				id := f.hire(t, "Tom", 3)
+				_, err := f.missions.AddMission(ctx, models.Mission{Name: "First", CatId: &id, TargetList: []models.Target{{Name: "Target", Country: "France"}}})
+				checkErr(t, err, nil)
+				m.CatId = &id
+			},
+			wantErr: appErrors.ErrCatAlreadyOnMission,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forEachBackend(t, func(t *testing.T, f *fixture) {
+				if tt.policy != nil {
+					tt.policy(&f.policy.Policy)
+				}
+
+				mission := models.Mission{Name: "Mission"}
+				for i := 0; i < tt.targets; i++ {
+					mission.TargetList = append(mission.TargetList, models.Target{Name: "Target", Country: "France"})
+				}
+				if tt.edit != nil {
+					tt.edit(t, f, &mission)
+				}
+
+				before, err := f.missions.ListMissions(ctx, models.MissionFilter{})
+				checkErr(t, err, nil)
+
+				res, err := f.missions.AddMission(ctx, mission)
+				if !checkErr(t, err, tt.wantErr) {
+					after, err := f.missions.ListMissions(ctx, models.MissionFilter{})
+					checkErr(t, err, nil)
+					if after.Total != before.Total {
+						t.Errorf("rejected mission was stored, %d missions, want %d", after.Total, before.Total)
+					}
+					return
+				}
+
+				stored, err := f.missions.GetMission(ctx, res.ID)
+				checkErr(t, err, nil)
+				if stored.Status != tt.wantStatus {
+					t.Errorf("status = %s, want %s", stored.Status, tt.wantStatus)
+				}
+				if stored.Priority != models.PriorityNormal {
+					t.Errorf("priority = %s, want %s", stored.Priority, models.PriorityNormal)
+				}
+				if len(stored.TargetList) != tt.targets {
+					t.Errorf("mission has %d targets, want %d", len(stored.TargetList), tt.targets)
+				}
+
+				assignments, err := f.missions.ListAssignments(ctx, res.ID)
+				checkErr(t, err, nil)
+				if assigned := tt.wantStatus == models.MissionAssigned; assigned != (len(assignments) == 1) {
+					t.Errorf("assignments = %+v, assigned = %v", assignments, assigned)
+				}
+			})
+		})
+	}
+}
+
+// The stream gets events after the commit, once AddMission has assigned the
+// cat; mission.created must still show the mission as it was created.
+func TestAddMissionStreamsCreatedMission(t *testing.T) {
+	store := memstore.New()
+	bus := events.NewBus(8)
+	publisher := memstore.NewAfterCommitPublisher(store, bus)
+	transactor := memstore.NewUnitOfWork(store)
+	audit := memstore.NewAuditRepository(store)
+	cats := services.NewCatService(memstore.NewCatRepository(store), audit, &policy{models.DefaultPolicy()}, publisher, transactor)
+	missions := services.NewMissionService(memstore.NewMissionRepository(store), audit, &policy{models.DefaultPolicy()}, publisher, transactor)
+
+	cat, err := cats.HireCat(ctx, models.Cat{Name: "Tom", YearsOfExperience: 3, Breed: "Siamese", Salary: 100000})
+	checkErr(t, err, nil)
+
+	sub, _ := bus.Subscribe(events.Filter{}, 0)
+	defer sub.Close()
+
+	mission, err := missions.AddMission(ctx, models.Mission{Name: "Mission", CatId: &cat.ID, TargetList: []models.Target{{Name: "Target", Country: "France"}}})
+	checkErr(t, err, nil)
+	if mission.Status != models.MissionAssigned {
+		t.Fatalf("status = %s, want %s", mission.Status, models.MissionAssigned)
+	}
+
+	record := <-sub.C()
+	if record.Type != events.MissionCreated {
+		t.Fatalf("first event = %s, want %s", record.Type, events.MissionCreated)
+	}
+
+	body, err := json.Marshal(record)
+	checkErr(t, err, nil)
+	var streamed struct {
+		Data models.Mission `json:"data"`
+	}
+	checkErr(t, json.Unmarshal(body, &streamed), nil)
+	if streamed.Data.Status != models.MissionDraft {
+		t.Errorf("streamed status = %s, want %s", streamed.Data.Status, models.MissionDraft)
+	}
+}
+
+func TestAssign(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  func(*models.Policy)
+		setup   func(t *testing.T, f *fixture) (missionID, catID uint)
+		wantErr error
+	}{
+		{
+			name: "draft mission",
+			setup: func(t *testing.T, f *fixture) (uint, uint) {
+				return f.createMission(t, 1).ID, f.hire(t, "Tom", 3)
+			},
+		},
+		{
+			name: "unknown mission",
+			setup: func(t *testing.T, f *fixture) (uint, uint) {
+				return 42, f.hire(t, "Tom", 3)
+			},
+			wantErr: appErrors.ErrMissionNotFound,
+		},
+		{
+			name: "unknown cat",
+			setup: func(t *testing.T, f *fixture) (uint, uint) {
+				return f.createMission(t, 1).ID, 42
+			},
+			wantErr: appErrors.ErrCatNotFound,
+		},
+		{
+			name: "fired cat",
+			setup: func(t *testing.T, f *fixture) (uint, uint) {
+				id := f.hire(t, "Tom", 3)
+				checkErr(t, f.cats.FireCat(ctx, id, ""), nil)
+				return f.createMission(t, 1).ID, id
+			},
+			wantErr: appErrors.ErrCatNotFound,
+		},
+		{
+			name: "mission assigned to another cat",
+			setup: func(t *testing.T, f *fixture) (uint, uint) {
+				mission := f.missionIn(t, models.MissionAssigned, 1, f.hire(t, "Tom", 3))
+				return mission.ID, f.hire(t, "Felix", 3)
+			},
+			wantErr: appErrors.ErrMissionAlreadyAssigned,
+		},
+		{
+			name: "aborted mission",
+			setup: func(t *testing.T, f *fixture) (uint, uint) {
+				mission := f.createMission(t, 1)
+				_, err := f.missions.Transition(ctx, mission.ID, models.MissionAborted)
+				checkErr(t, err, nil)
+				return mission.ID, f.hire(t, "Tom", 3)
+			},
+			wantErr: appErrors.ErrInvalidTransition,
+		},
+		{
+			name: "cat already on a mission",
+			setup: func(t *testing.T, f *fixture) (uint, uint) {
+				id := f.hire(t, "Tom", 3)
+				f.missionIn(t, models.MissionInProgress, 1, id)
+				return f.createMission(t, 1).ID, id
+			},
+			wantErr: appErrors.ErrCatAlreadyOnMission,
+		},
+		{
+			name:   "cat below the policy's mission limit",
+			policy: func(p *models.Policy) { p.MaxActiveMissionsPerCat = 2 },
+			setup: func(t *testing.T, f *fixture) (uint, uint) {
+				id := f.hire(t, "Tom", 3)
+				f.missionIn(t, models.MissionInProgress, 1, id)
+				return f.createMission(t, 1).ID, id
+			},
+		},
+		{
+			name: "cat whose earlier mission is completed",
+			setup: func(t *testing.T, f *fixture) (uint, uint) {
+				id := f.hire(t, "Tom", 3)
+				f.missionIn(t, models.MissionCompleted, 1, id)
+				return f.createMission(t, 1).ID, id
+			},
+		},
+		{
+			name:   "cat not experienced enough for the priority",
+			policy: func(p *models.Policy) { p.MinExperienceByPriority = map[string]uint{"high": 5} },
+			setup: func(t *testing.T, f *fixture) (uint, uint) {
+				mission := f.createMission(t, 1, func(m *models.Mission) { m.Priority = models.PriorityHigh })
+				return mission.ID, f.hire(t, "Tom", 3)
+			},
+			wantErr: appErrors.ErrCatNotExperienced,
+		},
+		{
+			name:   "experience rule of another priority",
+			policy: func(p *models.Policy) { p.MinExperienceByPriority = map[string]uint{"high": 5} },
+			setup: func(t *testing.T, f *fixture) (uint, uint) {
+				return f.createMission(t, 1).ID, f.hire(t, "Tom", 3)
+			},
+		},
+		{
+			name:   "default experience rule",
+			policy: func(p *models.Policy) { p.MinExperienceByPriority = map[string]uint{models.DefaultRuleKey: 4} },
+			setup: func(t *testing.T, f *fixture) (uint, uint) {
+				return f.createMission(t, 1).ID, f.hire(t, "Tom", 3)
+			},
+			wantErr: appErrors.ErrCatNotExperienced,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forEachBackend(t, func(t *testing.T, f *fixture) {
+				missionID, catID := tt.setup(t, f)
+				if tt.policy != nil {
+					tt.policy(&f.policy.Policy)
+				}
+
+				if !checkErr(t, f.missions.Assign(ctx, missionID, catID), tt.wantErr) {
+					return
+				}
+
+				mission, err := f.missions.GetMission(ctx, missionID)
+				checkErr(t, err, nil)
+				if mission.Status != models.MissionAssigned || mission.CatId == nil || *mission.CatId != catID {
+					t.Errorf("mission = %+v, want assigned to cat %d", mission, catID)
+				}
+
+				assertTransitions(t, f, missionID, models.MissionDraft, models.MissionAssigned)
+
+				assignments, err := f.missions.ListAssignments(ctx, missionID)
+				checkErr(t, err, nil)
+				if len(assignments) != 1 || assignments[0].CatID != catID || assignments[0].ReleasedAt != nil {
+					t.Errorf("assignments = %+v, want one open for cat %d", assignments, catID)
+				}
+			})
+		})
+	}
+}
+
+func TestUnassign(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(t *testing.T, f *fixture) uint
+		wantErr error
+	}{
+		{
+			name: "assigned mission",
+			setup: func(t *testing.T, f *fixture) uint {
+				return f.missionIn(t, models.MissionAssigned, 1, f.hire(t, "Tom", 3)).ID
+			},
+		},
+		{
+			name: "mission in progress",
+			setup: func(t *testing.T, f *fixture) uint {
+				return f.missionIn(t, models.MissionInProgress, 1, f.hire(t, "Tom", 3)).ID
+			},
+		},
+		{
+			name:    "unknown mission",
+			setup:   func(t *testing.T, f *fixture) uint { return 42 },
+			wantErr: appErrors.ErrMissionNotFound,
+		},
+		{
+			name:    "draft mission",
+			setup:   func(t *testing.T, f *fixture) uint { return f.createMission(t, 1).ID },
+			wantErr: appErrors.ErrMissionNotAssigned,
+		},
+		{
+			name: "completed mission",
+			setup: func(t *testing.T, f *fixture) uint {
+				return f.missionIn(t, models.MissionCompleted, 1, f.hire(t, "Tom", 3)).ID
+			},
+			wantErr: appErrors.ErrMissionCompleted,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forEachBackend(t, func(t *testing.T, f *fixture) {
+				id := tt.setup(t, f)
+
+				mission, err := f.missions.Unassign(ctx, id)
+				if !checkErr(t, err, tt.wantErr) {
+					return
+				}
+
+				if mission.Status != models.MissionDraft || mission.CatId != nil {
+					t.Errorf("mission = %+v, want an unassigned draft", mission)
+				}
+
+				assignments, err := f.missions.ListAssignments(ctx, id)
+				checkErr(t, err, nil)
+				if len(assignments) != 1 || assignments[0].ReleasedAt == nil {
+					t.Errorf("assignments = %+v, want one released", assignments)
+				}
+
+				// The cat is free for another mission.
+				checkErr(t, f.missions.Assign(ctx, f.createMission(t, 1).ID, assignments[0].CatID), nil)
+			})
+		})
+	}
+}
+
+func TestReassign(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(t *testing.T, f *fixture) (missionID, catID uint)
+		wantErr error
+	}{
+		{
+			name: "mission in progress",
+			setup: func(t *testing.T, f *fixture) (uint, uint) {
+				mission := f.missionIn(t, models.MissionInProgress, 1, f.hire(t, "Tom", 3))
+				return mission.ID, f.hire(t, "Felix", 3)
+			},
+		},
+		{
+			name: "unknown mission",
+			setup: func(t *testing.T, f *fixture) (uint, uint) {
+				return 42, f.hire(t, "Felix", 3)
+			},
+			wantErr: appErrors.ErrMissionNotFound,
+		},
+		{
+			name: "draft mission",
+			setup: func(t *testing.T, f *fixture) (uint, uint) {
+				return f.createMission(t, 1).ID, f.hire(t, "Felix", 3)
+			},
+			wantErr: appErrors.ErrMissionNotAssigned,
+		},
+		{
+			name: "same cat",
+			setup: func(t *testing.T, f *fixture) (uint, uint) {
+				id := f.hire(t, "Tom", 3)
+				return f.missionIn(t, models.MissionAssigned, 1, id).ID, id
+			},
+			wantErr: appErrors.ErrMissionAlreadyAssigned,
+		},
+		{
+			name: "cat busy with another mission",
+			setup: func(t *testing.T, f *fixture) (uint, uint) {
+				mission := f.missionIn(t, models.MissionAssigned, 1, f.hire(t, "Tom", 3))
+				busy := f.hire(t, "Felix", 3)
+				f.missionIn(t, models.MissionAssigned, 1, busy)
+				return mission.ID, busy
+			},
+			wantErr: appErrors.ErrCatAlreadyOnMission,
+		},
+		{
+			name: "fired cat",
+			setup: func(t *testing.T, f *fixture) (uint, uint) {
+				mission := f.missionIn(t, models.MissionAssigned, 1, f.hire(t, "Tom", 3))
+				fired := f.hire(t, "Felix", 3)
+				checkErr(t, f.cats.FireCat(ctx, fired, ""), nil)
+				return mission.ID, fired
+			},
+			wantErr: appErrors.ErrCatNotFound,
+		},
+		{
+			name: "failed mission",
+			setup: func(t *testing.T, f *fixture) (uint, uint) {
+				mission := f.missionIn(t, models.MissionFailed, 1, f.hire(t, "Tom", 3))
+				return mission.ID, f.hire(t, "Felix", 3)
+			},
+			wantErr: appErrors.ErrMissionCompleted,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forEachBackend(t, func(t *testing.T, f *fixture) {
+				missionID, catID := tt.setup(t, f)
+
+				mission, err := f.missions.Reassign(ctx, missionID, catID)
+				if !checkErr(t, err, tt.wantErr) {
+					return
+				}
+
+				if mission.Status != models.MissionInProgress || *mission.CatId != catID {
+					t.Errorf("mission = %+v, want in progress with cat %d", mission, catID)
+				}
+
+				assignments, err := f.missions.ListAssignments(ctx, missionID)
+				checkErr(t, err, nil)
+				if len(assignments) != 2 || assignments[0].ReleasedAt == nil || assignments[1].CatID != catID || assignments[1].ReleasedAt != nil {
+					t.Errorf("assignments = %+v, want the first released and one open for cat %d", assignments, catID)
+				}
+
+				assertTransitions(t, f, missionID, models.MissionDraft, models.MissionAssigned, models.MissionInProgress)
+			})
+		})
+	}
+}
+
+func TestTransition(t *testing.T) {
+	tests := []struct {
+		name    string
+		from    models.MissionStatus
+		to      models.MissionStatus
+		agent   bool
+		wantErr error
+	}{
+		{name: "start", from: models.MissionAssigned, to: models.MissionInProgress},
+		{name: "abort a draft", from: models.MissionDraft, to: models.MissionAborted},
+		{name: "abort in progress", from: models.MissionInProgress, to: models.MissionAborted},
+		{name: "fail", from: models.MissionInProgress, to: models.MissionFailed},
+		{name: "start a draft", from: models.MissionDraft, to: models.MissionInProgress, wantErr: appErrors.ErrInvalidTransition},
+		{name: "complete with open targets", from: models.MissionInProgress, to: models.MissionCompleted, wantErr: appErrors.ErrInvalidTransition},
+		{name: "leave a final state", from: models.MissionFailed, to: models.MissionAborted, wantErr: appErrors.ErrInvalidTransition},
+		{name: "assign through a transition", from: models.MissionDraft, to: models.MissionAssigned, wantErr: appErrors.ErrInvalidRequest},
+		{name: "unassign through a transition", from: models.MissionAssigned, to: models.MissionDraft, wantErr: appErrors.ErrInvalidRequest},
+		{name: "agent of the cat", from: models.MissionAssigned, to: models.MissionInProgress, agent: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forEachBackend(t, func(t *testing.T, f *fixture) {
+				catID := f.hire(t, "Tom", 3)
+				id := f.missionIn(t, tt.from, 1, catID).ID
+
+				c := ctx
+				if tt.agent {
+					c = agent(catID)
+				}
+
+				mission, err := f.missions.Transition(c, id, tt.to)
+				if !checkErr(t, err, tt.wantErr) {
+					stored, getErr := f.missions.GetMission(ctx, id)
+					checkErr(t, getErr, nil)
+					if stored.Status != tt.from {
+						t.Errorf("status = %s after a rejected transition, want %s", stored.Status, tt.from)
+					}
+					if errors.Is(err, appErrors.ErrInvalidTransition) {
+						checkMissionStatus(t, err, tt.from)
+					}
+					return
+				}
+
+				if mission.Status != tt.to {
+					t.Errorf("status = %s, want %s", mission.Status, tt.to)
+				}
+
+				transitions, err := f.missions.ListTransitions(ctx, id)
+				checkErr(t, err, nil)
+				last := transitions[len(transitions)-1]
+				if last.From == nil || *last.From != tt.from || last.To != tt.to {
+					t.Errorf("last transition = %+v, want %s to %s", last, tt.from, tt.to)
+				}
+
+				if !tt.to.IsFinal() || mission.CatId == nil {
+					return
+				}
+
+				// A final mission releases the cat.
+				assignments, err := f.missions.ListAssignments(ctx, id)
+				checkErr(t, err, nil)
+				if assignments[0].ReleasedAt == nil {
+					t.Errorf("assignment of a %s mission is still open", tt.to)
+				}
+				checkErr(t, f.cats.FireCat(ctx, catID, ""), nil)
+			})
+		})
+	}
+}
+
+func TestTransitionByAnotherAgent(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, f *fixture) {
+		mission := f.missionIn(t, models.MissionAssigned, 1, f.hire(t, "Tom", 3))
+		other := f.hire(t, "Felix", 3)
+
+		_, err := f.missions.Transition(agent(other), mission.ID, models.MissionInProgress)
+		checkErr(t, err, appErrors.ErrForbidden)
+
+		_, err = f.missions.GetMission(agent(other), mission.ID)
+		checkErr(t, err, appErrors.ErrForbidden)
+	})
+}
+
+func TestDeleteMission(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(t *testing.T, f *fixture) uint
+		wantErr error
+	}{
+		{
+			name:  "draft mission",
+			setup: func(t *testing.T, f *fixture) uint { return f.createMission(t, 2).ID },
+		},
+		{
+			name: "mission unassigned before",
+			setup: func(t *testing.T, f *fixture) uint {
+				id := f.missionIn(t, models.MissionInProgress, 1, f.hire(t, "Tom", 3)).ID
+				_, err := f.missions.Unassign(ctx, id)
+				checkErr(t, err, nil)
+				return id
+			},
+		},
+		{
+			name:    "unknown mission",
+			setup:   func(t *testing.T, f *fixture) uint { return 42 },
+			wantErr: appErrors.ErrMissionNotFound,
+		},
+		{
+			name: "assigned mission",
+			setup: func(t *testing.T, f *fixture) uint {
+				return f.missionIn(t, models.MissionAssigned, 1, f.hire(t, "Tom", 3)).ID
+			},
+			wantErr: appErrors.ErrMissionAssigned,
+		},
+		{
+			name: "completed mission",
+			setup: func(t *testing.T, f *fixture) uint {
+				return f.missionIn(t, models.MissionCompleted, 1, f.hire(t, "Tom", 3)).ID
+			},
+			wantErr: appErrors.ErrMissionAssigned,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forEachBackend(t, func(t *testing.T, f *fixture) {
+				id := tt.setup(t, f)
+
+				var targets []models.Target
+				if mission, err := f.missions.GetMission(ctx, id); err == nil {
+					targets = mission.TargetList
+				}
+
+				if !checkErr(t, f.missions.DeleteMission(ctx, id), tt.wantErr) {
+					return
+				}
+
+				_, err := f.missions.GetMission(ctx, id)
+				checkErr(t, err, appErrors.ErrMissionNotFound)
+
+				// Targets, their notes and the history go with the mission.
+				for _, target := range targets {
+					_, err := f.missions.GetTarget(ctx, target.ID)
+					checkErr(t, err, appErrors.ErrTargetNotFound)
+				}
+				transitions, err := f.repos.missions.ListTransitions(ctx, id)
+				checkErr(t, err, nil)
+				assignments, err := f.repos.missions.ListAssignments(ctx, id, 0)
+				checkErr(t, err, nil)
+				if len(transitions) != 0 || len(assignments) != 0 {
+					t.Errorf("%d transitions and %d assignments left", len(transitions), len(assignments))
+				}
+			})
+		})
+	}
+}
+
+func TestAddTarget(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(t *testing.T, f *fixture) uint
+		target  models.Target
+		wantErr error
+	}{
+		{
+			name:   "below the limit",
+			setup:  func(t *testing.T, f *fixture) uint { return f.createMission(t, 2).ID },
+			target: models.Target{Name: "Target", Country: "Spain"},
+		},
+		{
+			name: "mission in progress",
+			setup: func(t *testing.T, f *fixture) uint {
+				return f.missionIn(t, models.MissionInProgress, 1, f.hire(t, "Tom", 3)).ID
+			},
+			target: models.Target{Name: "Target", Country: "Spain", Notes: "first notes"},
+		},
+		{
+			name:    "unknown mission",
+			setup:   func(t *testing.T, f *fixture) uint { return 42 },
+			target:  models.Target{Name: "Target", Country: "Spain"},
+			wantErr: appErrors.ErrMissionNotFound,
+		},
+		{
+			name:    "at the limit",
+			setup:   func(t *testing.T, f *fixture) uint { return f.createMission(t, 3).ID },
+			target:  models.Target{Name: "Target", Country: "Spain"},
+			wantErr: appErrors.ErrTargetLimitExceeded,
+		},
+		{
+			name: "completed mission",
+			setup: func(t *testing.T, f *fixture) uint {
+				return f.missionIn(t, models.MissionCompleted, 1, f.hire(t, "Tom", 3)).ID
+			},
+			target:  models.Target{Name: "Target", Country: "Spain"},
+			wantErr: appErrors.ErrMissionCompleted,
+		},
+		{
+			name: "due after the mission",
+			setup: func(t *testing.T, f *fixture) uint {
+				deadline := time.Now().Add(time.Hour)
+				return f.createMission(t, 1, func(m *models.Mission) { m.Deadline = &deadline }).ID
+			},
+			target:  models.Target{Name: "Target", Country: "Spain", Deadline: timePtr(time.Now().Add(2 * time.Hour))},
+			wantErr: appErrors.ErrInvalidRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forEachBackend(t, func(t *testing.T, f *fixture) {
+				id := tt.setup(t, f)
+
+				target, err := f.missions.AddTarget(ctx, id, tt.target)
+				if !checkErr(t, err, tt.wantErr) {
+					return
+				}
+
+				if target.MissionID != id || target.Priority != models.PriorityNormal || target.IsCompleted {
+					t.Errorf("target = %+v, want an open normal priority target of mission %d", target, id)
+				}
+
+				history, err := f.missions.NotesHistory(ctx, target.ID)
+				checkErr(t, err, nil)
+				if wantRevisions := target.NotesRevision; len(history) != wantRevisions {
+					t.Errorf("notes history has %d revisions, want %d", len(history), wantRevisions)
+				}
+			})
+		})
+	}
+}
+
+func TestDeleteTarget(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(t *testing.T, f *fixture) uint
+		wantErr error
+	}{
+		{
+			name:  "target of a draft",
+			setup: func(t *testing.T, f *fixture) uint { return f.createMission(t, 2).TargetList[0].ID },
+		},
+		{
+			name:    "unknown target",
+			setup:   func(t *testing.T, f *fixture) uint { return 42 },
+			wantErr: appErrors.ErrTargetNotFound,
+		},
+		{
+			name:    "last target",
+			setup:   func(t *testing.T, f *fixture) uint { return f.createMission(t, 1).TargetList[0].ID },
+			wantErr: appErrors.ErrTargetLimitExceeded,
+		},
+		{
+			name: "completed target",
+			setup: func(t *testing.T, f *fixture) uint {
+				mission := f.missionIn(t, models.MissionInProgress, 2, f.hire(t, "Tom", 3))
+				checkErr(t, f.missions.CompleteTarget(ctx, mission.TargetList[0].ID), nil)
+				return mission.TargetList[0].ID
+			},
+			wantErr: appErrors.ErrTargetCompleted,
+		},
+		{
+			name: "target of an aborted mission",
+			setup: func(t *testing.T, f *fixture) uint {
+				mission := f.createMission(t, 2)
+				_, err := f.missions.Transition(ctx, mission.ID, models.MissionAborted)
+				checkErr(t, err, nil)
+				return mission.TargetList[0].ID
+			},
+			wantErr: appErrors.ErrMissionCompleted,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forEachBackend(t, func(t *testing.T, f *fixture) {
+				id := tt.setup(t, f)
+
+				if !checkErr(t, f.missions.DeleteTarget(ctx, id), tt.wantErr) {
+					return
+				}
+
+				_, err := f.missions.GetTarget(ctx, id)
+				checkErr(t, err, appErrors.ErrTargetNotFound)
+			})
+		})
+	}
+}
+
+func TestDeleteMissionWhileAssigning(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, f *fixture) {
+		catID := f.hire(t, "Tom", 3)
+		mission := f.createMission(t, 1)
+
+		var assignErr, deleteErr error
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			assignErr = f.missions.Assign(ctx, mission.ID, catID)
+		}()
+		go func() {
+			defer wg.Done()
+			deleteErr = f.missions.DeleteMission(ctx, mission.ID)
+		}()
+		wg.Wait()
+
+		// Either the mission is deleted before the cat gets it, or it is
+		// assigned and kept.
+		if deleteErr == nil {
+			checkErr(t, assignErr, appErrors.ErrMissionNotFound)
+			return
+		}
+		checkErr(t, deleteErr, appErrors.ErrMissionAssigned)
+		checkErr(t, assignErr, nil)
+
+		res, err := f.missions.GetMission(ctx, mission.ID)
+		checkErr(t, err, nil)
+		if res.CatId == nil || *res.CatId != catID {
+			t.Errorf("mission cat = %v, want %d", res.CatId, catID)
+		}
+	})
+}
+
+func TestDeleteTargetsConcurrently(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, f *fixture) {
+		mission := f.createMission(t, 2)
+
+		errs := make([]error, len(mission.TargetList))
+		var wg sync.WaitGroup
+		for i, target := range mission.TargetList {
+			wg.Add(1)
+			go func(i int, id uint) {
+				defer wg.Done()
+				errs[i] = f.missions.DeleteTarget(ctx, id)
+			}(i, target.ID)
+		}
+		wg.Wait()
+
+		deleted := 0
+		for _, err := range errs {
+			if err == nil {
+				deleted++
+			} else {
+				checkErr(t, err, appErrors.ErrTargetLimitExceeded)
+			}
+		}
+		if deleted != 1 {
+			t.Errorf("%d targets deleted, want 1", deleted)
+		}
+
+		res, err := f.missions.GetMission(ctx, mission.ID)
+		checkErr(t, err, nil)
+		if len(res.TargetList) != 1 {
+			t.Errorf("mission has %d targets, want 1", len(res.TargetList))
+		}
+	})
+}
+
+func TestCompleteTarget(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(t *testing.T, f *fixture) (targetID, catID uint)
+		agent   bool
+		wantErr error
+		// wantMission is the status the mission has afterwards.
+		wantMission models.MissionStatus
+		wantEvents  []events.Type
+	}{
+		{
+			name: "not the last target",
+			setup: func(t *testing.T, f *fixture) (uint, uint) {
+				catID := f.hire(t, "Tom", 3)
+				return f.missionIn(t, models.MissionInProgress, 2, catID).TargetList[0].ID, catID
+			},
+			wantMission: models.MissionInProgress,
+			wantEvents:  []events.Type{events.TargetCompleted},
+		},
+		{
+			name: "last target completes the mission",
+			setup: func(t *testing.T, f *fixture) (uint, uint) {
+				catID := f.hire(t, "Tom", 3)
+				mission := f.missionIn(t, models.MissionInProgress, 2, catID)
+				checkErr(t, f.missions.CompleteTarget(ctx, mission.TargetList[1].ID), nil)
+				return mission.TargetList[0].ID, catID
+			},
+			wantMission: models.MissionCompleted,
+			wantEvents:  []events.Type{events.TargetCompleted, events.MissionCompleted},
+		},
+		{
+			name: "only target by the cat's agent",
+			setup: func(t *testing.T, f *fixture) (uint, uint) {
+				catID := f.hire(t, "Tom", 3)
+				return f.missionIn(t, models.MissionInProgress, 1, catID).TargetList[0].ID, catID
+			},
+			agent:       true,
+			wantMission: models.MissionCompleted,
+			wantEvents:  []events.Type{events.TargetCompleted, events.MissionCompleted},
+		},
+		{
+			name: "unknown target",
+			setup: func(t *testing.T, f *fixture) (uint, uint) {
+				return 42, f.hire(t, "Tom", 3)
+			},
+			wantErr: appErrors.ErrTargetNotFound,
+		},
+		{
+			name: "completed target",
+			setup: func(t *testing.T, f *fixture) (uint, uint) {
+				catID := f.hire(t, "Tom", 3)
+				mission := f.missionIn(t, models.MissionInProgress, 2, catID)
+				checkErr(t, f.missions.CompleteTarget(ctx, mission.TargetList[0].ID), nil)
+				return mission.TargetList[0].ID, catID
+			},
+			wantErr: appErrors.ErrTargetCompleted,
+		},
+		{
+			name: "draft mission",
+			setup: func(t *testing.T, f *fixture) (uint, uint) {
+				return f.createMission(t, 1).TargetList[0].ID, f.hire(t, "Tom", 3)
+			},
+			wantErr: appErrors.ErrMissionNotInProgress,
+		},
+		{
+			name: "assigned mission",
+			setup: func(t *testing.T, f *fixture) (uint, uint) {
+				catID := f.hire(t, "Tom", 3)
+				return f.missionIn(t, models.MissionAssigned, 1, catID).TargetList[0].ID, catID
+			},
+			wantErr: appErrors.ErrMissionNotInProgress,
+		},
+		{
+			name: "mission of another cat",
+			setup: func(t *testing.T, f *fixture) (uint, uint) {
+				target := f.missionIn(t, models.MissionInProgress, 1, f.hire(t, "Tom", 3)).TargetList[0].ID
+				return target, f.hire(t, "Felix", 3)
+			},
+			agent:   true,
+			wantErr: appErrors.ErrForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forEachBackend(t, func(t *testing.T, f *fixture) {
+				targetID, catID := tt.setup(t, f)
+
+				c := ctx
+				if tt.agent {
+					c = agent(catID)
+				}
+
+				before := len(f.events.published())
+				completeErr := f.missions.CompleteTarget(c, targetID)
+				if !checkErr(t, completeErr, tt.wantErr) {
+					if errors.Is(completeErr, appErrors.ErrMissionNotInProgress) {
+						target, err := f.missions.GetTarget(ctx, targetID)
+						checkErr(t, err, nil)
+						mission, err := f.missions.GetMission(ctx, target.MissionID)
+						checkErr(t, err, nil)
+						checkMissionStatus(t, completeErr, mission.Status)
+					}
+					return
+				}
+
+				target, err := f.missions.GetTarget(ctx, targetID)
+				checkErr(t, err, nil)
+				if !target.IsCompleted {
+					t.Error("target is not completed")
+				}
+
+				mission, err := f.missions.GetMission(ctx, target.MissionID)
+				checkErr(t, err, nil)
+				if mission.Status != tt.wantMission {
+					t.Errorf("mission is %s, want %s", mission.Status, tt.wantMission)
+				}
+
+				var got []string
+				for _, eventType := range f.events.published()[before:] {
+					got = append(got, string(eventType))
+				}
+				var want []string
+				for _, eventType := range tt.wantEvents {
+					want = append(want, string(eventType))
+				}
+				assertStrings(t, got, want)
+
+				if mission.Status != models.MissionCompleted {
+					return
+				}
+
+				// The cat is released and can be fired.
+				assignments, err := f.missions.ListAssignments(ctx, mission.ID)
+				checkErr(t, err, nil)
+				if assignments[0].ReleasedAt == nil {
+					t.Error("assignment of the completed mission is still open")
+				}
+				checkErr(t, f.cats.FireCat(ctx, catID, ""), nil)
+			})
+		})
+	}
+}
+
+func TestCompleteTargetConcurrently(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, f *fixture) {
+		mission := f.missionIn(t, models.MissionInProgress, 2, f.hire(t, "Tom", 3))
+		targetID := mission.TargetList[0].ID
+
+		errs := make([]error, 5)
+		var wg sync.WaitGroup
+		for i := range errs {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				errs[i] = f.missions.CompleteTarget(ctx, targetID)
+			}(i)
+		}
+		wg.Wait()
+
+		completed := 0
+		for _, err := range errs {
+			if err == nil {
+				completed++
+			} else {
+				checkErr(t, err, appErrors.ErrTargetCompleted)
+			}
+		}
+		if completed != 1 {
+			t.Errorf("target completed %d times, want once", completed)
+		}
+		published := 0
+		for _, eventType := range f.events.published() {
+			if eventType == events.TargetCompleted {
+				published++
+			}
+		}
+		if published != 1 {
+			t.Errorf("%s published %d times, want once", events.TargetCompleted, published)
+		}
+	})
+}
+
+func TestUpdateTargetNotes(t *testing.T) {
+	zero, one := 0, 1
+
+	tests := []struct {
+		name     string
+		setup    func(t *testing.T, f *fixture) uint
+		revision *int
+		wantErr  error
+		// wantRevision is the revision the notes are at afterwards.
+		wantRevision int
+	}{
+		{
+			name:         "first notes",
+			setup:        func(t *testing.T, f *fixture) uint { return f.createMission(t, 1).TargetList[0].ID },
+			revision:     &zero,
+			wantRevision: 1,
+		},
+		{
+			name: "notes written at creation",
+			setup: func(t *testing.T, f *fixture) uint {
+				return f.createMission(t, 1, func(m *models.Mission) { m.TargetList[0].Notes = "seen in Paris" }).TargetList[0].ID
+			},
+			revision:     &one,
+			wantRevision: 2,
+		},
+		{
+			name: "without a revision",
+			setup: func(t *testing.T, f *fixture) uint {
+				return f.createMission(t, 1, func(m *models.Mission) { m.TargetList[0].Notes = "seen in Paris" }).TargetList[0].ID
+			},
+			wantRevision: 2,
+		},
+		{
+			name: "stale revision",
+			setup: func(t *testing.T, f *fixture) uint {
+				return f.createMission(t, 1, func(m *models.Mission) { m.TargetList[0].Notes = "seen in Paris" }).TargetList[0].ID
+			},
+			revision: &zero,
+			wantErr:  appErrors.ErrNotesConflict,
+		},
+		{
+			name:     "unknown target",
+			setup:    func(t *testing.T, f *fixture) uint { return 42 },
+			revision: &zero,
+			wantErr:  appErrors.ErrTargetNotFound,
+		},
+		{
+			name: "completed target",
+			setup: func(t *testing.T, f *fixture) uint {
+				mission := f.missionIn(t, models.MissionInProgress, 2, f.hire(t, "Tom", 3))
+				checkErr(t, f.missions.CompleteTarget(ctx, mission.TargetList[0].ID), nil)
+				return mission.TargetList[0].ID
+			},
+			wantErr: appErrors.ErrTargetCompleted,
+		},
+		{
+			name: "target of an aborted mission",
+			setup: func(t *testing.T, f *fixture) uint {
+				mission := f.createMission(t, 1)
+				_, err := f.missions.Transition(ctx, mission.ID, models.MissionAborted)
+				checkErr(t, err, nil)
+				return mission.TargetList[0].ID
+			},
+			wantErr: appErrors.ErrMissionCompleted,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forEachBackend(t, func(t *testing.T, f *fixture) {
+				id := tt.setup(t, f)
+
+				target, err := f.missions.UpdateTargetNotes(ctx, id, "seen in Paris\nleft for Rome", tt.revision)
+				if !checkErr(t, err, tt.wantErr) {
+					return
+				}
+
+				if target.NotesRevision != tt.wantRevision {
+					t.Errorf("revision = %d, want %d", target.NotesRevision, tt.wantRevision)
+				}
+
+				history, err := f.missions.NotesHistory(ctx, id)
+				checkErr(t, err, nil)
+				if len(history) != tt.wantRevision || history[len(history)-1].Notes != target.Notes {
+					t.Errorf("notes history = %+v, want %d revisions ending with the new notes", history, tt.wantRevision)
+				}
+
+				diff, err := f.missions.NotesDiff(ctx, id, nil, nil)
+				checkErr(t, err, nil)
+				if diff.From != tt.wantRevision-1 || diff.To != tt.wantRevision || len(diff.Lines) == 0 {
+					t.Errorf("diff = %+v, want the change from revision %d", diff, tt.wantRevision-1)
+				}
+			})
+		})
+	}
+}
+
+func TestUpdateTargetNotesByAnotherAgent(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, f *fixture) {
+		mission := f.missionIn(t, models.MissionInProgress, 2, f.hire(t, "Tom", 3))
+		other := f.hire(t, "Felix", 3)
+
+		// Whether a target is completed is not revealed to an agent of
+		// another cat.
+		checkErr(t, f.missions.CompleteTarget(ctx, mission.TargetList[0].ID), nil)
+		for _, target := range mission.TargetList {
+			_, err := f.missions.UpdateTargetNotes(agent(other), target.ID, "seen in Paris", nil)
+			checkErr(t, err, appErrors.ErrForbidden)
+		}
+	})
+}
+
+func TestMarkOverdue(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, f *fixture) {
+		// Missions are added through the DAO, the service would refuse
+		// deadlines in the past.
+		add := func(deadline time.Time) uint {
+			mission, err := f.repos.missions.AddMission(ctx, models.Mission{Name: "Mission", Priority: models.PriorityNormal, Deadline: &deadline})
+			checkErr(t, err, nil)
+			return mission.ID
+		}
+
+		overdue := add(time.Now().Add(-time.Hour))
+		add(time.Now().Add(time.Hour))
+		aborted := add(time.Now().Add(-time.Hour))
+		checkErr(t, f.repos.missions.UpdateStatus(ctx, aborted, models.MissionDraft, models.MissionAborted), nil)
+
+		marked, err := f.missions.MarkOverdue(ctx)
+		checkErr(t, err, nil)
+		if len(marked) != 1 || marked[0].ID != overdue || marked[0].OverdueAt == nil {
+			t.Fatalf("marked = %+v, want only mission %d", marked, overdue)
+		}
+		assertEvents(t, f, events.MissionOverdue)
+
+		marked, err = f.missions.MarkOverdue(ctx)
+		checkErr(t, err, nil)
+		if len(marked) != 0 {
+			t.Errorf("marked again: %+v", marked)
+		}
+
+		page, err := f.missions.ListOverdueMissions(ctx, models.MissionFilter{})
+		checkErr(t, err, nil)
+		if page.Total != 1 || page.List[0].ID != overdue {
+			t.Errorf("overdue missions = %+v, want only mission %d", page.List, overdue)
+		}
+	})
+}
+
+func TestListMissions(t *testing.T) {
+	assigned := true
+
+	tests := []struct {
+		name   string
+		filter func(catID uint) models.MissionFilter
+		want   []string
+	}{
+		{
+			name:   "by id",
+			filter: func(uint) models.MissionFilter { return models.MissionFilter{} },
+			want:   []string{"Alpha", "Bravo", "Charlie"},
+		},
+		{
+			name:   "by status",
+			filter: func(uint) models.MissionFilter { return models.MissionFilter{Status: "draft"} },
+			want:   []string{"Alpha", "Charlie"},
+		},
+		{
+			name:   "assigned",
+			filter: func(uint) models.MissionFilter { return models.MissionFilter{Assigned: &assigned} },
+			want:   []string{"Bravo"},
+		},
+		{
+			name:   "by cat",
+			filter: func(catID uint) models.MissionFilter { return models.MissionFilter{CatID: &catID} },
+			want:   []string{"Bravo"},
+		},
+		{
+			name:   "by target country",
+			filter: func(uint) models.MissionFilter { return models.MissionFilter{TargetCountry: "Spain"} },
+			want:   []string{"Charlie"},
+		},
+		{
+			name:   "by priority",
+			filter: func(uint) models.MissionFilter { return models.MissionFilter{Priority: models.PriorityCritical} },
+			want:   []string{"Bravo"},
+		},
+		{
+			name:   "most urgent first",
+			filter: func(uint) models.MissionFilter { return models.MissionFilter{Sort: "-priority"} },
+			want:   []string{"Bravo", "Alpha", "Charlie"},
+		},
+		{
+			name:   "earliest deadline first",
+			filter: func(uint) models.MissionFilter { return models.MissionFilter{Sort: "deadline"} },
+			want:   []string{"Charlie", "Alpha", "Bravo"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forEachBackend(t, func(t *testing.T, f *fixture) {
+				catID := f.hire(t, "Tom", 3)
+				f.createMission(t, 1, func(m *models.Mission) {
+					m.Name = "Alpha"
+					m.Deadline = timePtr(time.Now().Add(2 * time.Hour))
+				})
+				f.createMission(t, 1, func(m *models.Mission) {
+					m.Name = "Bravo"
+					m.Priority = models.PriorityCritical
+					m.CatId = &catID
+				})
+				f.createMission(t, 1, func(m *models.Mission) {
+					m.Name = "Charlie"
+					m.Priority = models.PriorityLow
+					m.Deadline = timePtr(time.Now().Add(time.Hour))
+					m.TargetList[0].Country = "Spain"
+				})
+
+				page, err := f.missions.ListMissions(ctx, tt.filter(catID))
+				checkErr(t, err, nil)
+
+				var names []string
+				for _, mission := range page.List {
+					names = append(names, mission.Name)
+					if len(mission.TargetList) != 1 {
+						t.Errorf("mission %s has %d targets, want 1", mission.Name, len(mission.TargetList))
+					}
+				}
+				assertStrings(t, names, tt.want)
+			})
+		})
+	}
+}
+
+// checkMissionStatus checks that err reports the status of the mission next
+// to, not in place of, the HTTP status of the problem.
+func checkMissionStatus(t *testing.T, err error, want models.MissionStatus) {
+	t.Helper()
+
+	var httpErr *appErrors.HttpError
+	if !errors.As(err, &httpErr) {
+		t.Fatalf("error = %v, want an HttpError", err)
+	}
+
+	problem := httpErr.Problem("")
+	if problem["status"] != httpErr.StatusCode {
+		t.Errorf("problem status = %v, want %d", problem["status"], httpErr.StatusCode)
+	}
+	if problem["mission_status"] != want {
+		t.Errorf("problem mission_status = %v, want %s", problem["mission_status"], want)
+	}
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
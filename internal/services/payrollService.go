@@ -0,0 +1,65 @@
+package services
+
+import (
+	"context"
+	appErrors "spy_cat_agency/internal/appErorrs"
+	"spy_cat_agency/internal/models"
+	"spy_cat_agency/internal/payroll"
+	"time"
+)
+
+type IPayrollDao interface {
+	ListCatsHiredBefore(ctx context.Context, until time.Time) ([]models.Cat, error)
+	ListSalaryChanges(ctx context.Context, until time.Time) ([]models.SalaryChange, error)
+	ListEmploymentEvents(ctx context.Context, until time.Time) ([]models.EmploymentEvent, error)
+}
+
+type PayrollService struct {
+	PayrollDao IPayrollDao
+}
+
+func NewPayrollService(payrollDao IPayrollDao) *PayrollService {
+	return &PayrollService{
+		PayrollDao: payrollDao,
+	}
+}
+
+func (s *PayrollService) Report(ctx context.Context, filter models.PayrollFilter) (models.PayrollReport, error) {
+	if filter.From.After(filter.To) {
+		return models.PayrollReport{}, appErrors.ErrInvalidRequest.WithDetail("from must not be after to")
+	}
+
+	cats, err := s.PayrollDao.ListCatsHiredBefore(ctx, filter.To)
+	if err != nil {
+		return models.PayrollReport{}, err
+	}
+
+	changes, err := s.PayrollDao.ListSalaryChanges(ctx, filter.To)
+	if err != nil {
+		return models.PayrollReport{}, err
+	}
+
+	events, err := s.PayrollDao.ListEmploymentEvents(ctx, filter.To)
+	if err != nil {
+		return models.PayrollReport{}, err
+	}
+
+	employees := make([]payroll.Employee, len(cats))
+	index := make(map[uint]int, len(cats))
+	for i, cat := range cats {
+		employees[i].Cat = cat
+		index[cat.ID] = i
+	}
+	for _, change := range changes {
+		if i, ok := index[change.CatID]; ok {
+			employees[i].Salary = append(employees[i].Salary, change)
+		}
+	}
+	for _, event := range events {
+		if i, ok := index[event.CatID]; ok {
+			employees[i].Employment = append(employees[i].Employment, event)
+		}
+	}
+
+	return payroll.Compute(filter.From, filter.To, employees), nil
+}
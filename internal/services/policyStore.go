@@ -0,0 +1,88 @@
+package services
+
+import (
+	"fmt"
+	"spy_cat_agency/internal/models"
+	"sync"
+	"time"
+)
+
+type IPolicySource interface {
+	FetchPolicy() (models.Policy, error)
+}
+
+// IPolicy gives services the rules currently in force.
+type IPolicy interface {
+	Current() models.Policy
+}
+
+type PolicyStatus struct {
+	Policy    models.Policy `json:"policy"`
+	LoadedAt  time.Time     `json:"loaded_at"`
+	LastError string        `json:"last_error,omitempty"`
+}
+
+// PolicyStore keeps the policy in memory. Reload replaces it only with a
+// policy that passes validation, so a broken file or row leaves the previous
+// rules in force.
+type PolicyStore struct {
+	source IPolicySource
+
+	mu       sync.RWMutex
+	policy   models.Policy
+	loadedAt time.Time
+	lastErr  error
+}
+
+// NewPolicyStore starts with the default policy until the first Reload.
+func NewPolicyStore(source IPolicySource) *PolicyStore {
+	return &PolicyStore{
+		source: source,
+		policy: models.DefaultPolicy(),
+	}
+}
+
+func (s *PolicyStore) Reload() error {
+	p, err := s.source.FetchPolicy()
+	if err == nil {
+		if verr := p.Validate(); verr != nil {
+			err = fmt.Errorf("invalid policy: %w", verr)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err != nil {
+		s.lastErr = err
+		return err
+	}
+
+	s.policy = p
+	s.loadedAt = time.Now()
+	s.lastErr = nil
+
+	return nil
+}
+
+func (s *PolicyStore) Current() models.Policy {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.policy
+}
+
+func (s *PolicyStore) Status() PolicyStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	status := PolicyStatus{
+		Policy:   s.policy,
+		LoadedAt: s.loadedAt,
+	}
+	if s.lastErr != nil {
+		status.LastError = s.lastErr.Error()
+	}
+
+	return status
+}
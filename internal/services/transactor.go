@@ -0,0 +1,9 @@
+package services
+
+import "context"
+
+// ITransactor groups DAO calls into one atomic unit. DAO calls must use the
+// context passed to fn to take part in the transaction.
+type ITransactor interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	appErrors "spy_cat_agency/internal/appErorrs"
+	"spy_cat_agency/internal/events"
+	"spy_cat_agency/internal/models"
+	"spy_cat_agency/internal/webhooks"
+)
+
+type IWebhookDao interface {
+	AddWebhook(ctx context.Context, hook models.Webhook) (*models.Webhook, error)
+	ListWebhooks(ctx context.Context) ([]models.Webhook, error)
+	GetWebhook(ctx context.Context, id uint) (*models.Webhook, error)
+	DeleteWebhook(ctx context.Context, id uint) error
+	QueryDeliveries(ctx context.Context, webhookID uint, filter models.DeliveryFilter) (models.Page[models.WebhookDelivery], error)
+	GetDelivery(ctx context.Context, id uint) (*models.WebhookDelivery, error)
+	RetryDelivery(ctx context.Context, id uint) error
+}
+
+type WebhookService struct {
+	WebhookDao IWebhookDao
+}
+
+func NewWebhookService(webhookDao IWebhookDao) *WebhookService {
+	return &WebhookService{
+		WebhookDao: webhookDao,
+	}
+}
+
+// RegisterWebhook stores the webhook, generating a secret when none is given.
+// Only events published after registration are delivered to it.
+func (s *WebhookService) RegisterWebhook(ctx context.Context, hook models.Webhook) (*models.Webhook, error) {
+	u, err := url.Parse(hook.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, appErrors.ErrInvalidRequest.WithDetail("webhook url must be an absolute http or https url")
+	}
+
+	if hook.Events == nil {
+		hook.Events = []string{}
+	}
+	for _, eventType := range hook.Events {
+		if !events.Type(eventType).IsKnown() {
+			return nil, appErrors.ErrInvalidRequest.
+				WithDetail(fmt.Sprintf("unknown event type %q", eventType)).
+				WithExtension("allowed", events.Types())
+		}
+	}
+
+	if hook.Secret == "" {
+		if hook.Secret, err = webhooks.GenerateSecret(); err != nil {
+			return nil, err
+		}
+	}
+
+	res, err := s.WebhookDao.AddWebhook(ctx, hook)
+
+	return res, err
+}
+
+func (s *WebhookService) ListWebhooks(ctx context.Context) ([]models.Webhook, error) {
+	list, err := s.WebhookDao.ListWebhooks(ctx)
+
+	return list, err
+}
+
+func (s *WebhookService) GetWebhook(ctx context.Context, id uint) (*models.Webhook, error) {
+	hook, err := s.WebhookDao.GetWebhook(ctx, id)
+	if errors.Is(err, appErrors.ErrNotFound) {
+		return nil, appErrors.ErrWebhookNotFound
+	}
+
+	return hook, err
+}
+
+func (s *WebhookService) DeleteWebhook(ctx context.Context, id uint) error {
+	err := s.WebhookDao.DeleteWebhook(ctx, id)
+	if errors.Is(err, appErrors.ErrNotFound) {
+		return appErrors.ErrWebhookNotFound
+	}
+
+	return err
+}
+
+func (s *WebhookService) ListDeliveries(ctx context.Context, webhookID uint, filter models.DeliveryFilter) (models.Page[models.WebhookDelivery], error) {
+	if _, err := s.GetWebhook(ctx, webhookID); err != nil {
+		return models.Page[models.WebhookDelivery]{}, err
+	}
+
+	page, err := s.WebhookDao.QueryDeliveries(ctx, webhookID, filter)
+
+	return page, err
+}
+
+func (s *WebhookService) GetDelivery(ctx context.Context, id uint) (*models.WebhookDelivery, error) {
+	delivery, err := s.WebhookDao.GetDelivery(ctx, id)
+	if errors.Is(err, appErrors.ErrNotFound) {
+		return nil, appErrors.ErrDeliveryNotFound
+	}
+
+	return delivery, err
+}
+
+// RetryDelivery queues a dead delivery for another round of attempts.
+func (s *WebhookService) RetryDelivery(ctx context.Context, id uint) (*models.WebhookDelivery, error) {
+	if _, err := s.GetDelivery(ctx, id); err != nil {
+		return nil, err
+	}
+
+	if err := s.WebhookDao.RetryDelivery(ctx, id); err != nil {
+		return nil, err
+	}
+
+	return s.GetDelivery(ctx, id)
+}
@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"context"
+	"spy_cat_agency/internal/events"
+	"spy_cat_agency/internal/memstore"
+)
+
+// DefaultEmbeddedFile is where the embedded store is kept when no source is
+// configured.
+const DefaultEmbeddedFile = "spy_cat_agency.db"
+
+func openEmbedded(config Config) (*Storage, error) {
+	path := config.Source
+	if path == "" {
+		path = DefaultEmbeddedFile
+	}
+
+	store, err := memstore.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Storage{
+		Driver:     DriverEmbedded,
+		Cats:       memstore.NewCatRepository(store),
+		Missions:   memstore.NewMissionRepository(store),
+		Audit:      memstore.NewAuditRepository(store),
+		Payroll:    memstore.NewPayrollRepository(store),
+		ApiKeys:    memstore.NewApiKeyRepository(store),
+		Webhooks:   memstore.NewWebhookRepository(store),
+		Breeds:     memstore.NewBreedRepository(store),
+		Policies:   memstore.NewPolicyRepository(store),
+		Transactor: memstore.NewUnitOfWork(store),
+		Outbox:     memstore.NewOutboxRepository(store),
+		AfterCommit: func(publisher events.Publisher) events.Publisher {
+			return memstore.NewAfterCommitPublisher(store, publisher)
+		},
+		migrate: store.Migrate,
+		latest: func() (uint, error) {
+			return memstore.LatestVersion(), nil
+		},
+		version: func(context.Context) (uint, bool, error) {
+			return store.Version(), false, nil
+		},
+		ping: func(context.Context) error {
+			return store.Ping()
+		},
+		close: store.Close,
+	}, nil
+}
@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io/fs"
+	"spy_cat_agency/internal/database"
+	"spy_cat_agency/internal/events"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+)
+
+func openPostgres(config Config) (*Storage, error) {
+	db, err := sql.Open("postgres", config.Source)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(config.MaxOpenConns)
+	db.SetMaxIdleConns(config.MaxIdleConns)
+	db.SetConnMaxLifetime(config.ConnMaxLifetime)
+
+	return &Storage{
+		Driver:     DriverPostgres,
+		Cats:       database.NewCatRepository(db),
+		Missions:   database.NewMissionRepository(db),
+		Audit:      database.NewAuditRepository(db),
+		Payroll:    database.NewPayrollRepository(db),
+		ApiKeys:    database.NewApiKeyRepository(db),
+		Webhooks:   database.NewWebhookRepository(db),
+		Breeds:     database.NewBreedRepository(db),
+		Policies:   database.NewPolicyRepository(db),
+		Transactor: database.NewUnitOfWork(db),
+		Outbox:     database.NewOutboxRepository(db),
+		AfterCommit: func(publisher events.Publisher) events.Publisher {
+			return database.NewAfterCommitPublisher(publisher)
+		},
+		migrate: func() error {
+			migration, err := migrate.New(config.MigrationPath, config.Source)
+			if err != nil {
+				return err
+			}
+			defer migration.Close()
+
+			if err := migration.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+				return err
+			}
+			return nil
+		},
+		latest: func() (uint, error) {
+			return latestMigration(config.MigrationPath)
+		},
+		version: func(ctx context.Context) (uint, bool, error) {
+			return schemaVersion(ctx, db)
+		},
+		ping:  db.PingContext,
+		close: db.Close,
+	}, nil
+}
+
+// latestMigration returns the version of the last migration at path.
+func latestMigration(path string) (uint, error) {
+	migrations, err := source.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer migrations.Close()
+
+	version, err := migrations.First()
+	if err != nil {
+		return 0, err
+	}
+
+	for {
+		next, err := migrations.Next(version)
+		if errors.Is(err, fs.ErrNotExist) {
+			return version, nil
+		} else if err != nil {
+			return 0, err
+		}
+		version = next
+	}
+}
+
+// schemaVersion reads the version golang-migrate keeps in schema_migrations.
+// A database that has never been migrated is at version 0.
+func schemaVersion(ctx context.Context, db *sql.DB) (uint, bool, error) {
+	var (
+		version uint
+		dirty   bool
+	)
+
+	err := db.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+
+	return version, dirty, nil
+}
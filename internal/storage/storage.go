@@ -0,0 +1,115 @@
+// Package storage opens the repositories of the storage driver chosen in the
+// configuration: Postgres, or an embedded store kept in a local file for
+// running the service without any external database.
+package storage
+
+import (
+	"context"
+	"fmt"
+	"spy_cat_agency/internal/events"
+	"spy_cat_agency/internal/services"
+	"spy_cat_agency/internal/webhooks"
+	"time"
+)
+
+const (
+	DriverPostgres = "postgres"
+	DriverEmbedded = "embedded"
+)
+
+// Config selects a driver. Source is the Postgres connection URL, or the
+// path of the embedded store's file. MigrationPath and the pool settings only
+// apply to Postgres; the embedded store's migrations are compiled in.
+type Config struct {
+	Driver          string
+	Source          string
+	MigrationPath   string
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+}
+
+// IWebhookStore is what the webhook API and the delivery worker need.
+type IWebhookStore interface {
+	services.IWebhookDao
+	webhooks.Store
+}
+
+// Storage is the set of repositories of one driver.
+type Storage struct {
+	Driver     string
+	Cats       services.ICatDao
+	Missions   services.IMissionDao
+	Audit      services.IAuditDao
+	Payroll    services.IPayrollDao
+	ApiKeys    services.IApiKeyDao
+	Webhooks   IWebhookStore
+	Breeds     services.IBreedSource
+	Policies   services.IPolicySource
+	Transactor services.ITransactor
+	// Outbox stores events in the transaction of the change they describe.
+	Outbox events.Publisher
+	// AfterCommit wraps a publisher that lives outside of storage, such as
+	// the event bus, so it only receives events of committed changes.
+	AfterCommit func(events.Publisher) events.Publisher
+
+	migrate func() error
+	latest  func() (uint, error)
+	version func(ctx context.Context) (uint, bool, error)
+	ping    func(ctx context.Context) error
+	close   func() error
+
+	// required is the version of the last migration of this build.
+	required uint
+}
+
+// Open opens the storage of the driver in config. An empty driver is
+// Postgres.
+func Open(config Config) (*Storage, error) {
+	switch config.Driver {
+	case "", DriverPostgres:
+		return openPostgres(config)
+	case DriverEmbedded:
+		return openEmbedded(config)
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", config.Driver)
+	}
+}
+
+// Migrate brings the storage up to the latest version of its schema.
+func (s *Storage) Migrate() error {
+	if err := s.migrate(); err != nil {
+		return err
+	}
+
+	required, err := s.latest()
+	if err != nil {
+		return fmt.Errorf("read latest migration: %w", err)
+	}
+	s.required = required
+
+	return nil
+}
+
+// SchemaVersion returns the version the schema is at, and whether the
+// migration to it failed halfway.
+func (s *Storage) SchemaVersion(ctx context.Context) (version uint, dirty bool, err error) {
+	return s.version(ctx)
+}
+
+// RequiredVersion returns the schema version this build needs, the one of
+// its last migration, 0 until Migrate has run. A schema at a later version
+// was migrated by a newer build during a rolling deploy and still serves
+// this one.
+func (s *Storage) RequiredVersion() uint {
+	return s.required
+}
+
+// Ping checks that the storage can still be reached.
+func (s *Storage) Ping(ctx context.Context) error {
+	return s.ping(ctx)
+}
+
+func (s *Storage) Close() error {
+	return s.close()
+}
@@ -0,0 +1,45 @@
+package textdiff
+
+import (
+	"strings"
+	"testing"
+)
+
+// script writes an edit script as "=kept +inserted -deleted".
+func script(lines []Line) string {
+	signs := map[Op]string{Equal: "=", Insert: "+", Delete: "-"}
+
+	parts := make([]string, len(lines))
+	for i, line := range lines {
+		parts[i] = signs[line.Op] + line.Text
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{"identical", "a\nb\nc", "a\nb\nc", "=a =b =c"},
+		{"both empty", "", "", ""},
+		{"insertion", "a\nc", "a\nb\nc", "=a +b =c"},
+		{"deletion", "a\nb\nc", "a\nc", "=a -b =c"},
+		{"empty old text", "", "a\nb", "+a +b"},
+		{"empty new text", "a\nb", "", "-a -b"},
+		{"changed line in the middle", "a\nb\nc", "a\nB\nc", "=a -b +B =c"},
+		{"changed first and last lines", "a\nb\nc", "A\nb\nC", "-a +A =b -c +C"},
+		{"missing trailing newline", "a\nb\n", "a\nb", "=a =b"},
+		{"extra trailing newline", "a\nb", "a\nb\n", "=a =b"},
+		{"extra empty line", "a\nb\n", "a\nb\n\n", "=a =b +"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := script(Lines(tt.a, tt.b)); got != tt.want {
+				t.Errorf("Lines(%q, %q) = %q, want %q", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
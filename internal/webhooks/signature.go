@@ -0,0 +1,55 @@
+// Package webhooks delivers outbox events to registered webhooks.
+//
+// Every request is a POST of the event JSON with these headers:
+//
+//	X-Webhook-Event      event type, e.g. target.completed
+//	X-Webhook-Delivery   delivery id, the same for every retry
+//	X-Webhook-Timestamp  Unix time the request was signed at
+//	X-Webhook-Signature  sha256=<hex HMAC-SHA256 of "<timestamp>.<body>">
+//
+// Receivers should recompute the signature with the webhook secret and
+// reject old timestamps to stop replays.
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+)
+
+const (
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+	TimestampHeader = "X-Webhook-Timestamp"
+	SignatureHeader = "X-Webhook-Signature"
+
+	secretPrefix = "whsec_"
+)
+
+// GenerateSecret returns a random secret for a new webhook.
+func GenerateSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return secretPrefix + hex.EncodeToString(buf), nil
+}
+
+// Sign returns the value of the signature header for body sent at timestamp.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature was made by Sign with the same secret,
+// timestamp and body.
+func Verify(secret string, timestamp int64, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
@@ -0,0 +1,162 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"spy_cat_agency/internal/logging"
+	"spy_cat_agency/internal/models"
+	"strconv"
+	"time"
+)
+
+const (
+	// MaxAttempts is the number of failed attempts after which a delivery is
+	// dead.
+	MaxAttempts = 8
+
+	batchSize   = 20
+	baseBackoff = 30 * time.Second
+	maxBackoff  = 6 * time.Hour
+
+	// leaseMargin covers recording the outcomes of a batch on top of sending
+	// it.
+	leaseMargin = time.Minute
+)
+
+type Store interface {
+	ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]models.DueDelivery, error)
+	RecordAttempt(ctx context.Context, delivery models.WebhookDelivery) error
+}
+
+// Worker sends due deliveries. A delivery succeeds on any 2xx response;
+// otherwise it is retried with exponential backoff until MaxAttempts.
+type Worker struct {
+	store  Store
+	client *http.Client
+}
+
+// NewWorker creates a worker sending with client, which should have a
+// timeout: a claimed delivery is only retried by another worker once the
+// claim expires, and the claim must outlast the whole batch.
+func NewWorker(store Store, client *http.Client) *Worker {
+	return &Worker{
+		store:  store,
+		client: client,
+	}
+}
+
+// DeliverDue sends one batch of due deliveries and records the outcome of
+// each. Only failures to talk to the store are returned; failed deliveries
+// are scheduled for a retry.
+func (w *Worker) DeliverDue(ctx context.Context) error {
+	due, err := w.store.ClaimDue(ctx, batchSize, lease(w.client.Timeout))
+	if err != nil {
+		return err
+	}
+
+	logger := logging.FromContext(ctx)
+
+	var errs []error
+	for _, delivery := range due {
+		status, err := w.send(ctx, delivery)
+		result := outcome(delivery.WebhookDelivery, status, err, time.Now())
+		if err := w.store.RecordAttempt(ctx, result); err != nil {
+			errs = append(errs, err)
+		}
+
+		attrs := []any{"delivery_id", result.ID, "webhook_id", result.WebhookID, "event", delivery.EventType, "attempt", result.Attempts, "status", status}
+		switch {
+		case err == nil:
+			logger.Debug("webhook delivered", attrs...)
+		case result.Status == models.DeliveryDead:
+			logger.Error("webhook delivery failed for the last time", append(attrs, logging.Err(err))...)
+		default:
+			logger.Warn("webhook delivery failed, will retry", append(attrs, "next_attempt_at", result.NextAttemptAt, logging.Err(err))...)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// lease is how long a batch stays claimed. Deliveries are sent one after
+// another, so the last one may only be sent after batchSize timeouts; its
+// claim must not expire while it waits, or another worker sends it too.
+func lease(timeout time.Duration) time.Duration {
+	if timeout <= 0 {
+		timeout = time.Minute
+	}
+
+	return batchSize*timeout + leaseMargin
+}
+
+// send posts the payload and returns the response status.
+func (w *Worker) send(ctx context.Context, delivery models.DueDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, delivery.EventType)
+	req.Header.Set(DeliveryHeader, strconv.FormatUint(uint64(delivery.ID), 10))
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(delivery.Secret, timestamp, delivery.Payload))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected response status %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// outcome is the delivery after an attempt that ended with status and err.
+func outcome(delivery models.WebhookDelivery, status int, err error, now time.Time) models.WebhookDelivery {
+	delivery.Attempts++
+	delivery.ResponseStatus = nil
+	if status != 0 {
+		delivery.ResponseStatus = &status
+	}
+
+	if err == nil {
+		delivery.Status = models.DeliveryDelivered
+		delivery.LastError = nil
+		delivery.DeliveredAt = &now
+		delivery.NextAttemptAt = now
+		return delivery
+	}
+
+	message := err.Error()
+	delivery.LastError = &message
+	delivery.NextAttemptAt = now.Add(Backoff(delivery.Attempts))
+	if delivery.Attempts >= MaxAttempts {
+		delivery.Status = models.DeliveryDead
+	}
+
+	return delivery
+}
+
+// Backoff is the wait before the attempt that follows the given number of
+// failed attempts: 30s, 1m, 2m, ... up to 6h.
+func Backoff(attempts int) time.Duration {
+	d := baseBackoff
+	for i := 1; i < attempts && d < maxBackoff; i++ {
+		d *= 2
+	}
+
+	if d > maxBackoff {
+		return maxBackoff
+	}
+	return d
+}
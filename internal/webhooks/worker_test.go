@@ -0,0 +1,219 @@
+package webhooks
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"spy_cat_agency/internal/models"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memStore hands out its pending deliveries and keeps the recorded attempts.
+type memStore struct {
+	mu         sync.Mutex
+	deliveries []models.DueDelivery
+	recorded   []models.WebhookDelivery
+	leases     []time.Duration
+}
+
+func (s *memStore) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]models.DueDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.leases = append(s.leases, lease)
+	var due []models.DueDelivery
+	for _, d := range s.deliveries {
+		if d.Status == models.DeliveryPending && len(due) < limit {
+			due = append(due, d)
+		}
+	}
+	return due, nil
+}
+
+func (s *memStore) RecordAttempt(ctx context.Context, delivery models.WebhookDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.recorded = append(s.recorded, delivery)
+	for i := range s.deliveries {
+		if s.deliveries[i].ID == delivery.ID {
+			s.deliveries[i].WebhookDelivery = delivery
+		}
+	}
+	return nil
+}
+
+// receiver is a local webhook endpoint that checks signatures and answers
+// with status.
+type receiver struct {
+	secret string
+	status int
+
+	mu       sync.Mutex
+	requests int
+	invalid  int
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	timestamp, _ := strconv.ParseInt(req.Header.Get(TimestampHeader), 10, 64)
+
+	r.mu.Lock()
+	r.requests++
+	if !Verify(r.secret, timestamp, body, req.Header.Get(SignatureHeader)) || req.Header.Get(EventHeader) != "target.completed" {
+		r.invalid++
+	}
+	r.mu.Unlock()
+
+	w.WriteHeader(r.status)
+}
+
+func TestWorkerDeliverDue(t *testing.T) {
+	const secret = "whsec_test"
+
+	tests := []struct {
+		name         string
+		status       int
+		attempts     int
+		wantStatus   models.DeliveryStatus
+		wantAttempts int
+		wantBackoff  time.Duration
+	}{
+		{name: "delivered on 2xx", status: http.StatusNoContent, wantStatus: models.DeliveryDelivered, wantAttempts: 1},
+		{name: "retried on 5xx", status: http.StatusInternalServerError, wantStatus: models.DeliveryPending, wantAttempts: 1, wantBackoff: 30 * time.Second},
+		{name: "backoff doubles", status: http.StatusBadGateway, attempts: 2, wantStatus: models.DeliveryPending, wantAttempts: 3, wantBackoff: 2 * time.Minute},
+		{name: "dead after max attempts", status: http.StatusGone, attempts: MaxAttempts - 1, wantStatus: models.DeliveryDead, wantAttempts: MaxAttempts, wantBackoff: Backoff(MaxAttempts)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recv := &receiver{secret: secret, status: tt.status}
+			srv := httptest.NewServer(recv)
+			defer srv.Close()
+
+			store := &memStore{deliveries: []models.DueDelivery{{
+				WebhookDelivery: models.WebhookDelivery{
+					ID:        1,
+					EventType: "target.completed",
+					Status:    models.DeliveryPending,
+					Attempts:  tt.attempts,
+					Payload:   []byte(`{"type":"target.completed"}`),
+				},
+				URL:    srv.URL,
+				Secret: secret,
+			}}}
+
+			before := time.Now()
+			if err := NewWorker(store, srv.Client()).DeliverDue(context.Background()); err != nil {
+				t.Fatalf("DeliverDue: %v", err)
+			}
+
+			if recv.requests != 1 || recv.invalid != 0 {
+				t.Fatalf("receiver got %d requests, %d with a bad signature or event header", recv.requests, recv.invalid)
+			}
+			if len(store.recorded) != 1 {
+				t.Fatalf("recorded %d attempts, want 1", len(store.recorded))
+			}
+
+			got := store.recorded[0]
+			if got.Status != tt.wantStatus || got.Attempts != tt.wantAttempts {
+				t.Errorf("status %s after %d attempts, want %s after %d", got.Status, got.Attempts, tt.wantStatus, tt.wantAttempts)
+			}
+			if got.ResponseStatus == nil || *got.ResponseStatus != tt.status {
+				t.Errorf("response status %v, want %d", got.ResponseStatus, tt.status)
+			}
+
+			if tt.wantStatus == models.DeliveryDelivered {
+				if got.DeliveredAt == nil || got.LastError != nil {
+					t.Errorf("delivered delivery has delivered_at %v and last_error %v", got.DeliveredAt, got.LastError)
+				}
+				return
+			}
+
+			if got.LastError == nil {
+				t.Error("failed delivery has no last_error")
+			}
+			if wait := got.NextAttemptAt.Sub(before); wait < tt.wantBackoff || wait > tt.wantBackoff+time.Minute {
+				t.Errorf("next attempt in %s, want about %s", wait, tt.wantBackoff)
+			}
+		})
+	}
+}
+
+func TestWorkerUnreachableReceiver(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close()
+
+	store := &memStore{deliveries: []models.DueDelivery{{
+		WebhookDelivery: models.WebhookDelivery{ID: 1, Status: models.DeliveryPending},
+		URL:             url,
+		Secret:          "whsec_test",
+	}}}
+
+	if err := NewWorker(store, &http.Client{Timeout: time.Second}).DeliverDue(context.Background()); err != nil {
+		t.Fatalf("DeliverDue: %v", err)
+	}
+
+	got := store.recorded[0]
+	if got.Status != models.DeliveryPending || got.Attempts != 1 || got.ResponseStatus != nil || got.LastError == nil {
+		t.Errorf("got status %s, %d attempts, response %v, error %v", got.Status, got.Attempts, got.ResponseStatus, got.LastError)
+	}
+}
+
+// The claim on a batch lasts until its last delivery, sent after all the
+// others timed out, has been recorded.
+func TestWorkerLeaseCoversBatch(t *testing.T) {
+	for _, timeout := range []time.Duration{0, time.Second, 10 * time.Second} {
+		store := &memStore{}
+		worker := NewWorker(store, &http.Client{Timeout: timeout})
+
+		if err := worker.DeliverDue(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(store.leases) != 1 || store.leases[0] <= batchSize*timeout {
+			t.Errorf("timeout %s: leases = %v, want one longer than %s", timeout, store.leases, batchSize*timeout)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{5, 8 * time.Minute},
+		{20, 6 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := Backoff(tt.attempts); got != tt.want {
+			t.Errorf("Backoff(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	body := []byte(`{"type":"mission.completed"}`)
+	signature := Sign("secret", 1700000000, body)
+
+	if !Verify("secret", 1700000000, body, signature) {
+		t.Error("signature does not verify")
+	}
+	if Verify("other", 1700000000, body, signature) {
+		t.Error("signature verifies with another secret")
+	}
+	if Verify("secret", 1700000001, body, signature) {
+		t.Error("signature verifies with another timestamp")
+	}
+	if Verify("secret", 1700000000, []byte(`{}`), signature) {
+		t.Error("signature verifies with another body")
+	}
+}